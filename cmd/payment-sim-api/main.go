@@ -19,12 +19,15 @@ import (
 	"google.golang.org/grpc/reflection"
 
 	paymentv1 "github.com/traffic-tacos/proto-contracts/gen/go/payment/v1"
+	"github.com/traffic-tacos/payment-sim-api/internal/admin"
 	awsClient "github.com/traffic-tacos/payment-sim-api/internal/aws"
 	"github.com/traffic-tacos/payment-sim-api/internal/config"
 	"github.com/traffic-tacos/payment-sim-api/internal/events"
 	"github.com/traffic-tacos/payment-sim-api/internal/grpc/server"
 	"github.com/traffic-tacos/payment-sim-api/internal/observability"
+	"github.com/traffic-tacos/payment-sim-api/internal/scenario"
 	"github.com/traffic-tacos/payment-sim-api/internal/service"
+	"github.com/traffic-tacos/payment-sim-api/internal/testvalues"
 	"github.com/traffic-tacos/payment-sim-api/internal/webhook"
 )
 
@@ -52,12 +55,67 @@ func main() {
 	// Initialize EventBridge publisher
 	eventPublisher := events.NewPublisher(awsClients.EventBridge, &cfg, logger)
 
+	// Initialize payment intent store
+	intentStore, err := service.NewStore(logger, &cfg)
+	if err != nil {
+		logger.Fatal("Failed to initialize intent store", zap.Error(err))
+	}
+	defer intentStore.Close()
+
+	// Load scripted payment scenarios (스크립트의 상태 전이는 결제 상태 머신으로 검증)
+	scenarios := scenario.NewRegistry(service.NewStateMachine().CanTransitionName)
+	if cfg.ScenarioFile != "" {
+		defs, err := scenarios.LoadFile(cfg.ScenarioFile)
+		if err != nil {
+			logger.Fatal("Failed to load scenario file", zap.String("path", cfg.ScenarioFile), zap.Error(err))
+		}
+		logger.Info("Scenarios loaded", zap.String("path", cfg.ScenarioFile), zap.Int("count", len(defs)))
+	}
+
+	// Default scenario (weighted mix) for requests without a scenario
+	defaultScenario, err := scenario.ParseMix(cfg.DefaultScenario)
+	if err != nil {
+		logger.Fatal("Invalid DEFAULT_SCENARIO", zap.String("default_scenario", cfg.DefaultScenario), zap.Error(err))
+	}
+
+	if err := service.ValidateReservationIntentPolicy(cfg.ReservationIntentPolicy); err != nil {
+		logger.Fatal("Invalid RESERVATION_INTENT_POLICY", zap.String("policy", cfg.ReservationIntentPolicy), zap.Error(err))
+	}
+
+	// Load magic test value rules (built-in decline test cards are always on)
+	testValues := testvalues.NewEngine()
+	if cfg.TestValuesFile != "" {
+		rules, err := testValues.LoadFile(cfg.TestValuesFile)
+		if err != nil {
+			logger.Fatal("Failed to load test value file", zap.String("path", cfg.TestValuesFile), zap.Error(err))
+		}
+		logger.Info("Test value rules loaded", zap.String("path", cfg.TestValuesFile), zap.Int("count", len(rules)))
+	}
+
 	// Initialize services
-	webhookDispatcher := webhook.NewDispatcher(logger, &cfg, eventPublisher)
-	paymentService := service.NewPaymentService(logger, &cfg, webhookDispatcher, eventPublisher)
+	// Webhook signing secrets (primary + rotation, per merchant/URL overrides)
+	webhookSecrets := webhook.NewSecrets(append([]string{cfg.WebhookSecret}, cfg.WebhookSecrets...)...)
+	if cfg.WebhookSecretsFile != "" {
+		secretConfig, err := webhookSecrets.LoadFile(cfg.WebhookSecretsFile)
+		if err != nil {
+			logger.Fatal("Failed to load webhook secrets file", zap.String("path", cfg.WebhookSecretsFile), zap.Error(err))
+		}
+		logger.Info("Webhook secrets loaded",
+			zap.String("path", cfg.WebhookSecretsFile),
+			zap.Int("merchants", len(secretConfig.Merchants)),
+			zap.Int("urls", len(secretConfig.URLs)))
+	}
+
+	deadLetterQueue := webhook.NewDeadLetterQueue(awsClients.SQS, cfg.PaymentWebhookDLQURL, logger)
+	webhookDispatcher := webhook.NewDispatcher(logger, &cfg, eventPublisher, deadLetterQueue, webhookSecrets)
+	paymentService := service.NewPaymentService(logger, &cfg, intentStore, webhookDispatcher, eventPublisher, scenarios, testValues, defaultScenario)
+	webhookDispatcher.SetStatusUpdater(paymentService)
+	webhookDispatcher.Start()
 
 	// Setup gRPC server
-	grpcServer := grpc.NewServer()
+	grpcServer := grpc.NewServer(
+		grpc.ChainUnaryInterceptor(server.UnaryErrorInterceptor(logger)),
+	)
 	paymentGRPCServer := server.NewPaymentServer(paymentService, logger)
 	paymentv1.RegisterPaymentServiceServer(grpcServer, paymentGRPCServer)
 
@@ -68,6 +126,7 @@ func main() {
 	mux := http.NewServeMux()
 	mux.Handle("/metrics", promhttp.Handler())
 	mux.HandleFunc("/health", healthHandler)
+	admin.NewHandler(logger, cfg.AdminToken, scenarios, testValues, defaultScenario, webhookDispatcher, webhookSecrets).Register(mux)
 
 	metricsServer := &http.Server{
 		Addr:    ":8031",
@@ -124,6 +183,13 @@ func main() {
 	grpcServer.GracefulStop()
 	wg.Wait()
 
+	// gRPC 종료 후 더 이상 신규 결제가 없으므로 남은 webhook 작업 정리
+	dispatcherCtx, dispatcherCancel := context.WithTimeout(context.Background(), time.Duration(cfg.WebhookShutdownTimeoutMs)*time.Millisecond)
+	defer dispatcherCancel()
+	if _, err := webhookDispatcher.Shutdown(dispatcherCtx); err != nil {
+		logger.Error("Webhook dispatcher shutdown incomplete", zap.Error(err))
+	}
+
 	logger.Info("Servers stopped")
 }
 
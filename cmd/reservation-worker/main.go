@@ -2,38 +2,23 @@ package main
 
 import (
 	"context"
-	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
 	"os"
 	"os/signal"
+	"syscall"
 	"time"
 
-	"github.com/aws/aws-sdk-go-v2/aws"
-	"github.com/aws/aws-sdk-go-v2/service/sqs"
-	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
 	"github.com/kelseyhightower/envconfig"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"go.uber.org/zap"
 
 	awsClient "github.com/traffic-tacos/payment-sim-api/internal/aws"
 	"github.com/traffic-tacos/payment-sim-api/internal/config"
+	"github.com/traffic-tacos/payment-sim-api/pkg/consumer"
 )
 
-type PaymentEventMessage struct {
-	PaymentID     string `json:"payment_id"`
-	ReservationID string `json:"reservation_id"`
-	UserID        string `json:"user_id"`
-	Status        string `json:"status"`
-	Amount        int64  `json:"amount"`
-	Currency      string `json:"currency"`
-	Timestamp     int64  `json:"timestamp"`
-	EventType     string `json:"event_type"`
-}
-
-type ReservationWorker struct {
-	sqsClient *sqs.Client
-	queueURL  string
-	logger    *zap.Logger
-}
-
 func main() {
 	logger, _ := zap.NewProduction()
 	defer logger.Sync()
@@ -53,18 +38,31 @@ func main() {
 		logger.Fatal("Failed to initialize AWS clients", zap.Error(err))
 	}
 
-	worker := &ReservationWorker{
-		sqsClient: awsClients.SQS,
-		queueURL:  cfg.PaymentWebhookQueueURL,
-		logger:    logger,
+	consumerConfig, err := consumerConfigFromConfig(&cfg)
+	if err != nil {
+		logger.Fatal("Invalid worker config", zap.Error(err))
 	}
+	// 같은 결제 상태 이벤트가 중복 전달돼도 예약 갱신은 한 번만 실행한다
+	dedup, err := newDedupStore(&cfg)
+	if err != nil {
+		logger.Fatal("Failed to initialize dedup store", zap.Error(err))
+	}
+	if closer, ok := dedup.(io.Closer); ok {
+		defer closer.Close()
+	}
+	consumerConfig.Dedup = dedup
+
+	c := consumer.New(logger, consumerConfig, awsClients.SQS)
+
+	// 실제 예약/재고 갱신은 ReservationUpdater 구현을 바꿔 끼운다
+	registerHandlers(c, logger, &logReservationUpdater{logger: logger})
 
 	// Graceful shutdown 설정
 	ctx, cancel := context.WithCancel(ctx)
 	defer cancel()
 
 	sigChan := make(chan os.Signal, 1)
-	signal.Notify(sigChan, os.Interrupt)
+	signal.Notify(sigChan, os.Interrupt, syscall.SIGTERM)
 
 	go func() {
 		<-sigChan
@@ -72,125 +70,99 @@ func main() {
 		cancel()
 	}()
 
-	// SQS 폴링 시작
-	worker.startPolling(ctx)
-}
-
-func (w *ReservationWorker) startPolling(ctx context.Context) {
-	w.logger.Info("Starting SQS polling", zap.String("queue_url", w.queueURL))
-
-	for {
-		select {
-		case <-ctx.Done():
-			w.logger.Info("Context cancelled, stopping polling")
-			return
-		default:
-			w.pollMessages(ctx)
-			time.Sleep(2 * time.Second) // 2초마다 폴링
-		}
-	}
-}
-
-func (w *ReservationWorker) pollMessages(ctx context.Context) {
-	input := &sqs.ReceiveMessageInput{
-		QueueUrl:            aws.String(w.queueURL),
-		MaxNumberOfMessages: 10,
-		WaitTimeSeconds:     20, // Long polling
-		VisibilityTimeout:   60,
+	// Metrics (처리량/지연) 및 health check 서버
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.Handler())
+	mux.HandleFunc("/health", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`{"status":"healthy","service":"reservation-worker"}`))
+	})
+	metricsServer := &http.Server{
+		Addr:    ":8040",
+		Handler: mux,
 	}
+	go func() {
+		logger.Info("Starting metrics server", zap.Int("port", 8040))
+		if err := metricsServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			logger.Error("Metrics server failed", zap.Error(err))
+		}
+	}()
 
-	result, err := w.sqsClient.ReceiveMessage(ctx, input)
-	if err != nil {
-		w.logger.Error("Failed to receive messages from SQS", zap.Error(err))
-		return
+	// SQS 폴링 시작 (종료 시 실행 중인 handler와 ack를 마친 뒤 반환)
+	if err := c.Run(ctx); err != nil {
+		logger.Fatal("Consumer stopped", zap.Error(err))
 	}
 
-	w.logger.Info("Polled SQS",
-		zap.Int("message_count", len(result.Messages)))
-
-	for _, message := range result.Messages {
-		w.processMessage(ctx, message)
+	shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer shutdownCancel()
+	if err := metricsServer.Shutdown(shutdownCtx); err != nil {
+		logger.Error("Metrics server shutdown failed", zap.Error(err))
 	}
 }
 
-func (w *ReservationWorker) processMessage(ctx context.Context, message types.Message) {
-	w.logger.Info("Processing SQS message",
-		zap.String("message_id", aws.ToString(message.MessageId)))
-
-	// EventBridge 메시지 파싱 (EventBridge -> SQS 형태)
-	var eventBridgeMessage struct {
-		Source     string                  `json:"source"`
-		DetailType string                  `json:"detail-type"`
-		Detail     PaymentEventMessage     `json:"detail"`
+func consumerConfigFromConfig(cfg *config.Config) (consumer.Config, error) {
+	// 파싱할 수 없는 메시지를 조용히 삭제하지 않는다
+	var dlqURL string
+	switch cfg.WorkerPoisonPolicy {
+	case "redrive":
+	case "dlq":
+		if cfg.WorkerDLQURL == "" {
+			return consumer.Config{}, fmt.Errorf("WORKER_POISON_POLICY=dlq requires WORKER_DLQ_URL")
+		}
+		// payment-sim-api의 DLQ redrive가 worker poison 메시지를 결제 이벤트로 재발행하지 않도록
+		if cfg.WorkerDLQURL == cfg.PaymentWebhookDLQURL {
+			return consumer.Config{}, fmt.Errorf("WORKER_DLQ_URL must differ from PAYMENT_WEBHOOK_DLQ_URL")
+		}
+		dlqURL = cfg.WorkerDLQURL
+	default:
+		return consumer.Config{}, fmt.Errorf("unknown WORKER_POISON_POLICY %q (want redrive or dlq)", cfg.WorkerPoisonPolicy)
 	}
 
-	if err := json.Unmarshal([]byte(aws.ToString(message.Body)), &eventBridgeMessage); err != nil {
-		w.logger.Error("Failed to unmarshal EventBridge message", zap.Error(err))
-		w.deleteMessage(ctx, message)
-		return
+	var dedupKey consumer.KeyFunc
+	switch cfg.WorkerDedupKey {
+	case "payment_status":
+		dedupKey = consumer.PaymentStatusKey
+	case "event_id":
+		dedupKey = consumer.EventIDKey
+	default:
+		return consumer.Config{}, fmt.Errorf("unknown WORKER_DEDUP_KEY %q (want payment_status or event_id)", cfg.WorkerDedupKey)
 	}
 
-	paymentEvent := eventBridgeMessage.Detail
-
-	w.logger.Info("Processing payment event",
-		zap.String("payment_id", paymentEvent.PaymentID),
-		zap.String("reservation_id", paymentEvent.ReservationID),
-		zap.String("status", paymentEvent.Status),
-		zap.Int64("amount", paymentEvent.Amount))
-
-	// 가라 예약 처리 로직 (설계 발표용)
-	w.processReservation(paymentEvent)
-
-	// 메시지 삭제 (성공적으로 처리됨)
-	w.deleteMessage(ctx, message)
+	return consumer.Config{
+		QueueURL:           cfg.PaymentWebhookQueueURL,
+		VisibilityTimeout:  time.Duration(cfg.WorkerVisibilityTimeoutMs) * time.Millisecond,
+		Pollers:            cfg.WorkerPollers,
+		Concurrency:        cfg.WorkerConcurrency,
+		DeadLetterQueueURL: dlqURL,
+		DedupKey:           dedupKey,
+		Retry: consumer.RetryPolicy{
+			MaxAttempts:     cfg.WorkerMaxAttempts,
+			Base:            time.Duration(cfg.WorkerRetryBaseMs) * time.Millisecond,
+			Max:             time.Duration(cfg.WorkerRetryMaxMs) * time.Millisecond,
+			Jitter:          0.2,
+			RequeueDelay:    time.Duration(cfg.WorkerRequeueDelayMs) * time.Millisecond,
+			MaxRequeueDelay: time.Duration(cfg.WorkerRequeueMaxDelayMs) * time.Millisecond,
+			MaxReceives:     cfg.WorkerMaxReceives,
+		},
+	}, nil
 }
 
-func (w *ReservationWorker) processReservation(event PaymentEventMessage) {
-	// 가라 비즈니스 로직 (실제로는 예약 상태 업데이트 등)
-	switch event.Status {
-	case "PAYMENT_STATUS_APPROVED":
-		w.logger.Info("Payment approved - updating reservation to CONFIRMED",
-			zap.String("reservation_id", event.ReservationID),
-			zap.String("payment_id", event.PaymentID))
-
-		// 실제로는 여기서 reservation DB 업데이트
-		// 예: reservationService.UpdateStatus(event.ReservationID, "CONFIRMED")
-
-	case "PAYMENT_STATUS_FAILED":
-		w.logger.Info("Payment failed - updating reservation to PAYMENT_FAILED",
-			zap.String("reservation_id", event.ReservationID),
-			zap.String("payment_id", event.PaymentID))
-
-		// 실제로는 여기서 reservation DB 업데이트
-		// 예: reservationService.UpdateStatus(event.ReservationID, "PAYMENT_FAILED")
-
+// newDedupStore returns nil when dedup is disabled.
+func newDedupStore(cfg *config.Config) (consumer.DedupStore, error) {
+	ttl := time.Duration(cfg.WorkerDedupTTLMs) * time.Millisecond
+	switch cfg.WorkerDedupStore {
+	case "none":
+		return nil, nil
+	case "", "memory":
+		return consumer.NewMemoryDedupStore(cfg.WorkerDedupSize, ttl), nil
+	case "file":
+		store, err := consumer.NewFileDedupStore(cfg.WorkerDedupPath, cfg.WorkerDedupSize, ttl)
+		if err != nil {
+			return nil, err
+		}
+		return store, nil
 	default:
-		w.logger.Warn("Unknown payment status received",
-			zap.String("status", event.Status),
-			zap.String("payment_id", event.PaymentID))
+		return nil, fmt.Errorf("unknown dedup store: %s", cfg.WorkerDedupStore)
 	}
-
-	// 가라 처리 시간 시뮬레이션
-	time.Sleep(100 * time.Millisecond)
-
-	w.logger.Info("Reservation processing completed",
-		zap.String("reservation_id", event.ReservationID),
-		zap.String("payment_status", event.Status))
 }
-
-func (w *ReservationWorker) deleteMessage(ctx context.Context, message types.Message) {
-	input := &sqs.DeleteMessageInput{
-		QueueUrl:      aws.String(w.queueURL),
-		ReceiptHandle: message.ReceiptHandle,
-	}
-
-	_, err := w.sqsClient.DeleteMessage(ctx, input)
-	if err != nil {
-		w.logger.Error("Failed to delete message from SQS",
-			zap.String("message_id", aws.ToString(message.MessageId)),
-			zap.Error(err))
-	} else {
-		w.logger.Info("Message deleted successfully",
-			zap.String("message_id", aws.ToString(message.MessageId)))
-	}
-}
\ No newline at end of file
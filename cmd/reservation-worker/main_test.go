@@ -0,0 +1,48 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/traffic-tacos/payment-sim-api/internal/config"
+)
+
+func TestConsumerConfigDeadLetterQueue(t *testing.T) {
+	tests := []struct {
+		name       string
+		policy     string
+		workerDLQ  string
+		paymentDLQ string
+		want       string
+		wantErr    string
+	}{
+		{name: "redrive ignores the worker DLQ", policy: "redrive", workerDLQ: "worker-dlq"},
+		{name: "dlq uses the worker DLQ", policy: "dlq", workerDLQ: "worker-dlq", paymentDLQ: "payment-dlq", want: "worker-dlq"},
+		{name: "dlq without a worker DLQ", policy: "dlq", paymentDLQ: "payment-dlq", wantErr: "requires WORKER_DLQ_URL"},
+		{name: "dlq shared with the API", policy: "dlq", workerDLQ: "payment-dlq", paymentDLQ: "payment-dlq", wantErr: "must differ"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &config.Config{
+				WorkerPoisonPolicy:   tt.policy,
+				WorkerDLQURL:         tt.workerDLQ,
+				PaymentWebhookDLQURL: tt.paymentDLQ,
+				WorkerDedupKey:       "payment_status",
+			}
+			got, err := consumerConfigFromConfig(cfg)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("consumerConfigFromConfig: %v", err)
+			}
+			if got.DeadLetterQueueURL != tt.want {
+				t.Errorf("DeadLetterQueueURL = %q, want %q", got.DeadLetterQueueURL, tt.want)
+			}
+		})
+	}
+}
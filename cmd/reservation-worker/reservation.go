@@ -0,0 +1,110 @@
+package main
+
+import (
+	"context"
+
+	"go.uber.org/zap"
+
+	"github.com/traffic-tacos/payment-sim-api/pkg/consumer"
+	"github.com/traffic-tacos/payment-sim-api/pkg/paymentevent"
+)
+
+// ReservationUpdater applies payment outcomes to reservations. An error makes
+// the consumer retry the event, so implementations must be idempotent.
+type ReservationUpdater interface {
+	Confirm(ctx context.Context, event paymentevent.Event) error
+	MarkPaymentFailed(ctx context.Context, event paymentevent.Event) error
+	Refund(ctx context.Context, event paymentevent.Event) error
+}
+
+func registerHandlers(c *consumer.Consumer, logger *zap.Logger, updater ReservationUpdater) {
+	h := &reservationHandlers{logger: logger, updater: updater}
+	c.HandlePaymentEvent(paymentevent.EventTypeStatusUpdated, h.statusUpdated)
+	c.HandlePaymentEvent(paymentevent.EventTypeCancelled, h.statusUpdated)
+	c.HandlePaymentEvent(paymentevent.EventTypeRefunded, h.refunded)
+	c.HandlePaymentEvent(paymentevent.EventTypeRefundFailed, h.refundFailed)
+}
+
+type reservationHandlers struct {
+	logger  *zap.Logger
+	updater ReservationUpdater
+}
+
+func (h *reservationHandlers) statusUpdated(ctx context.Context, msg *consumer.Message, event paymentevent.Event) consumer.Result {
+	logger := h.logger.With(
+		zap.String("reservation_id", event.ReservationID),
+		zap.String("payment_id", event.PaymentID),
+		zap.String("status", string(event.Status)))
+
+	var err error
+	switch event.Status.Outcome() {
+	case paymentevent.OutcomeApproved:
+		logger.Info("Payment approved - updating reservation to CONFIRMED")
+		err = h.updater.Confirm(ctx, event)
+
+	case paymentevent.OutcomeFailed:
+		logger.Info("Payment failed - updating reservation to PAYMENT_FAILED",
+			zap.String("failure_code", event.FailureCode))
+		err = h.updater.MarkPaymentFailed(ctx, event)
+
+	case paymentevent.OutcomeInProgress:
+		// 중간 상태 (스크립트 시나리오의 PROCESSING webhook, 수동 매입 승인 등)
+		logger.Info("Payment in progress - reservation unchanged")
+
+	case paymentevent.OutcomeRefunded:
+		// 예약 환불은 payment.refunded 이벤트에서 처리한다
+		logger.Info("Payment refunded - reservation updated by the refund event")
+
+	default:
+		logger.Warn("Unknown payment status received")
+	}
+
+	if err != nil {
+		return consumer.Nack(err)
+	}
+	return consumer.Ack()
+}
+
+// 환불 이벤트의 status는 환불 후 결제 상태이므로 결제 결과로 처리하지 않는다
+func (h *reservationHandlers) refunded(ctx context.Context, msg *consumer.Message, event paymentevent.Event) consumer.Result {
+	h.logger.Info("Refund event received",
+		zap.String("reservation_id", event.ReservationID),
+		zap.String("payment_id", event.PaymentID),
+		zap.String("refund_id", event.RefundID),
+		zap.Int64("amount", event.Amount))
+
+	if err := h.updater.Refund(ctx, event); err != nil {
+		return consumer.Nack(err)
+	}
+	return consumer.Ack()
+}
+
+func (h *reservationHandlers) refundFailed(ctx context.Context, msg *consumer.Message, event paymentevent.Event) consumer.Result {
+	h.logger.Warn("Refund failed, reservation unchanged",
+		zap.String("reservation_id", event.ReservationID),
+		zap.String("payment_id", event.PaymentID),
+		zap.String("refund_id", event.RefundID),
+		zap.String("failure_reason", event.FailureReason))
+	return consumer.Ack()
+}
+
+// logReservationUpdater only logs (설계 발표용). 실제로는 reservation DB 업데이트,
+// 예: reservationService.UpdateStatus(event.ReservationID, "CONFIRMED")
+type logReservationUpdater struct {
+	logger *zap.Logger
+}
+
+func (u *logReservationUpdater) Confirm(ctx context.Context, event paymentevent.Event) error {
+	u.logger.Info("Reservation CONFIRMED", zap.String("reservation_id", event.ReservationID))
+	return nil
+}
+
+func (u *logReservationUpdater) MarkPaymentFailed(ctx context.Context, event paymentevent.Event) error {
+	u.logger.Info("Reservation PAYMENT_FAILED", zap.String("reservation_id", event.ReservationID))
+	return nil
+}
+
+func (u *logReservationUpdater) Refund(ctx context.Context, event paymentevent.Event) error {
+	u.logger.Info("Reservation refunded", zap.String("reservation_id", event.ReservationID))
+	return nil
+}
@@ -0,0 +1,223 @@
+package admin
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"go.uber.org/zap"
+
+	"github.com/traffic-tacos/payment-sim-api/internal/scenario"
+	"github.com/traffic-tacos/payment-sim-api/internal/testvalues"
+	"github.com/traffic-tacos/payment-sim-api/internal/webhook"
+)
+
+// maxBodyBytes는 admin 요청 본문의 최대 크기다.
+const maxBodyBytes = 1 << 20
+
+// Handler는 metrics/health 포트(8031)에서 운영용 endpoint를 제공한다. 실행 중인
+// 설정을 바꾸는 endpoint에는 admin token이 필요하다.
+type Handler struct {
+	logger          *zap.Logger
+	token           string
+	scenarios       *scenario.Registry
+	testValues      *testvalues.Engine
+	defaultScenario scenario.Mix
+	dispatcher      *webhook.Dispatcher
+	secrets         *webhook.Secrets
+}
+
+// NewHandler는 admin handler를 반환한다. token이 비어 있으면 token이 필요한
+// endpoint는 꺼진다.
+func NewHandler(logger *zap.Logger, token string, scenarios *scenario.Registry, testValues *testvalues.Engine, defaultScenario scenario.Mix, dispatcher *webhook.Dispatcher, secrets *webhook.Secrets) *Handler {
+	return &Handler{
+		logger:          logger,
+		token:           token,
+		scenarios:       scenarios,
+		testValues:      testValues,
+		defaultScenario: defaultScenario,
+		dispatcher:      dispatcher,
+		secrets:         secrets,
+	}
+}
+
+func (h *Handler) Register(mux *http.ServeMux) {
+	mux.HandleFunc("GET /admin/scenarios", h.listScenarios)
+	mux.HandleFunc("POST /admin/scenarios", h.authorized(h.loadScenarios))
+	mux.HandleFunc("GET /admin/default-scenario", h.getDefaultScenario)
+	mux.HandleFunc("GET /admin/test-values", h.listTestValues)
+	mux.HandleFunc("PUT /admin/test-values", h.authorized(h.replaceTestValues))
+	mux.HandleFunc("GET /admin/payments/{payment_id}/deliveries", h.listDeliveries)
+	mux.HandleFunc("POST /admin/webhooks/redrive", h.authorized(h.redrive))
+	mux.HandleFunc("PUT /admin/webhooks/secrets", h.authorized(h.replaceSecrets))
+}
+
+// authorized는 "Authorization: Bearer <ADMIN_TOKEN>"을 요구한다. token이 설정되지
+// 않았으면 endpoint를 열어 두지 않고 끈다.
+func (h *Handler) authorized(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if h.token == "" {
+			writeError(w, http.StatusForbidden, fmt.Errorf("admin token is not configured (ADMIN_TOKEN)"))
+			return
+		}
+		token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(token), []byte(h.token)) != 1 {
+			h.logger.Warn("Rejected unauthorized admin request",
+				zap.String("method", r.Method),
+				zap.String("path", r.URL.Path),
+				zap.String("remote_addr", r.RemoteAddr))
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			writeError(w, http.StatusUnauthorized, fmt.Errorf("invalid admin token"))
+			return
+		}
+		next(w, r)
+	}
+}
+
+// replaceSecrets는 merchant별/URL별 서명 secret을 YAML 또는 JSON 본문으로
+// 교체한다. secret은 응답에 담지 않는다.
+func (h *Handler) replaceSecrets(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxBodyBytes))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	cfg, err := webhook.ParseSecretConfig(body)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	h.secrets.Replace(cfg)
+
+	h.logger.Info("Webhook secrets replaced via admin API",
+		zap.Int("merchants", len(cfg.Merchants)),
+		zap.Int("urls", len(cfg.URLs)))
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"merchants": len(cfg.Merchants),
+		"urls":      len(cfg.URLs),
+	})
+}
+
+// redrive는 DLQ 항목을 dispatcher로 다시 처리한다 (?max=N, 기본 10).
+func (h *Handler) redrive(w http.ResponseWriter, r *http.Request) {
+	max := 10
+	if v := r.URL.Query().Get("max"); v != "" {
+		parsed, err := strconv.Atoi(v)
+		if err != nil || parsed <= 0 {
+			writeError(w, http.StatusBadRequest, fmt.Errorf("invalid max: %q", v))
+			return
+		}
+		max = parsed
+	}
+
+	result, err := h.dispatcher.Redrive(r.Context(), max)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]interface{}{
+			"error":  err.Error(),
+			"result": result,
+		})
+		return
+	}
+	writeJSON(w, http.StatusOK, result)
+}
+
+// listDeliveries는 결제 intent의 모든 webhook 전송 기록(시도별 기록 포함)을
+// 반환한다.
+func (h *Handler) listDeliveries(w http.ResponseWriter, r *http.Request) {
+	paymentID := r.PathValue("payment_id")
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"payment_id": paymentID,
+		"deliveries": h.dispatcher.Deliveries(paymentID),
+	})
+}
+
+func (h *Handler) listScenarios(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"scenarios": h.scenarios.List(),
+	})
+}
+
+// loadScenarios는 YAML 또는 JSON 본문의 시나리오 정의를 등록하며, 같은 이름의
+// 정의는 교체한다. 잘못된 정의나 결제 상태 머신이 거부할 스크립트는 400으로
+// 응답한다.
+func (h *Handler) loadScenarios(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxBodyBytes))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	defs, err := h.scenarios.Load(body)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	names := make([]string, 0, len(defs))
+	for _, def := range defs {
+		names = append(names, def.Name)
+	}
+	h.logger.Info("Scenarios loaded via admin API", zap.Strings("scenarios", names))
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"loaded": names,
+	})
+}
+
+// getDefaultScenario는 PAYMENT_SCENARIO_UNSPECIFIED 요청에 적용되는
+// DEFAULT_SCENARIO를 알려준다.
+func (h *Handler) getDefaultScenario(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"default_scenario": h.defaultScenario.String(),
+		"weights":          h.defaultScenario,
+	})
+}
+
+// listTestValues는 테스트 값 규칙을 매칭 순서대로 반환한다. 내장 거절 테스트
+// 카드가 마지막이다.
+func (h *Handler) listTestValues(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"rules": h.testValues.Rules(),
+	})
+}
+
+// replaceTestValues는 설정된 테스트 값 규칙을 YAML 또는 JSON 본문으로
+// 교체한다. 내장 거절 테스트 카드는 유지한다.
+func (h *Handler) replaceTestValues(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxBodyBytes))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	rules, err := testvalues.Parse(body)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	h.testValues.Replace(rules)
+
+	h.logger.Info("Test value rules replaced via admin API", zap.Int("count", len(rules)))
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"rules": len(rules),
+	})
+}
+
+func writeJSON(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, map[string]interface{}{
+		"error": err.Error(),
+	})
+}
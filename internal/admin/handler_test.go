@@ -0,0 +1,111 @@
+package admin
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"go.uber.org/zap"
+
+	"github.com/traffic-tacos/payment-sim-api/internal/config"
+	"github.com/traffic-tacos/payment-sim-api/internal/scenario"
+	"github.com/traffic-tacos/payment-sim-api/internal/service"
+	"github.com/traffic-tacos/payment-sim-api/internal/testvalues"
+	"github.com/traffic-tacos/payment-sim-api/internal/webhook"
+)
+
+func newTestMux(token string) (*http.ServeMux, *webhook.Secrets) {
+	secrets := webhook.NewSecrets("default-secret")
+	dispatcher := webhook.NewDispatcher(zap.NewNop(), &config.Config{}, nil, nil, secrets)
+	h := NewHandler(zap.NewNop(), token, scenario.NewRegistry(service.NewStateMachine().CanTransitionName), testvalues.NewEngine(), scenario.Mix{}, dispatcher, secrets)
+	mux := http.NewServeMux()
+	h.Register(mux)
+	return mux, secrets
+}
+
+// adminWrites are the endpoints guarded by ADMIN_TOKEN, with a valid body
+// and the status the handler answers once the token is accepted.
+var adminWrites = []struct {
+	method, path, body string
+	authorized         int
+}{
+	{http.MethodPut, "/admin/webhooks/secrets", "merchants:\n  m-1: [merchant-secret]\n", http.StatusOK},
+	{http.MethodPost, "/admin/scenarios", "name: slow\nsteps:\n  - status: PROCESSING\n  - status: COMPLETED\n", http.StatusOK},
+	{http.MethodPut, "/admin/test-values", "rules:\n  - name: r\n    match:\n      amount_suffix: \"13\"\n    decline_code: INSUFFICIENT_FUNDS\n", http.StatusOK},
+	// 테스트 dispatcher에는 DLQ가 없으므로 인증을 통과하면 500
+	{http.MethodPost, "/admin/webhooks/redrive?max=1", "", http.StatusInternalServerError},
+}
+
+func TestAdminWritesRequireToken(t *testing.T) {
+	tests := []struct {
+		name          string
+		token         string // ADMIN_TOKEN
+		authorization string
+		want          int // 0 = the endpoint's authorized status
+	}{
+		{name: "token not configured", token: "", authorization: "Bearer anything", want: http.StatusForbidden},
+		{name: "missing header", token: "s3cret", want: http.StatusUnauthorized},
+		{name: "wrong token", token: "s3cret", authorization: "Bearer s3cre", want: http.StatusUnauthorized},
+		{name: "wrong scheme", token: "s3cret", authorization: "Basic s3cret", want: http.StatusUnauthorized},
+		{name: "valid token", token: "s3cret", authorization: "Bearer s3cret"},
+	}
+
+	for _, endpoint := range adminWrites {
+		for _, tt := range tests {
+			t.Run(endpoint.method+" "+endpoint.path+"/"+tt.name, func(t *testing.T) {
+				mux, _ := newTestMux(tt.token)
+
+				req := httptest.NewRequest(endpoint.method, endpoint.path, strings.NewReader(endpoint.body))
+				if tt.authorization != "" {
+					req.Header.Set("Authorization", tt.authorization)
+				}
+				rec := httptest.NewRecorder()
+				mux.ServeHTTP(rec, req)
+
+				want := tt.want
+				if want == 0 {
+					want = endpoint.authorized
+				}
+				if rec.Code != want {
+					t.Fatalf("status = %d, want %d (body %s)", rec.Code, want, rec.Body)
+				}
+			})
+		}
+	}
+}
+
+func TestAdminRejectedWriteHasNoEffect(t *testing.T) {
+	mux, secrets := newTestMux("s3cret")
+
+	req := httptest.NewRequest(http.MethodPut, "/admin/webhooks/secrets", strings.NewReader("merchants:\n  m-1: [merchant-secret]\n"))
+	req.Header.Set("Authorization", "Bearer wrong")
+	mux.ServeHTTP(httptest.NewRecorder(), req)
+
+	if got := secrets.For("m-1", ""); len(got) != 1 || got[0] != "default-secret" {
+		t.Errorf("secrets after rejected request = %v, want the defaults", got)
+	}
+}
+
+func TestLoadScenariosRejectsIllegalTransition(t *testing.T) {
+	mux, _ := newTestMux("s3cret")
+
+	req := httptest.NewRequest(http.MethodPost, "/admin/scenarios", strings.NewReader("name: skip\nsteps:\n  - status: PENDING\n  - status: COMPLETED\n"))
+	req.Header.Set("Authorization", "Bearer s3cret")
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusBadRequest || !strings.Contains(rec.Body.String(), "invalid transition") {
+		t.Errorf("POST /admin/scenarios = %d %s, want 400 invalid transition", rec.Code, rec.Body)
+	}
+}
+
+func TestAdminReadsStayOpen(t *testing.T) {
+	mux, _ := newTestMux("s3cret")
+
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/admin/scenarios", nil))
+	if rec.Code != http.StatusOK {
+		t.Errorf("GET /admin/scenarios status = %d, want 200", rec.Code)
+	}
+}
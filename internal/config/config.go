@@ -4,6 +4,9 @@ type Config struct {
 	Environment string `envconfig:"ENVIRONMENT" default:"development"`
 	GRPCPort    int    `envconfig:"GRPC_PORT" default:"8030"`
 
+	// 8031 admin API의 변경 요청(POST/PUT)에 필요한 Bearer 토큰 (비우면 변경 요청은 모두 거절)
+	AdminToken string `envconfig:"ADMIN_TOKEN"`
+
 	// AWS Configuration
 	AWSRegion      string `envconfig:"AWS_REGION" default:"ap-northeast-2"`
 	AWSProfile     string `envconfig:"AWS_PROFILE" default:"tacos"`
@@ -16,8 +19,84 @@ type Config struct {
 
 	// Webhook configuration (실제 PG사 시뮬레이션용)
 	WebhookSecret string `envconfig:"WEBHOOK_SECRET" default:"payment-sim-secret"`
+	// 키 rotation 중 함께 서명할 추가 secret (쉼표 구분), merchant/URL별 secret 파일 (YAML/JSON)
+	WebhookSecrets     []string `envconfig:"WEBHOOK_SECRETS"`
+	WebhookSecretsFile string   `envconfig:"WEBHOOK_SECRETS_FILE"`
+
+	// Webhook 재시도 (지수 백오프 + jitter)
+	WebhookTimeoutMs       int     `envconfig:"WEBHOOK_TIMEOUT_MS" default:"30000"`
+	WebhookMaxAttempts     int     `envconfig:"WEBHOOK_MAX_ATTEMPTS" default:"5"`
+	WebhookRetryBaseMs     int     `envconfig:"WEBHOOK_RETRY_BASE_MS" default:"1000"`
+	WebhookRetryMaxMs      int     `envconfig:"WEBHOOK_RETRY_MAX_MS" default:"30000"`
+	WebhookRetryJitter     float64 `envconfig:"WEBHOOK_RETRY_JITTER" default:"0.2"`
+	WebhookDeliveryLogSize int     `envconfig:"WEBHOOK_DELIVERY_LOG_SIZE" default:"10000"` // 보관할 최근 payment 수
+
+	// Webhook dispatch worker pool (지연 큐 + 고정 worker 수)
+	WebhookWorkers    int `envconfig:"WEBHOOK_WORKERS" default:"64"`
+	WebhookQueueDepth int `envconfig:"WEBHOOK_QUEUE_DEPTH" default:"100000"` // 초과 시 신규 결제 거절
+
+	// 종료 시 남은 webhook 작업을 저장해 다음 프로세스가 이어서 처리 (비우면 즉시 flush 후 나머지는 drop 보고)
+	WebhookPendingPath       string `envconfig:"WEBHOOK_PENDING_PATH"`
+	WebhookShutdownTimeoutMs int    `envconfig:"WEBHOOK_SHUTDOWN_TIMEOUT_MS" default:"20000"`
+
+	// reservation-worker SQS consumer (pkg/consumer)
+	WorkerPollers             int `envconfig:"WORKER_POLLERS" default:"2"`                   // 동시 ReceiveMessage long polling 수
+	WorkerConcurrency         int `envconfig:"WORKER_CONCURRENCY" default:"20"`              // 동시 handler goroutine 수
+	WorkerVisibilityTimeoutMs int `envconfig:"WORKER_VISIBILITY_TIMEOUT_MS" default:"60000"` // handler 실행 중에는 자동 연장
+	WorkerMaxAttempts         int `envconfig:"WORKER_MAX_ATTEMPTS" default:"3"`              // 수신 1회당 handler 시도 횟수
+	WorkerRetryBaseMs         int `envconfig:"WORKER_RETRY_BASE_MS" default:"200"`
+	WorkerRetryMaxMs          int `envconfig:"WORKER_RETRY_MAX_MS" default:"5000"`
+	WorkerRequeueDelayMs      int `envconfig:"WORKER_REQUEUE_DELAY_MS" default:"30000"`      // 재시도 소진 후 큐에 돌려보낼 때 다시 보이기까지
+	WorkerRequeueMaxDelayMs   int `envconfig:"WORKER_REQUEUE_MAX_DELAY_MS" default:"900000"` // ApproximateReceiveCount마다 2배, 상한
+	WorkerMaxReceives         int `envconfig:"WORKER_MAX_RECEIVES" default:"0"`              // 0이면 큐의 redrive policy에 맡김
+	// 파싱/스키마 검증 실패 메시지: redrive (큐에 남겨 SQS redrive policy로 DLQ 이동) | dlq (WORKER_DLQ_URL로 오류와 함께 전달)
+	WorkerPoisonPolicy string `envconfig:"WORKER_POISON_POLICY" default:"redrive"`
+	// worker 전용 DLQ. /admin/webhooks/redrive가 EventBridge로 재발행하지 않도록 PAYMENT_WEBHOOK_DLQ_URL과 분리한다
+	WorkerDLQURL string `envconfig:"WORKER_DLQ_URL"`
+
+	// reservation-worker 중복 이벤트 제거: memory (LRU) | file (재시작 후에도 유지) | none
+	WorkerDedupStore string `envconfig:"WORKER_DEDUP_STORE" default:"memory"`
+	WorkerDedupPath  string `envconfig:"WORKER_DEDUP_PATH" default:"data/worker-dedup.log"`
+	WorkerDedupKey   string `envconfig:"WORKER_DEDUP_KEY" default:"payment_status"` // payment_status (payment ID + 상태) | event_id (EventBridge event ID)
+	WorkerDedupTTLMs int    `envconfig:"WORKER_DEDUP_TTL_MS" default:"86400000"`
+	WorkerDedupSize  int    `envconfig:"WORKER_DEDUP_SIZE" default:"100000"` // LRU 최대 key 수
+
+	// Payment intent store: "memory" or "file" (append-only log, survives restarts)
+	IntentStore     string `envconfig:"INTENT_STORE" default:"memory"`
+	IntentStorePath string `envconfig:"INTENT_STORE_PATH" default:"data/intents.log"`
+	// 로그가 줄 수 또는 크기 기준을 넘고 절반 이상이 이전 snapshot이면 실행 중에도 compaction (0이면 해당 기준 끔)
+	IntentStoreCompactRecords int   `envconfig:"INTENT_STORE_COMPACT_RECORDS" default:"100000"`
+	IntentStoreCompactBytes   int64 `envconfig:"INTENT_STORE_COMPACT_BYTES" default:"67108864"`
+
+	// CreatePaymentIntent Idempotency-Key 보관 기간 (기본 24시간)
+	IdempotencyTTLMs int `envconfig:"IDEMPOTENCY_TTL_MS" default:"86400000"`
+
+	// 같은 예약에 활성 intent가 이미 있을 때: reject | return_existing | allow_and_flag
+	ReservationIntentPolicy string `envconfig:"RESERVATION_INTENT_POLICY" default:"reject"`
 
 	// Simulation settings
-	DefaultDelayMs   int    `envconfig:"DEFAULT_DELAY_MS" default:"2000"`
-	DefaultScenario  string `envconfig:"DEFAULT_SCENARIO" default:"approve"`
+	DefaultDelayMs  int    `envconfig:"DEFAULT_DELAY_MS" default:"2000"`
+	DefaultScenario string `envconfig:"DEFAULT_SCENARIO" default:"approve"` // UNSPECIFIED 요청: approve|fail|delay|random 또는 가중치 mix (approve:90,fail:8,delay:2)
+
+	// PAYMENT_SCENARIO_RANDOM 재현 설정 (RANDOM_SEED=0이면 시작 시각으로 시드)
+	RandomSeed                int64   `envconfig:"RANDOM_SEED" default:"0"`
+	RandomSeedFromReservation bool    `envconfig:"RANDOM_SEED_FROM_RESERVATION" default:"false"`
+	RandomApprovalRatio       float64 `envconfig:"RANDOM_APPROVAL_RATIO" default:"0.5"`
+
+	// 스크립트 시나리오 정의 파일 (YAML/JSON), metadata.tags["scenario"]로 선택
+	ScenarioFile string `envconfig:"SCENARIO_FILE"`
+
+	// 매직 테스트 값 규칙 파일 (YAML/JSON), 금액/사용자/예약 ID/테스트 카드로 결과 선택
+	TestValuesFile string `envconfig:"TEST_VALUES_FILE"`
+
+	// PAYMENT_SCENARIO_DELAY 설정 (metadata.tags의 delay_* 값으로 intent별 override 가능)
+	DelayScenarioMs      int    `envconfig:"DELAY_SCENARIO_MS" default:"10000"`
+	DelayDistribution    string `envconfig:"DELAY_DISTRIBUTION" default:"fixed"` // fixed|uniform|normal|longtail
+	DelayMaxMs           int    `envconfig:"DELAY_MAX_MS" default:"30000"`
+	DelayStdDevMs        int    `envconfig:"DELAY_STDDEV_MS" default:"2000"`
+	DelayExceedHoldTTL   bool   `envconfig:"DELAY_EXCEED_HOLD_TTL" default:"false"`
+	ReservationHoldTTLMs int    `envconfig:"RESERVATION_HOLD_TTL_MS" default:"60000"`
+
+	// capture_method=manual 승인의 유효 기간 (지나면 EXPIRED, metadata.tags["authorization_ttl_ms"]로 override)
+	AuthorizationTTLMs int `envconfig:"AUTHORIZATION_TTL_MS" default:"600000"`
 }
\ No newline at end of file
@@ -0,0 +1,102 @@
+// Package decline은 시뮬레이션하는 PG 거절 사유 카탈로그다. 실패한 결제는
+// GetPaymentStatus, webhook payload, EventBridge 이벤트에 이 코드 중 하나를
+// 담으므로 호출자는 사용자 안내 메시지를 시험해 볼 수 있다.
+package decline
+
+import "sort"
+
+// 거절 코드
+const (
+	Generic           = "GENERIC_DECLINE"
+	InsufficientFunds = "INSUFFICIENT_FUNDS"
+	CardExpired       = "CARD_EXPIRED"
+	FraudSuspected    = "FRAUD_SUSPECTED"
+	IssuerUnavailable = "ISSUER_UNAVAILABLE"
+	ThreeDSFailed     = "THREE_DS_FAILED"
+	LimitExceeded     = "LIMIT_EXCEEDED"
+)
+
+// 환불 실패 코드. 카드 거절이 아니므로 테스트 카드가 없고 Lookup이나 All에도
+// 나오지 않는다.
+const (
+	RefundDeclined = "REFUND_DECLINED"
+	// RefundScheduleFailed는 시뮬레이터가 환불을 큐에 넣지 못했을 때
+	// 보고한다 (예: webhook 큐가 가득 참).
+	RefundScheduleFailed = "REFUND_SCHEDULE_FAILED"
+)
+
+// Reason은 PG가 보고하는 형태로 거절을 설명한다.
+type Reason struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+	// ResponseCode는 ISO 8583 형식의 카드사 응답 코드다.
+	ResponseCode int32 `json:"response_code"`
+	// Retryable은 같은 카드로 나중에 다시 시도하면 성공할 수 있는지 나타낸다.
+	Retryable bool `json:"retryable"`
+	// TestCard는 항상 이 사유로 거절된다 (Stripe 테스트 카드처럼,
+	// internal/testvalues 참고).
+	TestCard string `json:"test_card"`
+}
+
+var reasons = map[string]Reason{
+	Generic: {
+		Code: Generic, Message: "The card was declined.",
+		ResponseCode: 5, TestCard: "4000000000000002",
+	},
+	InsufficientFunds: {
+		Code: InsufficientFunds, Message: "The card has insufficient funds.",
+		ResponseCode: 51, TestCard: "4000000000009995",
+	},
+	CardExpired: {
+		Code: CardExpired, Message: "The card has expired.",
+		ResponseCode: 54, TestCard: "4000000000000069",
+	},
+	FraudSuspected: {
+		Code: FraudSuspected, Message: "The payment was blocked as suspected fraud.",
+		ResponseCode: 59, TestCard: "4100000000000019",
+	},
+	IssuerUnavailable: {
+		Code: IssuerUnavailable, Message: "The card issuer could not be reached.",
+		ResponseCode: 91, Retryable: true, TestCard: "4000000000000119",
+	},
+	ThreeDSFailed: {
+		Code: ThreeDSFailed, Message: "3D Secure authentication failed.",
+		ResponseCode: 65, Retryable: true, TestCard: "4000000000003220",
+	},
+	LimitExceeded: {
+		Code: LimitExceeded, Message: "The card's spending limit was exceeded.",
+		ResponseCode: 61, Retryable: true, TestCard: "4000000000006975",
+	},
+}
+
+// refundMessages는 환불 코드의 메시지다.
+var refundMessages = map[string]string{
+	RefundDeclined:       "The refund was declined.",
+	RefundScheduleFailed: "The refund could not be processed. Try again later.",
+}
+
+// Lookup은 code의 카탈로그 항목을 반환한다.
+func Lookup(code string) (Reason, bool) {
+	r, ok := reasons[code]
+	return r, ok
+}
+
+// Message는 거절 또는 환불 코드의 메시지를 반환하며, 모르는 코드면 ""다.
+func Message(code string) string {
+	if r, ok := reasons[code]; ok {
+		return r.Message
+	}
+	return refundMessages[code]
+}
+
+// All은 코드 순으로 정렬한 카탈로그를 반환한다.
+func All() []Reason {
+	all := make([]Reason, 0, len(reasons))
+	for _, r := range reasons {
+		all = append(all, r)
+	}
+	sort.Slice(all, func(i, j int) bool {
+		return all[i].Code < all[j].Code
+	})
+	return all
+}
@@ -3,6 +3,7 @@ package events
 import (
 	"context"
 	"encoding/json"
+	"fmt"
 	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
@@ -11,19 +12,9 @@ import (
 	"go.uber.org/zap"
 
 	"github.com/traffic-tacos/payment-sim-api/internal/config"
+	"github.com/traffic-tacos/payment-sim-api/pkg/paymentevent"
 )
 
-type PaymentEvent struct {
-	PaymentID     string `json:"payment_id"`
-	ReservationID string `json:"reservation_id"`
-	UserID        string `json:"user_id"`
-	Status        string `json:"status"`
-	Amount        int64  `json:"amount"`
-	Currency      string `json:"currency"`
-	Timestamp     int64  `json:"timestamp"`
-	EventType     string `json:"event_type"`
-}
-
 type Publisher struct {
 	eventBridge *eventbridge.Client
 	config      *config.Config
@@ -38,20 +29,33 @@ func NewPublisher(eventBridge *eventbridge.Client, config *config.Config, logger
 	}
 }
 
-func (p *Publisher) PublishPaymentEvent(ctx context.Context, event PaymentEvent) error {
+// PublishPaymentEvent publishes event with the current schema version. Events
+// that do not validate against the paymentevent schema are rejected.
+func (p *Publisher) PublishPaymentEvent(ctx context.Context, event paymentevent.Event) error {
+	event.SchemaVersion = paymentevent.SchemaVersion
 	event.Timestamp = time.Now().Unix()
-	event.EventType = "payment.status_updated"
+	if event.EventType == "" {
+		event.EventType = paymentevent.EventTypeStatusUpdated
+	}
+	detailType, ok := event.EventType.DetailType()
+	if !ok {
+		return fmt.Errorf("unknown payment event type: %s", event.EventType)
+	}
 
 	detail, err := json.Marshal(event)
 	if err != nil {
 		p.logger.Error("Failed to marshal payment event", zap.Error(err))
 		return err
 	}
+	if err := paymentevent.Validate(detail); err != nil {
+		p.logger.Error("Payment event does not match schema", zap.Error(err))
+		return err
+	}
 
 	entry := types.PutEventsRequestEntry{
-		Source:     aws.String(p.config.EventSource),
-		DetailType: aws.String("Payment Status Updated"),
-		Detail:     aws.String(string(detail)),
+		Source:       aws.String(p.config.EventSource),
+		DetailType:   aws.String(detailType),
+		Detail:       aws.String(string(detail)),
 		EventBusName: aws.String(p.config.EventBusName),
 	}
 
@@ -61,7 +65,8 @@ func (p *Publisher) PublishPaymentEvent(ctx context.Context, event PaymentEvent)
 
 	p.logger.Info("Publishing payment event to EventBridge",
 		zap.String("payment_id", event.PaymentID),
-		zap.String("status", event.Status),
+		zap.String("status", string(event.Status)),
+		zap.String("event_type", string(event.EventType)),
 		zap.String("event_bus", p.config.EventBusName))
 
 	result, err := p.eventBridge.PutEvents(ctx, input)
@@ -75,7 +80,9 @@ func (p *Publisher) PublishPaymentEvent(ctx context.Context, event PaymentEvent)
 		p.logger.Error("EventBridge entry failed",
 			zap.String("error_code", *result.Entries[0].ErrorCode),
 			zap.String("error_message", aws.ToString(result.Entries[0].ErrorMessage)))
-		return err
+		return fmt.Errorf("eventbridge entry failed: %s: %s",
+			aws.ToString(result.Entries[0].ErrorCode),
+			aws.ToString(result.Entries[0].ErrorMessage))
 	}
 
 	p.logger.Info("Payment event published successfully",
@@ -0,0 +1,96 @@
+package server
+
+import (
+	"context"
+
+	"go.uber.org/zap"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/protoadapt"
+
+	"github.com/traffic-tacos/payment-sim-api/internal/service"
+)
+
+// errorDomain은 이 서비스가 반환하는 모든 에러의 ErrorInfo domain이다.
+const errorDomain = "payment-sim-api"
+
+var kindCodes = map[service.Kind]codes.Code{
+	service.KindInternal:           codes.Internal,
+	service.KindInvalidArgument:    codes.InvalidArgument,
+	service.KindNotFound:           codes.NotFound,
+	service.KindAlreadyExists:      codes.AlreadyExists,
+	service.KindFailedPrecondition: codes.FailedPrecondition,
+	service.KindAborted:            codes.Aborted,
+	service.KindResourceExhausted:  codes.ResourceExhausted,
+	service.KindUnavailable:        codes.Unavailable,
+	service.KindCanceled:           codes.Canceled,
+	service.KindDeadlineExceeded:   codes.DeadlineExceeded,
+}
+
+// UnaryErrorInterceptor는 service 에러를, reason code를 담은
+// errdetails.ErrorInfo가 붙은 gRPC status 에러로 바꾼다.
+func UnaryErrorInterceptor(logger *zap.Logger) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		resp, err := handler(ctx, req)
+		if err == nil {
+			return resp, nil
+		}
+
+		st := toStatus(err)
+		if st.Code() == codes.Internal {
+			logger.Error("gRPC request failed",
+				zap.String("method", info.FullMethod),
+				zap.Error(err))
+		} else {
+			logger.Info("gRPC request rejected",
+				zap.String("method", info.FullMethod),
+				zap.String("code", st.Code().String()),
+				zap.Error(err))
+		}
+		return resp, st.Err()
+	}
+}
+
+// toStatus는 err를 gRPC status로 바꾼다. 이미 gRPC status인 에러는 그대로
+// 통과시킨다.
+func toStatus(err error) *status.Status {
+	if st, ok := status.FromError(err); ok {
+		return st
+	}
+
+	class := service.Classify(err)
+	code, ok := kindCodes[class.Kind]
+	if !ok {
+		code = codes.Internal
+	}
+
+	details := []protoadapt.MessageV1{&errdetails.ErrorInfo{
+		Reason:   class.Reason,
+		Domain:   errorDomain,
+		Metadata: class.Metadata,
+	}}
+	if len(class.Violations) > 0 {
+		badRequest := &errdetails.BadRequest{}
+		for _, v := range class.Violations {
+			badRequest.FieldViolations = append(badRequest.FieldViolations, &errdetails.BadRequest_FieldViolation{
+				Field:       v.Field,
+				Description: v.Description,
+			})
+		}
+		details = append(details, badRequest)
+	}
+
+	// 내부/일시 장애 에러의 원문(store 경로, AWS 에러 등)은 로그에만 남긴다
+	message := err.Error()
+	if class.Kind == service.KindInternal || class.Kind == service.KindUnavailable {
+		message = class.Message
+	}
+	st := status.New(code, message)
+	withDetails, detailErr := st.WithDetails(details...)
+	if detailErr != nil {
+		return st
+	}
+	return withDetails
+}
@@ -0,0 +1,178 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"testing"
+
+	"go.uber.org/zap"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	paymentv1 "github.com/traffic-tacos/proto-contracts/gen/go/payment/v1"
+	"github.com/traffic-tacos/payment-sim-api/internal/service"
+	"github.com/traffic-tacos/payment-sim-api/internal/webhook"
+)
+
+func TestUnaryErrorInterceptor(t *testing.T) {
+	tests := []struct {
+		name         string
+		err          error
+		wantCode     codes.Code
+		wantReason   string
+		wantMessage  string // 비어 있으면 전체 에러
+		wantMetadata map[string]string
+		// wantFields는 BadRequest의 필드 위반 사유 (순서대로)
+		wantFields []string
+	}{
+		{
+			name:       "intent not found",
+			err:        fmt.Errorf("%w: pay-1", service.ErrIntentNotFound),
+			wantCode:   codes.NotFound,
+			wantReason: "PAYMENT_INTENT_NOT_FOUND",
+		},
+		{
+			name: "invalid transition",
+			err: fmt.Errorf("capture: %w", &service.TransitionError{
+				PaymentID: "pay-1",
+				From:      paymentv1.PaymentStatus_PAYMENT_STATUS_FAILED,
+				To:        paymentv1.PaymentStatus_PAYMENT_STATUS_COMPLETED,
+			}),
+			wantCode:   codes.FailedPrecondition,
+			wantReason: "INVALID_STATUS_TRANSITION",
+			wantMetadata: map[string]string{
+				"payment_intent_id": "pay-1",
+				"from":              "PAYMENT_STATUS_FAILED",
+				"to":                "PAYMENT_STATUS_COMPLETED",
+			},
+		},
+		{
+			name:       "intent final",
+			err:        fmt.Errorf("%w: pay-1 is PAYMENT_STATUS_FAILED", service.ErrIntentFinal),
+			wantCode:   codes.FailedPrecondition,
+			wantReason: "PAYMENT_INTENT_FINAL",
+		},
+		{
+			name: "validation",
+			err: &service.ValidationError{Violations: []service.FieldViolation{
+				{Field: "reservation_id", Description: "is required"},
+				{Field: "amount.currency", Description: "unknown currency"},
+			}},
+			wantCode:   codes.InvalidArgument,
+			wantReason: "INVALID_REQUEST",
+			wantFields: []string{"reservation_id: is required", "amount.currency: unknown currency"},
+		},
+		{
+			name:       "dispatch queue full",
+			err:        fmt.Errorf("failed to schedule payment intent: %w", webhook.ErrQueueFull),
+			wantCode:   codes.ResourceExhausted,
+			wantReason: "DISPATCH_QUEUE_FULL",
+		},
+		{
+			name:        "shutting down",
+			err:         fmt.Errorf("failed to schedule payment intent: %w", webhook.ErrDispatcherClosed),
+			wantCode:    codes.Unavailable,
+			wantReason:  "SHUTTING_DOWN",
+			wantMessage: "webhook dispatcher is shutting down",
+		},
+		{
+			name:       "canceled",
+			err:        fmt.Errorf("waiting for idempotency key: %w", context.Canceled),
+			wantCode:   codes.Canceled,
+			wantReason: "CANCELED",
+		},
+		{
+			name:       "deadline exceeded",
+			err:        context.DeadlineExceeded,
+			wantCode:   codes.DeadlineExceeded,
+			wantReason: "DEADLINE_EXCEEDED",
+		},
+		{
+			name:        "unclassified",
+			err:         fmt.Errorf("failed to store payment intent: %w", errors.New("write /var/lib/payment-sim/intents.log: no space left on device")),
+			wantCode:    codes.Internal,
+			wantReason:  "INTERNAL",
+			wantMessage: "internal error",
+		},
+	}
+
+	interceptor := UnaryErrorInterceptor(zap.NewNop())
+	info := &grpc.UnaryServerInfo{FullMethod: "/payment.v1.PaymentService/Test"}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := interceptor(context.Background(), nil, info, func(ctx context.Context, req interface{}) (interface{}, error) {
+				return nil, tt.err
+			})
+
+			st, ok := status.FromError(err)
+			if !ok {
+				t.Fatalf("error %v is not a gRPC status", err)
+			}
+			if st.Code() != tt.wantCode {
+				t.Errorf("code = %s, want %s", st.Code(), tt.wantCode)
+			}
+			wantMessage := tt.wantMessage
+			if wantMessage == "" {
+				wantMessage = tt.err.Error()
+			}
+			if st.Message() != wantMessage {
+				t.Errorf("message = %q, want %q", st.Message(), wantMessage)
+			}
+
+			var (
+				errorInfo *errdetails.ErrorInfo
+				fields    []string
+			)
+			for _, detail := range st.Details() {
+				switch d := detail.(type) {
+				case *errdetails.ErrorInfo:
+					errorInfo = d
+				case *errdetails.BadRequest:
+					for _, v := range d.FieldViolations {
+						fields = append(fields, v.Field+": "+v.Description)
+					}
+				}
+			}
+			if errorInfo == nil {
+				t.Fatal("status has no ErrorInfo")
+			}
+			if errorInfo.Domain != "payment-sim-api" || errorInfo.Reason != tt.wantReason {
+				t.Errorf("ErrorInfo = %s/%s, want payment-sim-api/%s", errorInfo.Domain, errorInfo.Reason, tt.wantReason)
+			}
+			if tt.wantMetadata != nil && !maps.Equal(errorInfo.Metadata, tt.wantMetadata) {
+				t.Errorf("ErrorInfo metadata = %v, want %v", errorInfo.Metadata, tt.wantMetadata)
+			}
+			if !slices.Equal(fields, tt.wantFields) {
+				t.Errorf("field violations = %v, want %v", fields, tt.wantFields)
+			}
+		})
+	}
+}
+
+func TestUnaryErrorInterceptorPassesStatusThrough(t *testing.T) {
+	interceptor := UnaryErrorInterceptor(zap.NewNop())
+	want := status.Error(codes.PermissionDenied, "no")
+
+	_, err := interceptor(context.Background(), nil, &grpc.UnaryServerInfo{}, func(ctx context.Context, req interface{}) (interface{}, error) {
+		return nil, want
+	})
+	if st := status.Convert(err); st.Code() != codes.PermissionDenied || len(st.Details()) != 0 {
+		t.Errorf("status = %s with %d details, want the handler's PermissionDenied unchanged", st.Code(), len(st.Details()))
+	}
+}
+
+func TestUnaryErrorInterceptorKeepsResponse(t *testing.T) {
+	interceptor := UnaryErrorInterceptor(zap.NewNop())
+	resp, err := interceptor(context.Background(), nil, &grpc.UnaryServerInfo{}, func(ctx context.Context, req interface{}) (interface{}, error) {
+		return "ok", nil
+	})
+	if resp != "ok" || err != nil {
+		t.Errorf("interceptor = %v, %v, want the handler's response", resp, err)
+	}
+}
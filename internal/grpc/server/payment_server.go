@@ -42,4 +42,20 @@ func (s *PaymentServer) ProcessPayment(ctx context.Context, req *paymentv1.Proce
 		zap.String("payment_intent_id", req.PaymentIntentId))
 
 	return s.paymentService.ProcessPayment(ctx, req)
-}
\ No newline at end of file
+}
+
+func (s *PaymentServer) CancelPayment(ctx context.Context, req *paymentv1.CancelPaymentRequest) (*paymentv1.CancelPaymentResponse, error) {
+	s.logger.Info("gRPC CancelPayment called",
+		zap.String("payment_intent_id", req.PaymentIntentId),
+		zap.String("reason", req.Reason))
+
+	return s.paymentService.CancelPayment(ctx, req)
+}
+
+func (s *PaymentServer) ListPayments(ctx context.Context, req *paymentv1.ListPaymentsRequest) (*paymentv1.ListPaymentsResponse, error) {
+	s.logger.Info("gRPC ListPayments called",
+		zap.String("reservation_id", req.ReservationId),
+		zap.String("user_id", req.UserId))
+
+	return s.paymentService.ListPayments(ctx, req)
+}
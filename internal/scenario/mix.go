@@ -0,0 +1,123 @@
+package scenario
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/rand/v2"
+	"strconv"
+	"strings"
+
+	paymentv1 "github.com/traffic-tacos/proto-contracts/gen/go/payment/v1"
+)
+
+// Mix는 가중치를 둔 결제 시나리오 선택이며 "approve:90,fail:8,delay:2"처럼
+// 쓴다. 이름만 쓰면("random") 가중치는 1이다. 빈 Mix는 항상 승인한다.
+type Mix []Weighted
+
+// Weighted는 Mix의 항목 하나다.
+type Weighted struct {
+	Scenario paymentv1.PaymentScenario
+	Weight   int
+}
+
+// ParseMix는 시나리오 mix를 파싱한다. 빈 문자열은 빈 Mix다.
+func ParseMix(spec string) (Mix, error) {
+	var mix Mix
+	seen := make(map[paymentv1.PaymentScenario]bool)
+	for _, part := range strings.Split(spec, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+
+		name, weightText, hasWeight := strings.Cut(part, ":")
+		sc, err := ParseName(name)
+		if err != nil {
+			return nil, err
+		}
+		if seen[sc] {
+			return nil, fmt.Errorf("scenario %q listed twice", name)
+		}
+		seen[sc] = true
+
+		weight := 1
+		if hasWeight {
+			weight, err = strconv.Atoi(strings.TrimSpace(weightText))
+			if err != nil || weight <= 0 {
+				return nil, fmt.Errorf("scenario %q: weight must be a positive integer, got %q", name, weightText)
+			}
+		}
+		mix = append(mix, Weighted{Scenario: sc, Weight: weight})
+	}
+	return mix, nil
+}
+
+// Pick은 시나리오 하나를 뽑는다. 항목이 하나뿐이면 rng를 쓰지 않는다.
+func (m Mix) Pick(rng *rand.Rand) paymentv1.PaymentScenario {
+	switch len(m) {
+	case 0:
+		return paymentv1.PaymentScenario_PAYMENT_SCENARIO_APPROVE
+	case 1:
+		return m[0].Scenario
+	}
+
+	n := rng.IntN(m.total())
+	for _, w := range m {
+		if n < w.Weight {
+			return w.Scenario
+		}
+		n -= w.Weight
+	}
+	return m[len(m)-1].Scenario
+}
+
+func (m Mix) total() int {
+	total := 0
+	for _, w := range m {
+		total += w.Weight
+	}
+	return total
+}
+
+// String은 mix를 설정 형식으로 반환한다 (예: "approve:90,fail:10").
+func (m Mix) String() string {
+	switch len(m) {
+	case 0:
+		return ShortName(paymentv1.PaymentScenario_PAYMENT_SCENARIO_APPROVE)
+	case 1:
+		return ShortName(m[0].Scenario)
+	}
+	parts := make([]string, 0, len(m))
+	for _, w := range m {
+		parts = append(parts, ShortName(w.Scenario)+":"+strconv.Itoa(w.Weight))
+	}
+	return strings.Join(parts, ",")
+}
+
+// MarshalJSON은 시나리오마다 가중치와 비율(%)을 보고한다.
+func (m Mix) MarshalJSON() ([]byte, error) {
+	type entry struct {
+		Scenario string  `json:"scenario"`
+		Weight   int     `json:"weight"`
+		Percent  float64 `json:"percent"`
+	}
+	if len(m) == 0 {
+		m = Mix{{Scenario: paymentv1.PaymentScenario_PAYMENT_SCENARIO_APPROVE, Weight: 1}}
+	}
+
+	total := float64(m.total())
+	entries := make([]entry, 0, len(m))
+	for _, w := range m {
+		entries = append(entries, entry{
+			Scenario: ShortName(w.Scenario),
+			Weight:   w.Weight,
+			Percent:  float64(w.Weight) * 100 / total,
+		})
+	}
+	return json.Marshal(entries)
+}
+
+// ShortName은 시나리오의 설정 이름("approve")을 반환한다.
+func ShortName(sc paymentv1.PaymentScenario) string {
+	return strings.ToLower(strings.TrimPrefix(sc.String(), "PAYMENT_SCENARIO_"))
+}
@@ -0,0 +1,77 @@
+package scenario
+
+import (
+	"math"
+	"math/rand/v2"
+	"testing"
+
+	paymentv1 "github.com/traffic-tacos/proto-contracts/gen/go/payment/v1"
+)
+
+func TestParseMix(t *testing.T) {
+	tests := []struct {
+		spec    string
+		want    string // 파싱한 mix의 String()
+		wantErr bool
+	}{
+		{spec: "", want: "approve"},
+		{spec: "random", want: "random"},
+		{spec: "approve:90,fail:8,delay:2", want: "approve:90,fail:8,delay:2"},
+		{spec: " approve : 3 , fail ", want: "approve:3,fail:1"},
+		{spec: "approve:90,,fail:10,", want: "approve:90,fail:10"},
+		{spec: "approve:0", wantErr: true},
+		{spec: "approve:-1", wantErr: true},
+		{spec: "approve:x", wantErr: true},
+		{spec: "approve,approve:2", wantErr: true},
+		{spec: "bogus:1", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.spec, func(t *testing.T) {
+			mix, err := ParseMix(tt.spec)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("ParseMix(%q) = %v, want error", tt.spec, mix)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseMix(%q): %v", tt.spec, err)
+			}
+			if got := mix.String(); got != tt.want {
+				t.Errorf("String() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMixPickFollowsWeights(t *testing.T) {
+	mix, err := ParseMix("approve:90,fail:8,delay:2")
+	if err != nil {
+		t.Fatalf("ParseMix: %v", err)
+	}
+
+	const draws = 100000
+	rng := rand.New(rand.NewPCG(1, 2))
+	counts := make(map[paymentv1.PaymentScenario]int)
+	for i := 0; i < draws; i++ {
+		counts[mix.Pick(rng)]++
+	}
+
+	for _, w := range mix {
+		got := float64(counts[w.Scenario]) / draws * 100
+		if math.Abs(got-float64(w.Weight)) > 0.5 {
+			t.Errorf("%s picked %.2f%%, want about %d%%", ShortName(w.Scenario), got, w.Weight)
+		}
+	}
+}
+
+func TestMixPickWithoutRandomness(t *testing.T) {
+	if got := (Mix{}).Pick(nil); got != paymentv1.PaymentScenario_PAYMENT_SCENARIO_APPROVE {
+		t.Errorf("zero Mix picked %s, want APPROVE", got)
+	}
+	single := Mix{{Scenario: paymentv1.PaymentScenario_PAYMENT_SCENARIO_FAIL, Weight: 5}}
+	if got := single.Pick(nil); got != paymentv1.PaymentScenario_PAYMENT_SCENARIO_FAIL {
+		t.Errorf("single-entry Mix picked %s, want FAIL", got)
+	}
+}
@@ -0,0 +1,113 @@
+package scenario
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+
+	"go.yaml.in/yaml/v2"
+)
+
+// Registry는 이름으로 고를 수 있는 시나리오 정의를 보관한다.
+type Registry struct {
+	canTransition TransitionFunc
+
+	mu          sync.RWMutex
+	definitions map[string]Definition
+}
+
+// NewRegistry는 빈 registry를 반환한다. 이 registry로 읽는 정의는
+// canTransition으로 검사하며, nil이면 검사하지 않는다.
+func NewRegistry(canTransition TransitionFunc) *Registry {
+	return &Registry{
+		canTransition: canTransition,
+		definitions:   make(map[string]Definition),
+	}
+}
+
+// document는 파일과 admin API의 형식이다. YAML은 JSON의 상위 집합이므로 둘 다
+// 받는다.
+type document struct {
+	Scenarios []Definition `yaml:"scenarios"`
+}
+
+// Parse는 정의 하나 또는 {scenarios: [...]} 문서를 디코딩한다.
+func Parse(data []byte) ([]Definition, error) {
+	var doc document
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("failed to parse scenarios: %w", err)
+	}
+	if len(doc.Scenarios) == 0 {
+		var def Definition
+		if err := yaml.Unmarshal(data, &def); err != nil {
+			return nil, fmt.Errorf("failed to parse scenario: %w", err)
+		}
+		doc.Scenarios = []Definition{def}
+	}
+
+	for i := range doc.Scenarios {
+		if err := doc.Scenarios[i].Validate(); err != nil {
+			return nil, err
+		}
+	}
+	return doc.Scenarios, nil
+}
+
+// LoadFile은 YAML/JSON 파일의 모든 시나리오를 등록한다.
+func (r *Registry) LoadFile(path string) ([]Definition, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read scenario file: %w", err)
+	}
+	return r.Load(data)
+}
+
+// Load는 YAML/JSON 문서를 파싱해 정의를 등록한다. 검증에 실패하거나 결제
+// 상태 머신이 거부하는 전이를 담은 정의가 하나라도 있으면 아무것도 등록하지
+// 않는다.
+func (r *Registry) Load(data []byte) ([]Definition, error) {
+	defs, err := Parse(data)
+	if err != nil {
+		return nil, err
+	}
+	if r.canTransition != nil {
+		for i := range defs {
+			if err := defs[i].CheckTransitions(r.canTransition); err != nil {
+				return nil, err
+			}
+		}
+	}
+	r.Register(defs...)
+	return defs, nil
+}
+
+// Register는 검증된 정의를 추가하거나 교체한다.
+func (r *Registry) Register(defs ...Definition) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, def := range defs {
+		r.definitions[def.Name] = def
+	}
+}
+
+func (r *Registry) Get(name string) (Definition, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	def, ok := r.definitions[name]
+	return def, ok
+}
+
+func (r *Registry) List() []Definition {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	defs := make([]Definition, 0, len(r.definitions))
+	for _, def := range r.definitions {
+		defs = append(defs, def)
+	}
+	sort.Slice(defs, func(i, j int) bool {
+		return defs[i].Name < defs[j].Name
+	})
+	return defs
+}
@@ -0,0 +1,85 @@
+package scenario
+
+import (
+	"strings"
+	"testing"
+)
+
+// allowed는 결제 상태 머신의 일부다. 실제 상태 머신으로는 service 패키지에서
+// 예제 시나리오를 검사한다.
+var allowed = map[string][]string{
+	"PAYMENT_STATUS_PENDING":    {"PAYMENT_STATUS_PROCESSING", "PAYMENT_STATUS_FAILED", "PAYMENT_STATUS_CANCELLED", "PAYMENT_STATUS_EXPIRED"},
+	"PAYMENT_STATUS_PROCESSING": {"PAYMENT_STATUS_COMPLETED", "PAYMENT_STATUS_FAILED", "PAYMENT_STATUS_CANCELLED"},
+	"PAYMENT_STATUS_COMPLETED":  {"PAYMENT_STATUS_REFUNDED"},
+}
+
+func canTransition(from, to string) bool {
+	for _, target := range allowed[from] {
+		if target == to {
+			return true
+		}
+	}
+	return false
+}
+
+func TestRegistryLoadChecksTransitions(t *testing.T) {
+	tests := []struct {
+		name    string
+		doc     string
+		wantErr string
+	}{
+		{
+			name: "approve",
+			doc:  "name: ok\nsteps:\n  - status: PENDING\n  - status: PROCESSING\n  - status: COMPLETED\n",
+		},
+		{
+			name:    "refund after approval",
+			doc:     "name: bad\nsteps:\n  - status: PROCESSING\n  - status: COMPLETED\n  - status: REFUNDED\n",
+			wantErr: "step 2: PAYMENT_STATUS_REFUNDED is reached by refunding the payment",
+		},
+		{
+			name: "duplicate webhook between steps",
+			doc:  "name: ok\nsteps:\n  - status: PROCESSING\n  - action: duplicate_webhook\n  - status: FAILED\n",
+		},
+		{
+			name:    "skip processing",
+			doc:     "name: bad\nsteps:\n  - status: PENDING\n  - status: COMPLETED\n",
+			wantErr: "invalid transition PAYMENT_STATUS_PENDING -> PAYMENT_STATUS_COMPLETED",
+		},
+		{
+			name:    "leave final status",
+			doc:     "name: bad\nsteps:\n  - status: FAILED\n  - status: PROCESSING\n",
+			wantErr: "step 1: invalid transition PAYMENT_STATUS_FAILED -> PAYMENT_STATUS_PROCESSING",
+		},
+		{
+			name:    "back to pending",
+			doc:     "name: bad\nsteps:\n  - status: PROCESSING\n  - status: PENDING\n",
+			wantErr: "invalid transition PAYMENT_STATUS_PROCESSING -> PAYMENT_STATUS_PENDING",
+		},
+		{
+			name:    "one bad definition rejects the document",
+			doc:     "scenarios:\n  - name: ok\n    steps:\n      - status: FAILED\n  - name: bad\n    steps:\n      - status: REFUNDED\n",
+			wantErr: "scenario bad step 0",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := NewRegistry(canTransition)
+			_, err := r.Load([]byte(tt.doc))
+
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("Load: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("Load error = %v, want %q", err, tt.wantErr)
+			}
+			if len(r.List()) != 0 {
+				t.Errorf("rejected document registered %d definitions", len(r.List()))
+			}
+		})
+	}
+}
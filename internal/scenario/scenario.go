@@ -0,0 +1,207 @@
+package scenario
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	paymentv1 "github.com/traffic-tacos/proto-contracts/gen/go/payment/v1"
+)
+
+// Step 동작
+const (
+	// ActionTransition (기본값)은 intent를 Status로 옮긴다.
+	ActionTransition = "transition"
+	// ActionDuplicateWebhook은 직전 webhook을 그대로 다시 보낸다. 실제 PG도
+	// 가끔 같은 알림을 두 번 보낸다.
+	ActionDuplicateWebhook = "duplicate_webhook"
+	// ActionNotify는 intent를 바꾸지 않고 Status의 이벤트와 webhook만 보낸다.
+	// service가 이미 적용한 상태를 알릴 때 넣는 step이며 시나리오 파일에서는
+	// 쓸 수 없다.
+	ActionNotify = "notify"
+)
+
+// Definition은 이름 붙은 결제 step 스크립트다.
+//
+//	name: insufficient-funds-dup
+//	steps:
+//	  - status: PENDING
+//	  - status: PROCESSING
+//	    after: 500ms
+//	  - status: FAILED
+//	    after: 1s
+//	    code: INSUFFICIENT_FUNDS
+//	  - action: duplicate_webhook
+//	    after: 3s
+type Definition struct {
+	Name        string `json:"name" yaml:"name"`
+	Description string `json:"description,omitempty" yaml:"description,omitempty"`
+	Steps       []Step `json:"steps" yaml:"steps"`
+}
+
+// Step은 이전 step이 끝나고 After만큼 지난 뒤 실행된다.
+type Step struct {
+	After  Duration `json:"after,omitempty" yaml:"after,omitempty"`
+	Action string   `json:"action,omitempty" yaml:"action,omitempty"`
+	Status string   `json:"status,omitempty" yaml:"status,omitempty"`
+	// Code는 FAILED 상태와 함께 보고할 PG 실패 코드다.
+	Code string `json:"code,omitempty" yaml:"code,omitempty"`
+	// Webhook은 이 step에서 이벤트 발행과 webhook 전송을 할지 정한다.
+	// 최종 상태는 기본값이 true다.
+	Webhook *bool `json:"webhook,omitempty" yaml:"webhook,omitempty"`
+}
+
+// Notify는 이 step에서 이벤트를 발행하고 webhook을 보내야 하는지 알려준다.
+func (s Step) Notify() bool {
+	if s.Action == ActionDuplicateWebhook || s.Action == ActionNotify {
+		return true
+	}
+	if s.Webhook != nil {
+		return *s.Webhook
+	}
+	return isFinal(s.Status)
+}
+
+func isFinal(status string) bool {
+	switch status {
+	case "PAYMENT_STATUS_PENDING", "PAYMENT_STATUS_PROCESSING":
+		return false
+	default:
+		return true
+	}
+}
+
+// Validate는 정의를 검사하고 상태를 proto enum 전체 이름으로 정규화한다
+// ("FAILED" -> "PAYMENT_STATUS_FAILED").
+func (d *Definition) Validate() error {
+	if d.Name == "" {
+		return fmt.Errorf("scenario name is required")
+	}
+	if len(d.Steps) == 0 {
+		return fmt.Errorf("scenario %s: at least one step is required", d.Name)
+	}
+
+	for i := range d.Steps {
+		step := &d.Steps[i]
+		if step.After < 0 {
+			return fmt.Errorf("scenario %s step %d: negative delay", d.Name, i)
+		}
+
+		switch step.Action {
+		case "", ActionTransition:
+			step.Action = ActionTransition
+			status, err := normalizeStatus(step.Status)
+			if err != nil {
+				return fmt.Errorf("scenario %s step %d: %w", d.Name, i, err)
+			}
+			step.Status = status
+		case ActionDuplicateWebhook:
+			if step.Status != "" {
+				return fmt.Errorf("scenario %s step %d: %s does not take a status", d.Name, i, step.Action)
+			}
+		default:
+			return fmt.Errorf("scenario %s step %d: unknown action %q", d.Name, i, step.Action)
+		}
+	}
+	return nil
+}
+
+// TransitionFunc는 결제가 두 상태(enum 전체 이름) 사이를 옮겨 갈 수 있는지
+// 알려준다.
+type TransitionFunc func(from, to string) bool
+
+// CheckTransitions는 검증된 정의의 step을 PENDING부터 따라가며
+// canTransition이 허용하지 않는 첫 상태 변경을 거부한다 (예: PROCESSING 없이
+// PENDING -> COMPLETED). 현재 상태를 반복하는 step은 실행 시 아무것도 하지
+// 않으므로 항상 통과한다. REFUNDED는 스크립트로 갈 수 없다. 결제는 환불로만
+// 그 상태가 되며, 환불은 자체 기록과 이벤트를 남긴다.
+func (d *Definition) CheckTransitions(canTransition TransitionFunc) error {
+	current := paymentv1.PaymentStatus_PAYMENT_STATUS_PENDING.String()
+	for i, step := range d.Steps {
+		if step.Action != ActionTransition || step.Status == current {
+			continue
+		}
+		if step.Status == paymentv1.PaymentStatus_PAYMENT_STATUS_REFUNDED.String() {
+			return fmt.Errorf("scenario %s step %d: %s is reached by refunding the payment, not by a script", d.Name, i, step.Status)
+		}
+		if !canTransition(current, step.Status) {
+			return fmt.Errorf("scenario %s step %d: invalid transition %s -> %s", d.Name, i, current, step.Status)
+		}
+		current = step.Status
+	}
+	return nil
+}
+
+func normalizeStatus(status string) (string, error) {
+	name := strings.ToUpper(strings.TrimSpace(status))
+	if !strings.HasPrefix(name, "PAYMENT_STATUS_") {
+		name = "PAYMENT_STATUS_" + name
+	}
+	if v, ok := paymentv1.PaymentStatus_value[name]; !ok || v == int32(paymentv1.PaymentStatus_PAYMENT_STATUS_UNSPECIFIED) {
+		return "", fmt.Errorf("unknown status %q", status)
+	}
+	return name, nil
+}
+
+// ParseName은 "approve" 또는 "PAYMENT_SCENARIO_APPROVE" 형태(대소문자 무시)의
+// 결제 시나리오를 파싱한다.
+func ParseName(name string) (paymentv1.PaymentScenario, error) {
+	upper := strings.ToUpper(strings.TrimSpace(name))
+	if !strings.HasPrefix(upper, "PAYMENT_SCENARIO_") {
+		upper = "PAYMENT_SCENARIO_" + upper
+	}
+	v, ok := paymentv1.PaymentScenario_value[upper]
+	if !ok || v == int32(paymentv1.PaymentScenario_PAYMENT_SCENARIO_UNSPECIFIED) {
+		return 0, fmt.Errorf("unknown payment scenario %q", name)
+	}
+	return paymentv1.PaymentScenario(v), nil
+}
+
+// Duration은 Go duration 문자열("500ms", "1s")이나 정수 밀리초를 받는다.
+type Duration time.Duration
+
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var v interface{}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	return d.set(v)
+}
+
+func (d *Duration) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var v interface{}
+	if err := unmarshal(&v); err != nil {
+		return err
+	}
+	return d.set(v)
+}
+
+func (d *Duration) set(v interface{}) error {
+	switch value := v.(type) {
+	case string:
+		if ms, err := strconv.ParseInt(value, 10, 64); err == nil {
+			*d = Duration(time.Duration(ms) * time.Millisecond)
+			return nil
+		}
+		parsed, err := time.ParseDuration(value)
+		if err != nil {
+			return fmt.Errorf("invalid duration %q", value)
+		}
+		*d = Duration(parsed)
+	case int:
+		*d = Duration(time.Duration(value) * time.Millisecond)
+	case float64:
+		*d = Duration(time.Duration(value) * time.Millisecond)
+	case nil:
+		*d = 0
+	default:
+		return fmt.Errorf("invalid duration %v", v)
+	}
+	return nil
+}
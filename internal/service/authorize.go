@@ -0,0 +1,250 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	paymentv1 "github.com/traffic-tacos/proto-contracts/gen/go/payment/v1"
+	"github.com/traffic-tacos/payment-sim-api/internal/scenario"
+	"github.com/traffic-tacos/payment-sim-api/internal/webhook"
+	"github.com/traffic-tacos/payment-sim-api/pkg/paymentevent"
+)
+
+// 2단계 결제 (승인 후 매입). metadata.tags["capture_method"]="manual"이면 승인만 하고
+// ProcessPayment(capture) / CancelPayment(void)를 기다린다.
+const (
+	tagCaptureMethod      = "capture_method" // automatic|manual
+	tagAuthorizationTTLMs = "authorization_ttl_ms"
+
+	CaptureAutomatic = "automatic"
+	CaptureManual    = "manual"
+
+	// captureAmountMetadata는 ProcessPayment의 부분 매입 금액(minor unit)을 담는 gRPC metadata 키다.
+	captureAmountMetadata = "x-capture-amount"
+
+	authorizationExpiredCode = "AUTHORIZATION_EXPIRED"
+)
+
+var ErrCaptureAmount = newError(KindInvalidArgument, "INVALID_CAPTURE_AMOUNT", "invalid capture amount")
+
+func captureMethod(tags map[string]string) (string, error) {
+	switch method := strings.ToLower(tags[tagCaptureMethod]); method {
+	case "", CaptureAutomatic:
+		return CaptureAutomatic, nil
+	case CaptureManual:
+		return CaptureManual, nil
+	default:
+		return "", fmt.Errorf("%w: %s=%q", ErrInvalidTag, tagCaptureMethod, method)
+	}
+}
+
+// authorizationTTL은 capture되지 않은 승인이 유효한 기간이다.
+func (s *PaymentService) authorizationTTL(tags map[string]string) (time.Duration, error) {
+	ttl := time.Duration(s.config.AuthorizationTTLMs) * time.Millisecond
+	if v, ok := tags[tagAuthorizationTTLMs]; ok {
+		ms, err := strconv.Atoi(v)
+		if err != nil || ms <= 0 {
+			return 0, fmt.Errorf("%w: %s=%q", ErrInvalidTag, tagAuthorizationTTLMs, v)
+		}
+		ttl = time.Duration(ms) * time.Millisecond
+	}
+	return ttl, nil
+}
+
+// authorizeSteps는 시나리오를 승인만 하는 형태로 바꾼다. 승인은 AUTHORIZED에서
+// 멈춘다. expireAfter는 job 시작부터 승인이 만료되기까지의 시간(승인 step +
+// TTL)이며, 승인하는 step이 없으면 0이다.
+func (s *PaymentService) authorizeSteps(intent *PaymentIntent, steps []scenario.Step) (authorized []scenario.Step, expireAfter time.Duration, err error) {
+	ttl, err := s.authorizationTTL(intent.Tags)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	authorized = make([]scenario.Step, 0, len(steps))
+	var at time.Duration
+	for _, step := range steps {
+		at += time.Duration(step.After)
+		if step.Status == paymentv1.PaymentStatus_PAYMENT_STATUS_COMPLETED.String() {
+			step.Status = statusAuthorizedName
+			if expireAfter == 0 {
+				expireAfter = at + ttl
+			}
+		}
+		authorized = append(authorized, step)
+	}
+	return authorized, expireAfter, nil
+}
+
+// scheduleExpiry는 승인 만료를 별도 job으로 큐에 넣는다. 그래서 capture와 void는
+// 앞에 어떤 step이 남아 있든 만료만 취소할 수 있다. 그래도 capture나 void 뒤에
+// 실행되면 상태 머신이 거부한다.
+func (s *PaymentService) scheduleExpiry(intent *PaymentIntent, after time.Duration) {
+	s.schedule(intent, webhook.Job{
+		Amount: intent.Amount.GetAmount(),
+		Kind:   webhook.JobAuthorizationExpiry,
+		Steps: []scenario.Step{{
+			Action: scenario.ActionTransition,
+			Status: paymentv1.PaymentStatus_PAYMENT_STATUS_EXPIRED.String(),
+			Code:   authorizationExpiredCode,
+			After:  scenario.Duration(after),
+		}},
+	}, "authorization expiry")
+}
+
+// capturePayment은 승인된 결제를 매입한다. x-capture-amount metadata가 있으면 부분 매입이며,
+// 남은 승인 금액은 해제된다.
+func (s *PaymentService) capturePayment(ctx context.Context, paymentID string) (*paymentv1.ProcessPaymentResponse, error) {
+	requested, err := captureAmountFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	intent, err := s.mutate(ctx, paymentID, func(intent *PaymentIntent) error {
+		amount := intent.Amount.GetAmount()
+		if requested > 0 {
+			if requested > amount {
+				return fmt.Errorf("%w: %d exceeds authorized amount %d", ErrCaptureAmount, requested, amount)
+			}
+			if err := checkIncrement(intent.Amount.GetCurrency(), requested); err != nil {
+				return fmt.Errorf("%w: %v", ErrCaptureAmount, err)
+			}
+			amount = requested
+		}
+		if err := s.stateMachine.Apply(intent, paymentv1.PaymentStatus_PAYMENT_STATUS_COMPLETED, "captured"); err != nil {
+			return err
+		}
+		intent.CapturedAmount = amount
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// 남은 만료 step이 큐에 남아 WEBHOOK_QUEUE_DEPTH를 차지하지 않도록 제거한다.
+	// 아직 나가지 않은 AUTHORIZED 알림은 그대로 둔다.
+	removed := 0
+	if s.webhook != nil {
+		removed = s.webhook.CancelScheduled(intent.ID, webhook.JobAuthorizationExpiry)
+	}
+
+	s.logger.Info("Payment captured",
+		zap.String("payment_id", intent.ID),
+		zap.Int64("captured_amount", intent.CapturedAmount),
+		zap.Int64("authorized_amount", intent.Amount.GetAmount()),
+		zap.Int("cancelled_steps", removed))
+	s.notify(intent, intent.CapturedAmount, "")
+
+	response := &paymentv1.ProcessPaymentResponse{
+		PaymentId: intent.ID,
+		Status:    intent.Status,
+		Result: &paymentv1.PaymentResult{
+			Success:         true,
+			GatewayResponse: "CAPTURED",
+		},
+	}
+	if intent.ProcessedAt != nil {
+		response.ProcessedAt = timestamppb.New(*intent.ProcessedAt)
+	}
+	return response, nil
+}
+
+// voidPayment은 매입 전 승인을 취소한다.
+func (s *PaymentService) voidPayment(ctx context.Context, req *paymentv1.CancelPaymentRequest) (*paymentv1.CancelPaymentResponse, error) {
+	reason := req.Reason
+	if reason == "" {
+		reason = "voided"
+	}
+
+	intent, err := s.transition(ctx, req.PaymentIntentId, reason, paymentv1.PaymentStatus_PAYMENT_STATUS_CANCELLED)
+	if err != nil {
+		return nil, err
+	}
+
+	removed := 0
+	if s.webhook != nil {
+		removed = s.webhook.CancelScheduled(intent.ID, webhook.JobAuthorizationExpiry)
+	}
+
+	s.logger.Info("Authorization voided",
+		zap.String("payment_id", intent.ID),
+		zap.String("reason", reason),
+		zap.Int("cancelled_steps", removed))
+	s.notify(intent, intent.Amount.GetAmount(), paymentevent.EventTypeCancelled)
+
+	return &paymentv1.CancelPaymentResponse{
+		Status:      intent.Status,
+		CancelledAt: timestamppb.New(intent.UpdatedAt),
+	}, nil
+}
+
+// notify는 동기적으로 적용한 상태 변경의 이벤트와 webhook을 보낸다. 이 step은
+// 알리기만 하므로 실행 전에 상태가 다시 바뀌어도 나간다. eventType이 비어
+// 있으면 payment.status_updated다.
+func (s *PaymentService) notify(intent *PaymentIntent, amount int64, eventType paymentevent.EventType) {
+	s.schedule(intent, webhook.Job{
+		Amount:    amount,
+		EventType: eventType,
+		Steps: []scenario.Step{{
+			Action: scenario.ActionNotify,
+			Status: statusName(intent.Status),
+			Code:   intent.FailureCode,
+		}},
+	}, "payment notification")
+}
+
+// schedule은 job의 결제 필드를 채워 큐에 넣는다.
+func (s *PaymentService) schedule(intent *PaymentIntent, job webhook.Job, what string) {
+	if s.webhook == nil {
+		return
+	}
+	job.PaymentID = intent.ID
+	job.ReservationID = intent.ReservationID
+	job.MerchantID = intent.Tags[tagMerchantID]
+	job.WebhookURL = intent.WebhookURL
+	job.Currency = intent.Amount.GetCurrency()
+	err := s.webhook.SchedulePayment(job)
+	if err != nil {
+		s.logger.Error("Failed to schedule "+what,
+			zap.String("payment_id", intent.ID),
+			zap.String("status", statusName(intent.Status)),
+			zap.Error(err))
+	}
+}
+
+func captureAmountFromContext(ctx context.Context) (int64, error) {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return 0, nil
+	}
+	values := md.Get(captureAmountMetadata)
+	if len(values) == 0 {
+		return 0, nil
+	}
+	amount, err := strconv.ParseInt(values[0], 10, 64)
+	if err != nil || amount <= 0 {
+		return 0, fmt.Errorf("%w: %s=%q", ErrCaptureAmount, captureAmountMetadata, values[0])
+	}
+	return amount, nil
+}
+
+// paymentResult는 proto에서 PROCESSING으로 보고되는 승인된 결제와 실패한 결제의
+// 거절 사유를 설명한다.
+func paymentResult(intent *PaymentIntent) *paymentv1.PaymentResult {
+	switch intent.Status {
+	case StatusAuthorized:
+		return &paymentv1.PaymentResult{
+			Success:         true,
+			GatewayResponse: "AUTHORIZED",
+		}
+	case paymentv1.PaymentStatus_PAYMENT_STATUS_FAILED, paymentv1.PaymentStatus_PAYMENT_STATUS_EXPIRED:
+		return failureResult(intent)
+	}
+	return nil
+}
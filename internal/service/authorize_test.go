@@ -0,0 +1,316 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+
+	"google.golang.org/grpc/metadata"
+
+	commonv1 "github.com/traffic-tacos/proto-contracts/gen/go/common/v1"
+	paymentv1 "github.com/traffic-tacos/proto-contracts/gen/go/payment/v1"
+	"github.com/traffic-tacos/payment-sim-api/internal/scenario"
+	"github.com/traffic-tacos/payment-sim-api/internal/webhook"
+	"github.com/traffic-tacos/payment-sim-api/pkg/paymentevent"
+)
+
+func TestSettleAuthorizationCancelsExpiry(t *testing.T) {
+	tests := []struct {
+		name   string
+		settle func(ctx context.Context, s *PaymentService, paymentID string) error
+		want   paymentv1.PaymentStatus
+	}{
+		{
+			name: "capture",
+			settle: func(ctx context.Context, s *PaymentService, paymentID string) error {
+				_, err := s.ProcessPayment(ctx, &paymentv1.ProcessPaymentRequest{PaymentIntentId: paymentID})
+				return err
+			},
+			want: paymentv1.PaymentStatus_PAYMENT_STATUS_COMPLETED,
+		},
+		{
+			name: "void",
+			settle: func(ctx context.Context, s *PaymentService, paymentID string) error {
+				_, err := s.CancelPayment(ctx, &paymentv1.CancelPaymentRequest{PaymentIntentId: paymentID})
+				return err
+			},
+			want: paymentv1.PaymentStatus_PAYMENT_STATUS_CANCELLED,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			sender := &fakeSender{}
+			s := newTestService(t, testConfig(t), sender)
+
+			created, err := s.CreatePaymentIntent(ctx, &paymentv1.CreatePaymentIntentRequest{
+				ReservationId: "rsv-" + tt.name,
+				UserId:        "user-1",
+				Amount:        &commonv1.Money{Amount: 10000, Currency: "KRW"},
+				Scenario:      paymentv1.PaymentScenario_PAYMENT_SCENARIO_APPROVE,
+				Metadata: &paymentv1.PaymentMetadata{
+					Tags: map[string]string{tagCaptureMethod: CaptureManual},
+				},
+			})
+			if err != nil {
+				t.Fatalf("CreatePaymentIntent: %v", err)
+			}
+			id := created.PaymentIntentId
+
+			// dispatcher가 PROCESSING, AUTHORIZED step을 실행한 상태
+			for _, status := range []string{paymentv1.PaymentStatus_PAYMENT_STATUS_PROCESSING.String(), statusAuthorizedName} {
+				if err := s.UpdatePaymentStatus(id, status, ""); err != nil {
+					t.Fatalf("UpdatePaymentStatus(%s): %v", status, err)
+				}
+			}
+			if !sender.queued(id, webhook.JobAuthorizationExpiry) {
+				t.Fatal("expected the authorization expiry job to be queued")
+			}
+
+			if err := tt.settle(ctx, s, id); err != nil {
+				t.Fatalf("settle: %v", err)
+			}
+
+			if sender.queued(id, webhook.JobAuthorizationExpiry) {
+				t.Errorf("authorization expiry still queued after %s", tt.name)
+			}
+			intent, err := s.store.Get(ctx, id)
+			if err != nil {
+				t.Fatalf("Get: %v", err)
+			}
+			if intent.Status != tt.want {
+				t.Errorf("status = %s, want %s", statusName(intent.Status), statusName(tt.want))
+			}
+		})
+	}
+}
+
+func TestCapturePayment(t *testing.T) {
+	tests := []struct {
+		name     string
+		currency string
+		capture  string // x-capture-amount, "" for a full capture
+		wantErr  bool
+		captured int64
+	}{
+		{
+			name:     "full capture",
+			currency: "KRW",
+			captured: 10000,
+		},
+		{
+			name:     "partial capture",
+			currency: "KRW",
+			capture:  "4000",
+			captured: 4000,
+		},
+		{
+			name:     "capture of the whole authorization",
+			currency: "KRW",
+			capture:  "10000",
+			captured: 10000,
+		},
+		{
+			name:     "over capture",
+			currency: "KRW",
+			capture:  "10001",
+			wantErr:  true,
+		},
+		{
+			name:     "zero",
+			currency: "KRW",
+			capture:  "0",
+			wantErr:  true,
+		},
+		{
+			name:     "not a number",
+			currency: "KRW",
+			capture:  "4,000",
+			wantErr:  true,
+		},
+		{
+			name:     "off the currency increment",
+			currency: "HUF",
+			capture:  "150",
+			wantErr:  true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			sender := &fakeSender{}
+			s := newTestService(t, testConfig(t), sender)
+			id := authorizedIntent(t, s, tt.currency)
+
+			captureCtx := ctx
+			if tt.capture != "" {
+				captureCtx = metadata.NewIncomingContext(ctx, metadata.Pairs(captureAmountMetadata, tt.capture))
+			}
+			resp, err := s.ProcessPayment(captureCtx, &paymentv1.ProcessPaymentRequest{PaymentIntentId: id})
+
+			intent, gerr := s.store.Get(ctx, id)
+			if gerr != nil {
+				t.Fatalf("Get: %v", gerr)
+			}
+			if tt.wantErr {
+				if !errors.Is(err, ErrCaptureAmount) {
+					t.Fatalf("ProcessPayment err = %v, want %v", err, ErrCaptureAmount)
+				}
+				// 승인은 그대로 남고 만료도 예약된 채로 남는다
+				if intent.Status != StatusAuthorized || intent.CapturedAmount != 0 {
+					t.Errorf("intent = %s captured %d, want still AUTHORIZED", statusName(intent.Status), intent.CapturedAmount)
+				}
+				if !sender.queued(id, webhook.JobAuthorizationExpiry) {
+					t.Error("authorization expiry no longer queued")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ProcessPayment: %v", err)
+			}
+
+			if resp.Status != completed || intent.Status != completed || intent.CapturedAmount != tt.captured {
+				t.Errorf("captured %s/%s %d, want COMPLETED %d", resp.Status, statusName(intent.Status), intent.CapturedAmount, tt.captured)
+			}
+			if sender.queued(id, webhook.JobAuthorizationExpiry) {
+				t.Error("authorization expiry still queued after capture")
+			}
+			// 매입 알림의 금액은 매입 금액이다
+			jobs := sender.jobsFor(id)
+			last := jobs[len(jobs)-1]
+			if last.Amount != tt.captured || last.Steps[0].Action != scenario.ActionNotify || last.Steps[0].Status != completed.String() {
+				t.Errorf("capture notification = %+v, want COMPLETED for %d", last, tt.captured)
+			}
+		})
+	}
+}
+
+func TestVoidPayment(t *testing.T) {
+	ctx := context.Background()
+	sender := &fakeSender{}
+	s := newTestService(t, testConfig(t), sender)
+	id := authorizedIntent(t, s, "KRW")
+
+	resp, err := s.CancelPayment(ctx, &paymentv1.CancelPaymentRequest{PaymentIntentId: id, Reason: "customer changed mind"})
+	if err != nil {
+		t.Fatalf("CancelPayment: %v", err)
+	}
+	if resp.Status != cancelled {
+		t.Errorf("status = %s, want CANCELLED", resp.Status)
+	}
+
+	jobs := sender.jobsFor(id)
+	last := jobs[len(jobs)-1]
+	if last.EventType != paymentevent.EventTypeCancelled || last.Steps[0].Status != cancelled.String() {
+		t.Errorf("void notification = %+v, want a payment.cancelled CANCELLED step", last)
+	}
+
+	// 매입 전 취소된 결제는 매입할 수 없다
+	if _, err := s.ProcessPayment(ctx, &paymentv1.ProcessPaymentRequest{PaymentIntentId: id}); err == nil {
+		t.Error("ProcessPayment captured a voided authorization")
+	}
+}
+
+// 승인 직후 매입해도 아직 나가지 않은 AUTHORIZED 알림은 남고 만료 step만 제거된다.
+func TestCaptureKeepsAuthorizedNotification(t *testing.T) {
+	ctx := context.Background()
+	cfg := testConfig(t)
+	s, d, rec, url := newDispatchedService(t, cfg, nil, nil)
+
+	created, err := s.CreatePaymentIntent(ctx, &paymentv1.CreatePaymentIntentRequest{
+		ReservationId: "rsv-1",
+		UserId:        "user-1",
+		Amount:        &commonv1.Money{Amount: 10000, Currency: "KRW"},
+		Scenario:      paymentv1.PaymentScenario_PAYMENT_SCENARIO_APPROVE,
+		WebhookUrl:    url,
+		Metadata: &paymentv1.PaymentMetadata{
+			Tags: map[string]string{tagCaptureMethod: CaptureManual},
+		},
+	})
+	if err != nil {
+		t.Fatalf("CreatePaymentIntent: %v", err)
+	}
+	id := created.PaymentIntentId
+
+	// dispatcher가 멈춘 동안 승인과 매입이 연달아 들어온다
+	for _, want := range []paymentv1.PaymentStatus{StatusAuthorized, completed} {
+		resp, err := s.ProcessPayment(ctx, &paymentv1.ProcessPaymentRequest{PaymentIntentId: id})
+		if err != nil {
+			t.Fatalf("ProcessPayment: %v", err)
+		}
+		if resp.Status != wireStatus(want) {
+			t.Fatalf("ProcessPayment status = %s, want %s", resp.Status, wireStatus(want))
+		}
+	}
+
+	d.Start()
+	rec.wait(t, id, paymentevent.EventTypeStatusUpdated, statusAuthorizedName)
+	rec.wait(t, id, paymentevent.EventTypeStatusUpdated, completed.String())
+
+	report, err := d.Shutdown(ctx)
+	if err != nil {
+		t.Fatalf("Shutdown: %v", err)
+	}
+	if len(report.Dropped) != 0 {
+		t.Errorf("dropped tasks = %+v, want the expiry step cancelled by capture", report.Dropped)
+	}
+}
+
+// 승인 뒤에 아직 실행되지 않은 step이 남아 있어도 매입은 만료 job만 제거한다.
+func TestCaptureWithStepsStillQueued(t *testing.T) {
+	ctx := context.Background()
+	scenarios := scenario.NewRegistry(NewStateMachine().CanTransitionName)
+	if _, err := scenarios.Load([]byte(`name: late-duplicate
+steps:
+  - status: PROCESSING
+  - status: COMPLETED
+  - action: duplicate_webhook
+    after: 1h
+`)); err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	s, d, rec, url := newDispatchedService(t, testConfig(t), scenarios, nil)
+	d.Start()
+
+	created, err := s.CreatePaymentIntent(ctx, &paymentv1.CreatePaymentIntentRequest{
+		ReservationId: "rsv-1",
+		UserId:        "user-1",
+		Amount:        &commonv1.Money{Amount: 10000, Currency: "KRW"},
+		Scenario:      paymentv1.PaymentScenario_PAYMENT_SCENARIO_APPROVE,
+		WebhookUrl:    url,
+		Metadata: &paymentv1.PaymentMetadata{
+			Tags: map[string]string{tagCaptureMethod: CaptureManual, tagScenarioName: "late-duplicate"},
+		},
+	})
+	if err != nil {
+		t.Fatalf("CreatePaymentIntent: %v", err)
+	}
+	id := created.PaymentIntentId
+	rec.wait(t, id, paymentevent.EventTypeStatusUpdated, statusAuthorizedName)
+
+	if _, err := s.ProcessPayment(ctx, &paymentv1.ProcessPaymentRequest{PaymentIntentId: id}); err != nil {
+		t.Fatalf("ProcessPayment: %v", err)
+	}
+	rec.wait(t, id, paymentevent.EventTypeStatusUpdated, completed.String())
+
+	report, err := d.Shutdown(ctx)
+	if err != nil {
+		t.Fatalf("Shutdown: %v", err)
+	}
+	var dropped []string
+	for _, p := range report.Dropped {
+		if p.Kind != webhook.PendingStep {
+			continue
+		}
+		if p.Job.Kind == webhook.JobAuthorizationExpiry {
+			t.Errorf("authorization expiry still queued after capture: %+v", p)
+		}
+		dropped = append(dropped, p.Job.Steps[p.StepIndex].Action)
+	}
+	if !slices.Equal(dropped, []string{scenario.ActionDuplicateWebhook}) {
+		t.Errorf("queued steps at shutdown = %v, want the duplicate webhook only", dropped)
+	}
+}
@@ -0,0 +1,54 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"go.uber.org/zap"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	paymentv1 "github.com/traffic-tacos/proto-contracts/gen/go/payment/v1"
+	"github.com/traffic-tacos/payment-sim-api/pkg/paymentevent"
+)
+
+// ErrIntentFinal is returned when cancelling a payment whose outcome is
+// already decided (failed, cancelled, expired).
+var ErrIntentFinal = newError(KindFailedPrecondition, "PAYMENT_INTENT_FINAL", "payment intent is already final")
+
+// cancelPayment은 결과가 아직 정해지지 않은 결제를 취소한다 (예: 예약 hold 만료).
+// 예약된 시나리오 step은 dispatcher 큐에서 제거되고, 이미 실행 중인 step은 상태 머신이
+// 전이를 거절하므로 원래 결과의 webhook은 나가지 않는다.
+func (s *PaymentService) cancelPayment(ctx context.Context, req *paymentv1.CancelPaymentRequest) (*paymentv1.CancelPaymentResponse, error) {
+	reason := req.Reason
+	if reason == "" {
+		reason = "cancelled"
+	}
+
+	intent, err := s.transition(ctx, req.PaymentIntentId, reason, paymentv1.PaymentStatus_PAYMENT_STATUS_CANCELLED)
+	if err != nil {
+		// 취소 요청과 시나리오 step이 경합해 결과가 먼저 확정된 경우
+		var transitionErr *TransitionError
+		if errors.As(err, &transitionErr) {
+			return nil, fmt.Errorf("%w: %s is %s", ErrIntentFinal, req.PaymentIntentId, statusName(transitionErr.From))
+		}
+		return nil, err
+	}
+
+	removed := 0
+	if s.webhook != nil {
+		removed = s.webhook.CancelScheduled(intent.ID)
+	}
+
+	s.logger.Info("Payment intent cancelled",
+		zap.String("payment_id", intent.ID),
+		zap.String("reservation_id", intent.ReservationID),
+		zap.String("reason", reason),
+		zap.Int("cancelled_steps", removed))
+	s.notify(intent, intent.Amount.GetAmount(), paymentevent.EventTypeCancelled)
+
+	return &paymentv1.CancelPaymentResponse{
+		Status:      intent.Status,
+		CancelledAt: timestamppb.New(intent.UpdatedAt),
+	}, nil
+}
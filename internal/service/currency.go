@@ -0,0 +1,74 @@
+package service
+
+import (
+	"fmt"
+	"strings"
+)
+
+// currencyRule describes how amounts of an ISO 4217 currency are expressed in
+// minor units.
+type currencyRule struct {
+	// Exponent is the ISO 4217 minor unit (KRW 0 → 1000 means ₩1000, USD 2 → 1000 means $10.00).
+	Exponent int
+	// Increment is the smallest chargeable step in minor units. Card networks
+	// do not settle fractional HUF/TWD, so payment, partial capture and refund
+	// amounts in those currencies must be whole units.
+	Increment int64
+}
+
+// lookupCurrency finds the rule of an ISO 4217 code, ignoring case.
+func lookupCurrency(code string) (currencyRule, bool) {
+	rule, ok := currencies[strings.ToUpper(code)]
+	return rule, ok
+}
+
+// checkIncrement rejects an amount that is not a multiple of the currency's
+// increment. Unknown currencies were already rejected at creation.
+func checkIncrement(currency string, amount int64) error {
+	rule, ok := lookupCurrency(currency)
+	if !ok || amount%rule.Increment == 0 {
+		return nil
+	}
+	return fmt.Errorf("%s amounts must be a multiple of %d minor units", strings.ToUpper(currency), rule.Increment)
+}
+
+// maxAmountMinorUnits caps a single payment at 8 digits of minor units, like
+// most PGs do.
+const maxAmountMinorUnits = 99_999_999
+
+// currencies lists the active ISO 4217 currencies accepted for payments.
+// Fund codes (BOV, CLF, USN, ...) and precious metals (X*) are excluded.
+var currencies = func() map[string]currencyRule {
+	rules := make(map[string]currencyRule)
+	add := func(exponent int, codes ...string) {
+		for _, code := range codes {
+			rules[code] = currencyRule{Exponent: exponent, Increment: 1}
+		}
+	}
+
+	add(0, "BIF", "CLP", "DJF", "GNF", "ISK", "JPY", "KMF", "KRW", "PYG", "RWF",
+		"UGX", "VND", "VUV", "XAF", "XOF", "XPF")
+	add(3, "BHD", "IQD", "JOD", "KWD", "LYD", "OMR", "TND")
+	add(2, "AED", "AFN", "ALL", "AMD", "ANG", "AOA", "ARS", "AUD", "AWG", "AZN",
+		"BAM", "BBD", "BDT", "BGN", "BMD", "BND", "BOB", "BRL", "BSD", "BTN",
+		"BWP", "BYN", "BZD", "CAD", "CDF", "CHF", "CNY", "COP", "CRC", "CUP",
+		"CVE", "CZK", "DKK", "DOP", "DZD", "EGP", "ERN", "ETB", "EUR", "FJD",
+		"FKP", "GBP", "GEL", "GHS", "GIP", "GMD", "GTQ", "GYD", "HKD", "HNL",
+		"HTG", "HUF", "IDR", "ILS", "INR", "IRR", "JMD", "KES", "KGS", "KHR",
+		"KPW", "KYD", "KZT", "LAK", "LBP", "LKR", "LRD", "LSL", "MAD", "MDL",
+		"MGA", "MKD", "MMK", "MNT", "MOP", "MRU", "MUR", "MVR", "MWK", "MXN",
+		"MYR", "MZN", "NAD", "NGN", "NIO", "NOK", "NPR", "NZD", "PAB", "PEN",
+		"PGK", "PHP", "PKR", "PLN", "QAR", "RON", "RSD", "RUB", "SAR", "SBD",
+		"SCR", "SDG", "SEK", "SGD", "SHP", "SLE", "SOS", "SRD", "SSP", "STN",
+		"SVC", "SYP", "SZL", "THB", "TJS", "TMT", "TOP", "TRY", "TTD", "TWD",
+		"TZS", "UAH", "USD", "UYU", "UZS", "VED", "VES", "WST", "XCD", "XCG",
+		"YER", "ZAR", "ZMW", "ZWG")
+
+	// 소수 단위를 카드 결제에 쓰지 않는 통화
+	for _, code := range []string{"HUF", "TWD"} {
+		rule := rules[code]
+		rule.Increment = 100
+		rules[code] = rule
+	}
+	return rules
+}()
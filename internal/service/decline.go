@@ -0,0 +1,62 @@
+package service
+
+import (
+	"fmt"
+	"math/rand/v2"
+	"time"
+
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	paymentv1 "github.com/traffic-tacos/proto-contracts/gen/go/payment/v1"
+	"github.com/traffic-tacos/payment-sim-api/internal/decline"
+)
+
+// Metadata tags selecting the decline reason of a failed payment
+const (
+	tagDeclineCode = "decline_code" // FAIL/RANDOM 시나리오의 decline 코드 (예: INSUFFICIENT_FUNDS)
+	tagTestCard    = "test_card"    // 테스트 값 규칙에 매칭할 카드 번호 또는 tok_<code> 토큰
+)
+
+// outcome is the simulated PG result of an intent.
+type outcome struct {
+	Status string
+	Delay  time.Duration
+	Code   string // FAILED일 때의 decline 코드
+}
+
+// declineCode picks the decline code of a failed payment: the decline_code tag
+// if set, a random catalogue entry for the RANDOM scenario, GENERIC_DECLINE
+// otherwise.
+func declineCode(scenario paymentv1.PaymentScenario, tags map[string]string, rng *rand.Rand) (string, error) {
+	if code, ok := tags[tagDeclineCode]; ok {
+		if _, found := decline.Lookup(code); !found {
+			return "", fmt.Errorf("%w: %s=%q", ErrInvalidTag, tagDeclineCode, code)
+		}
+		return code, nil
+	}
+	if scenario == paymentv1.PaymentScenario_PAYMENT_SCENARIO_RANDOM {
+		all := decline.All()
+		return all[rng.IntN(len(all))].Code, nil
+	}
+	return decline.Generic, nil
+}
+
+// failureResult describes a FAILED/EXPIRED payment with its PG code.
+func failureResult(intent *PaymentIntent) *paymentv1.PaymentResult {
+	if intent.FailureCode == "" {
+		return nil
+	}
+	result := &paymentv1.PaymentResult{
+		Success:         false,
+		FailureReason:   intent.FailureCode,
+		GatewayResponse: intent.FailureCode,
+	}
+	if r, ok := decline.Lookup(intent.FailureCode); ok {
+		result.FailureReason = r.Message
+		result.ResponseCode = r.ResponseCode
+	}
+	if intent.ProcessedAt != nil {
+		result.ProcessedAt = timestamppb.New(*intent.ProcessedAt)
+	}
+	return result
+}
@@ -0,0 +1,287 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/aws/aws-sdk-go-v2/service/eventbridge"
+	"go.uber.org/zap"
+
+	commonv1 "github.com/traffic-tacos/proto-contracts/gen/go/common/v1"
+	paymentv1 "github.com/traffic-tacos/proto-contracts/gen/go/payment/v1"
+	"github.com/traffic-tacos/payment-sim-api/internal/config"
+	"github.com/traffic-tacos/payment-sim-api/internal/decline"
+	"github.com/traffic-tacos/payment-sim-api/internal/events"
+	"github.com/traffic-tacos/payment-sim-api/internal/scenario"
+	"github.com/traffic-tacos/payment-sim-api/internal/testvalues"
+	"github.com/traffic-tacos/payment-sim-api/internal/webhook"
+	"github.com/traffic-tacos/payment-sim-api/pkg/paymentevent"
+)
+
+// recorder collects what the merchant endpoint and EventBridge receive.
+type recorder struct {
+	mu       sync.Mutex
+	webhooks []webhook.WebhookPayload
+	events   []paymentevent.Event
+}
+
+func (r *recorder) receiveWebhook(w http.ResponseWriter, req *http.Request) {
+	var payload webhook.WebhookPayload
+	json.NewDecoder(req.Body).Decode(&payload)
+	r.mu.Lock()
+	r.webhooks = append(r.webhooks, payload)
+	r.mu.Unlock()
+}
+
+func (r *recorder) putEvents(w http.ResponseWriter, req *http.Request) {
+	var input struct {
+		Entries []struct {
+			Detail string
+		}
+	}
+	json.NewDecoder(req.Body).Decode(&input)
+	r.mu.Lock()
+	for _, entry := range input.Entries {
+		var event paymentevent.Event
+		json.Unmarshal([]byte(entry.Detail), &event)
+		r.events = append(r.events, event)
+	}
+	r.mu.Unlock()
+
+	w.Header().Set("Content-Type", "application/x-amz-json-1.1")
+	w.Write([]byte(`{"FailedEntryCount":0,"Entries":[{"EventId":"evt-1"}]}`))
+}
+
+// wait returns the webhook and event of the payment with the given type and
+// status once both have arrived.
+func (r *recorder) wait(t *testing.T, paymentID string, eventType paymentevent.EventType, status string) (webhook.WebhookPayload, paymentevent.Event) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		r.mu.Lock()
+		var (
+			payload *webhook.WebhookPayload
+			event   *paymentevent.Event
+		)
+		for i, p := range r.webhooks {
+			if p.PaymentID == paymentID && p.EventType == string(eventType) && p.Status == status {
+				payload = &r.webhooks[i]
+			}
+		}
+		for i, e := range r.events {
+			if e.PaymentID == paymentID && e.EventType == eventType && string(e.Status) == status {
+				event = &r.events[i]
+			}
+		}
+		r.mu.Unlock()
+
+		if payload != nil && event != nil {
+			return *payload, *event
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("no %s %s webhook and event for %s", eventType, status, paymentID)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+// newDispatchedService wires the service to a webhook.Dispatcher that delivers
+// to a test merchant endpoint and publishes to a fake EventBridge. The caller
+// starts the dispatcher.
+func newDispatchedService(t *testing.T, cfg *config.Config, scenarios *scenario.Registry, testValues *testvalues.Engine) (*PaymentService, *webhook.Dispatcher, *recorder, string) {
+	t.Helper()
+	cfg.DefaultDelayMs = 0
+	cfg.WebhookWorkers = 1
+	cfg.WebhookRetryBaseMs = 1
+	cfg.WebhookRetryMaxMs = 1
+
+	rec := &recorder{}
+	merchant := httptest.NewServer(http.HandlerFunc(rec.receiveWebhook))
+	t.Cleanup(merchant.Close)
+	eventBridge := httptest.NewServer(http.HandlerFunc(rec.putEvents))
+	t.Cleanup(eventBridge.Close)
+
+	client := eventbridge.New(eventbridge.Options{
+		Region:           "ap-northeast-2",
+		BaseEndpoint:     aws.String(eventBridge.URL),
+		Credentials:      credentials.NewStaticCredentialsProvider("test", "test", ""),
+		RetryMaxAttempts: 1,
+	})
+	publisher := events.NewPublisher(client, cfg, zap.NewNop())
+
+	d := webhook.NewDispatcher(zap.NewNop(), cfg, publisher, nil, webhook.NewSecrets("test-secret"))
+	mix, err := scenario.ParseMix(cfg.DefaultScenario)
+	if err != nil {
+		t.Fatalf("ParseMix: %v", err)
+	}
+	s := NewPaymentService(zap.NewNop(), cfg, NewMemoryStore(), d, publisher, scenarios, testValues, mix)
+	d.SetStatusUpdater(s)
+	t.Cleanup(func() { d.Shutdown(context.Background()) })
+	return s, d, rec, merchant.URL
+}
+
+func TestDeclineCodeReachesStatusWebhookAndEvent(t *testing.T) {
+	rules, err := testvalues.Parse([]byte(`rules:
+  - name: amount-ending-13-fails
+    match: {amount_suffix: "13"}
+    decline_code: INSUFFICIENT_FUNDS`))
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	testValues := testvalues.NewEngine()
+	testValues.Replace(rules)
+
+	tests := []struct {
+		name     string
+		scenario paymentv1.PaymentScenario
+		amount   int64
+		tags     map[string]string
+		// card is sent with ProcessPayment instead of waiting for the scheduled steps
+		card     string
+		wantCode string // "" accepts any catalogue code
+	}{
+		{
+			name:     "failed scenario",
+			scenario: paymentv1.PaymentScenario_PAYMENT_SCENARIO_FAIL,
+			wantCode: decline.Generic,
+		},
+		{
+			name:     "failed scenario with decline_code tag",
+			scenario: paymentv1.PaymentScenario_PAYMENT_SCENARIO_FAIL,
+			tags:     map[string]string{tagDeclineCode: decline.FraudSuspected},
+			wantCode: decline.FraudSuspected,
+		},
+		{
+			name:     "random scenario",
+			scenario: paymentv1.PaymentScenario_PAYMENT_SCENARIO_RANDOM,
+			tags:     map[string]string{tagApprovalRatio: "0"},
+		},
+		{
+			name:     "magic amount",
+			scenario: paymentv1.PaymentScenario_PAYMENT_SCENARIO_APPROVE,
+			amount:   10013,
+			wantCode: decline.InsufficientFunds,
+		},
+		{
+			name:     "magic card tag",
+			scenario: paymentv1.PaymentScenario_PAYMENT_SCENARIO_APPROVE,
+			tags:     map[string]string{tagTestCard: "tok_three_ds_failed"},
+			wantCode: decline.ThreeDSFailed,
+		},
+		{
+			name:     "magic card on process",
+			scenario: paymentv1.PaymentScenario_PAYMENT_SCENARIO_APPROVE,
+			card:     "4000 0000 0000 0069",
+			wantCode: decline.CardExpired,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			cfg := testConfig(t)
+			s, d, rec, url := newDispatchedService(t, cfg, nil, testValues)
+			d.Start()
+			if tt.card != "" {
+				// ProcessPayment가 먼저 확정하도록 예약된 결과를 늦춘다
+				cfg.DefaultDelayMs = int(time.Hour / time.Millisecond)
+			}
+
+			amount := tt.amount
+			if amount == 0 {
+				amount = 10000
+			}
+			created, err := s.CreatePaymentIntent(ctx, &paymentv1.CreatePaymentIntentRequest{
+				ReservationId: "rsv-1",
+				UserId:        "user-1",
+				Amount:        &commonv1.Money{Amount: amount, Currency: "KRW"},
+				Scenario:      tt.scenario,
+				WebhookUrl:    url,
+				Metadata:      &paymentv1.PaymentMetadata{Tags: tt.tags},
+			})
+			if err != nil {
+				t.Fatalf("CreatePaymentIntent: %v", err)
+			}
+			id := created.PaymentIntentId
+
+			if tt.card != "" {
+				_, err := s.ProcessPayment(ctx, &paymentv1.ProcessPaymentRequest{
+					PaymentIntentId: id,
+					PaymentDetails: &paymentv1.PaymentDetails{
+						Method:     paymentv1.PaymentMethod_PAYMENT_METHOD_CREDIT_CARD,
+						CreditCard: &paymentv1.CreditCardDetails{CardNumber: tt.card},
+					},
+				})
+				if err != nil {
+					t.Fatalf("ProcessPayment: %v", err)
+				}
+			}
+
+			payload, event := rec.wait(t, id, paymentevent.EventTypeStatusUpdated, "PAYMENT_STATUS_FAILED")
+			code := payload.FailureCode
+			want, ok := decline.Lookup(code)
+			if !ok || (tt.wantCode != "" && code != tt.wantCode) {
+				t.Fatalf("webhook failure code = %q, want %q from the catalogue", code, tt.wantCode)
+			}
+			if payload.FailureReason != want.Message {
+				t.Errorf("webhook failure reason = %q, want %q", payload.FailureReason, want.Message)
+			}
+			if event.FailureCode != code || event.FailureReason != want.Message {
+				t.Errorf("event failure = %q/%q, want %q/%q", event.FailureCode, event.FailureReason, code, want.Message)
+			}
+
+			status, err := s.GetPaymentStatus(ctx, &paymentv1.GetPaymentStatusRequest{PaymentIntentId: id})
+			if err != nil {
+				t.Fatalf("GetPaymentStatus: %v", err)
+			}
+			result := status.GetPayment().GetResult()
+			if status.GetPayment().GetStatus() != paymentv1.PaymentStatus_PAYMENT_STATUS_FAILED ||
+				result.GetGatewayResponse() != code ||
+				result.GetFailureReason() != want.Message ||
+				result.GetResponseCode() != want.ResponseCode {
+				t.Errorf("GetPaymentStatus = %s %+v, want FAILED with %s", status.GetPayment().GetStatus(), result, code)
+			}
+		})
+	}
+}
+
+func TestRefundFailureReasonReachesWebhookAndEvent(t *testing.T) {
+	ctx := context.Background()
+	s, d, rec, url := newDispatchedService(t, testConfig(t), nil, nil)
+	d.Start()
+
+	created, err := s.CreatePaymentIntent(ctx, &paymentv1.CreatePaymentIntentRequest{
+		ReservationId: "rsv-1",
+		UserId:        "user-1",
+		Amount:        &commonv1.Money{Amount: 10000, Currency: "KRW"},
+		Scenario:      paymentv1.PaymentScenario_PAYMENT_SCENARIO_APPROVE,
+		WebhookUrl:    url,
+		Metadata:      &paymentv1.PaymentMetadata{Tags: map[string]string{tagRefundScenario: refundScenarioFail}},
+	})
+	if err != nil {
+		t.Fatalf("CreatePaymentIntent: %v", err)
+	}
+	id := created.PaymentIntentId
+	rec.wait(t, id, paymentevent.EventTypeStatusUpdated, "PAYMENT_STATUS_COMPLETED")
+
+	if _, err := s.CancelPayment(ctx, refundRequest(id, 0, "")); err != nil {
+		t.Fatalf("CancelPayment: %v", err)
+	}
+
+	// 환불 실패 후에도 결제는 COMPLETED로 남는다
+	payload, event := rec.wait(t, id, paymentevent.EventTypeRefundFailed, "PAYMENT_STATUS_COMPLETED")
+	want := decline.Message(decline.RefundDeclined)
+	if want == "" || payload.FailureCode != decline.RefundDeclined || payload.FailureReason != want {
+		t.Errorf("refund webhook failure = %q/%q, want %q/%q", payload.FailureCode, payload.FailureReason, decline.RefundDeclined, want)
+	}
+	if event.FailureCode != decline.RefundDeclined || event.FailureReason != want {
+		t.Errorf("refund event failure = %q/%q, want %q/%q", event.FailureCode, event.FailureReason, decline.RefundDeclined, want)
+	}
+}
@@ -0,0 +1,123 @@
+package service
+
+import (
+	"fmt"
+	"math"
+	"math/rand/v2"
+	"strconv"
+	"time"
+
+	"github.com/traffic-tacos/payment-sim-api/internal/config"
+)
+
+// 지연 분포 (PAYMENT_SCENARIO_DELAY)
+const (
+	DelayFixed    = "fixed"    // 항상 Base
+	DelayUniform  = "uniform"  // [Base, Max] 균등 분포
+	DelayNormal   = "normal"   // 평균 Base, 표준편차 StdDev
+	DelayLongTail = "longtail" // Base 이상의 파레토 분포 (Max로 상한)
+)
+
+// longTailAlpha는 파레토 분포의 꼬리 두께. 작을수록 긴 지연이 자주 나온다.
+const longTailAlpha = 1.5
+
+// 요청 metadata.tags로 intent별 지연을 덮어쓸 수 있다.
+const (
+	tagDelayMs            = "delay_ms"
+	tagDelayDistribution  = "delay_distribution"
+	tagDelayMaxMs         = "delay_max_ms"
+	tagDelayStdDevMs      = "delay_stddev_ms"
+	tagDelayExceedHoldTTL = "delay_exceed_hold_ttl"
+)
+
+// DelaySpec describes how long a DELAY scenario waits before the final outcome.
+type DelaySpec struct {
+	Distribution string
+	Base         time.Duration
+	Max          time.Duration
+	StdDev       time.Duration
+	// ExceedHoldTTL adds the reservation hold TTL on top of the sampled delay so
+	// the outcome always arrives after the reservation hold has expired.
+	ExceedHoldTTL bool
+	HoldTTL       time.Duration
+}
+
+// delaySpecFromConfig returns the DELAY scenario defaults.
+func delaySpecFromConfig(cfg *config.Config) DelaySpec {
+	return DelaySpec{
+		Distribution:  cfg.DelayDistribution,
+		Base:          time.Duration(cfg.DelayScenarioMs) * time.Millisecond,
+		Max:           time.Duration(cfg.DelayMaxMs) * time.Millisecond,
+		StdDev:        time.Duration(cfg.DelayStdDevMs) * time.Millisecond,
+		ExceedHoldTTL: cfg.DelayExceedHoldTTL,
+		HoldTTL:       time.Duration(cfg.ReservationHoldTTLMs) * time.Millisecond,
+	}
+}
+
+// withTags applies per-intent overrides from request metadata tags.
+func (d DelaySpec) withTags(tags map[string]string) (DelaySpec, error) {
+	if v, ok := tags[tagDelayDistribution]; ok {
+		d.Distribution = v
+	}
+	for tag, target := range map[string]*time.Duration{
+		tagDelayMs:       &d.Base,
+		tagDelayMaxMs:    &d.Max,
+		tagDelayStdDevMs: &d.StdDev,
+	} {
+		v, ok := tags[tag]
+		if !ok {
+			continue
+		}
+		ms, err := strconv.ParseInt(v, 10, 64)
+		if err != nil || ms < 0 {
+			return d, fmt.Errorf("%w: %s=%q", ErrInvalidTag, tag, v)
+		}
+		*target = time.Duration(ms) * time.Millisecond
+	}
+	if v, ok := tags[tagDelayExceedHoldTTL]; ok {
+		exceed, err := strconv.ParseBool(v)
+		if err != nil {
+			return d, fmt.Errorf("%w: %s=%q", ErrInvalidTag, tagDelayExceedHoldTTL, v)
+		}
+		d.ExceedHoldTTL = exceed
+	}
+
+	switch d.Distribution {
+	case DelayFixed, DelayUniform, DelayNormal, DelayLongTail:
+	default:
+		return d, fmt.Errorf("%w: unknown delay distribution %q", ErrInvalidTag, d.Distribution)
+	}
+	return d, nil
+}
+
+// Sample draws a single delay from the distribution.
+func (d DelaySpec) Sample(rng *rand.Rand) time.Duration {
+	var delay time.Duration
+
+	switch d.Distribution {
+	case DelayUniform:
+		delay = d.Base
+		if d.Max > d.Base {
+			delay += time.Duration(rng.Int64N(int64(d.Max-d.Base) + 1))
+		}
+	case DelayNormal:
+		delay = d.Base + time.Duration(rng.NormFloat64()*float64(d.StdDev))
+	case DelayLongTail:
+		// 파레토: Base * U^(-1/alpha)
+		u := 1 - rng.Float64() // (0, 1]
+		delay = time.Duration(float64(d.Base) * math.Pow(u, -1/longTailAlpha))
+		if d.Max > 0 && delay > d.Max {
+			delay = d.Max
+		}
+	default:
+		delay = d.Base
+	}
+
+	if delay < 0 {
+		delay = 0
+	}
+	if d.ExceedHoldTTL {
+		delay += d.HoldTTL
+	}
+	return delay
+}
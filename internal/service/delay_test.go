@@ -0,0 +1,196 @@
+package service
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	paymentv1 "github.com/traffic-tacos/proto-contracts/gen/go/payment/v1"
+)
+
+func TestDelaySpecSample(t *testing.T) {
+	tests := []struct {
+		name     string
+		spec     DelaySpec
+		min, max time.Duration
+		// wantMin/wantMax require the bound itself to be drawn at least once
+		wantMin, wantMax bool
+	}{
+		{
+			name:    "fixed",
+			spec:    DelaySpec{Distribution: DelayFixed, Base: 2 * time.Second, Max: 9 * time.Second},
+			min:     2 * time.Second,
+			max:     2 * time.Second,
+			wantMin: true,
+		},
+		{
+			name: "uniform",
+			spec: DelaySpec{Distribution: DelayUniform, Base: time.Second, Max: 3 * time.Second},
+			min:  time.Second,
+			max:  3 * time.Second,
+		},
+		{
+			name:    "uniform with max below base",
+			spec:    DelaySpec{Distribution: DelayUniform, Base: 3 * time.Second, Max: time.Second},
+			min:     3 * time.Second,
+			max:     3 * time.Second,
+			wantMin: true,
+		},
+		{
+			// 평균보다 표준편차가 커서 음수가 자주 나오지만 0에서 자른다
+			name:    "normal clamped at zero",
+			spec:    DelaySpec{Distribution: DelayNormal, Base: 100 * time.Millisecond, StdDev: time.Second},
+			min:     0,
+			max:     time.Hour,
+			wantMin: true,
+		},
+		{
+			name:    "long tail capped at max",
+			spec:    DelaySpec{Distribution: DelayLongTail, Base: time.Second, Max: 5 * time.Second},
+			min:     time.Second,
+			max:     5 * time.Second,
+			wantMax: true,
+		},
+		{
+			name:    "exceed hold ttl",
+			spec:    DelaySpec{Distribution: DelayFixed, Base: time.Second, ExceedHoldTTL: true, HoldTTL: time.Minute},
+			min:     time.Minute + time.Second,
+			max:     time.Minute + time.Second,
+			wantMin: true,
+		},
+		{
+			// hold TTL은 clamp 이후에 더하므로 결과는 항상 TTL을 넘긴다
+			name:    "exceed hold ttl after clamping",
+			spec:    DelaySpec{Distribution: DelayNormal, StdDev: time.Second, ExceedHoldTTL: true, HoldTTL: time.Minute},
+			min:     time.Minute,
+			max:     time.Minute + time.Hour,
+			wantMin: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rng := newRand(42)
+			var sawMin, sawMax bool
+			for range 1000 {
+				got := tt.spec.Sample(rng)
+				if got < tt.min || got > tt.max {
+					t.Fatalf("Sample = %s, want within [%s, %s]", got, tt.min, tt.max)
+				}
+				sawMin = sawMin || got == tt.min
+				sawMax = sawMax || got == tt.max
+			}
+			if tt.wantMin && !sawMin {
+				t.Errorf("never sampled the lower bound %s", tt.min)
+			}
+			if tt.wantMax && !sawMax {
+				t.Errorf("never sampled the upper bound %s", tt.max)
+			}
+		})
+	}
+}
+
+func TestDelaySpecSampleIsSeeded(t *testing.T) {
+	for _, distribution := range []string{DelayUniform, DelayNormal, DelayLongTail} {
+		spec := DelaySpec{Distribution: distribution, Base: time.Second, Max: time.Minute, StdDev: time.Second}
+		a, b, other := newRand(7), newRand(7), newRand(8)
+
+		var differs bool
+		for range 20 {
+			x, y, z := spec.Sample(a), spec.Sample(b), spec.Sample(other)
+			if x != y {
+				t.Fatalf("%s: same seed sampled %s and %s", distribution, x, y)
+			}
+			differs = differs || x != z
+		}
+		if !differs {
+			t.Errorf("%s: different seeds sampled the same sequence", distribution)
+		}
+	}
+}
+
+func TestDelaySpecWithTags(t *testing.T) {
+	base := delaySpecFromConfig(testConfig(t))
+
+	tests := []struct {
+		name    string
+		tags    map[string]string
+		want    DelaySpec
+		wantErr bool
+	}{
+		{
+			name: "no tags keeps config",
+			want: base,
+		},
+		{
+			name: "overrides",
+			tags: map[string]string{
+				tagDelayDistribution:  DelayNormal,
+				tagDelayMs:            "1500",
+				tagDelayMaxMs:         "9000",
+				tagDelayStdDevMs:      "250",
+				tagDelayExceedHoldTTL: "true",
+			},
+			want: DelaySpec{
+				Distribution:  DelayNormal,
+				Base:          1500 * time.Millisecond,
+				Max:           9 * time.Second,
+				StdDev:        250 * time.Millisecond,
+				ExceedHoldTTL: true,
+				HoldTTL:       base.HoldTTL,
+			},
+		},
+		{name: "unknown distribution", tags: map[string]string{tagDelayDistribution: "bimodal"}, wantErr: true},
+		{name: "delay not a number", tags: map[string]string{tagDelayMs: "1s"}, wantErr: true},
+		{name: "negative delay", tags: map[string]string{tagDelayMaxMs: "-1"}, wantErr: true},
+		{name: "stddev not a number", tags: map[string]string{tagDelayStdDevMs: "wide"}, wantErr: true},
+		{name: "exceed hold ttl not a bool", tags: map[string]string{tagDelayExceedHoldTTL: "sometimes"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := base.withTags(tt.tags)
+			if tt.wantErr {
+				if !errors.Is(err, ErrInvalidTag) {
+					t.Errorf("withTags err = %v, want %v", err, ErrInvalidTag)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("withTags: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("withTags = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDetermineDelayExceedsHoldTTL(t *testing.T) {
+	cfg := testConfig(t)
+	cfg.DelayExceedHoldTTL = true
+	s := newTestService(t, cfg, &fakeSender{})
+	holdTTL := time.Duration(cfg.ReservationHoldTTLMs) * time.Millisecond
+
+	for seed := range uint64(100) {
+		delay, err := s.determineDelay(paymentv1.PaymentScenario_PAYMENT_SCENARIO_DELAY, nil, newRand(seed))
+		if err != nil {
+			t.Fatalf("determineDelay: %v", err)
+		}
+		if delay <= holdTTL {
+			t.Fatalf("delay = %s, want past the %s reservation hold", delay, holdTTL)
+		}
+	}
+
+	// DELAY가 아닌 시나리오는 hold TTL을 넘기지 않는다
+	delay, err := s.determineDelay(paymentv1.PaymentScenario_PAYMENT_SCENARIO_APPROVE, nil, newRand(1))
+	if err != nil || delay != time.Duration(cfg.DefaultDelayMs)*time.Millisecond {
+		t.Errorf("APPROVE delay = %s, %v, want the default delay", delay, err)
+	}
+
+	// 태그로 끌 수 있다
+	tags := map[string]string{tagDelayExceedHoldTTL: "false", tagDelayDistribution: DelayFixed, tagDelayMs: "10"}
+	if delay, err := s.determineDelay(paymentv1.PaymentScenario_PAYMENT_SCENARIO_DELAY, tags, newRand(1)); err != nil || delay != 10*time.Millisecond {
+		t.Errorf("delay with exceed tag off = %s, %v, want 10ms", delay, err)
+	}
+}
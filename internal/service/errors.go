@@ -0,0 +1,141 @@
+package service
+
+import (
+	"context"
+	"errors"
+)
+
+// Kind는 transport와 상관없이 도메인 에러를 분류한다. gRPC 서버는 kind마다
+// status code를 대응시킨다.
+type Kind int
+
+const (
+	KindInternal Kind = iota
+	KindInvalidArgument
+	KindNotFound
+	KindAlreadyExists
+	KindFailedPrecondition
+	KindAborted
+	KindResourceExhausted
+	KindUnavailable
+	KindCanceled
+	KindDeadlineExceeded
+)
+
+var kindNames = [...]string{
+	KindInternal:           "internal",
+	KindInvalidArgument:    "invalid_argument",
+	KindNotFound:           "not_found",
+	KindAlreadyExists:      "already_exists",
+	KindFailedPrecondition: "failed_precondition",
+	KindAborted:            "aborted",
+	KindResourceExhausted:  "resource_exhausted",
+	KindUnavailable:        "unavailable",
+	KindCanceled:           "canceled",
+	KindDeadlineExceeded:   "deadline_exceeded",
+}
+
+func (k Kind) String() string {
+	if k < 0 || int(k) >= len(kindNames) {
+		return kindNames[KindInternal]
+	}
+	return kindNames[k]
+}
+
+// kindError는 service가 의존하는 패키지(webhook dispatcher 등)의 에러가
+// 스스로를 분류할 때 구현한다. 그래서 Classify는 그 sentinel을 몰라도 된다.
+// ErrorKind는 Kind 이름이다 (Kind.String 참고).
+type kindError interface {
+	error
+	ErrorKind() string
+	ErrorReason() string
+}
+
+func kindByName(name string) Kind {
+	for k, n := range kindNames {
+		if n == name {
+			return Kind(k)
+		}
+	}
+	return KindInternal
+}
+
+// Error는 kind와, 기계가 읽을 수 있는 고정 reason code(예:
+// PAYMENT_INTENT_NOT_FOUND)를 가진 sentinel 도메인 에러다. fmt.Errorf("%w")로
+// 감싸 문맥을 더해도 errors.Is와 Classify는 그대로 알아본다.
+type Error struct {
+	Kind   Kind
+	Reason string
+	msg    string
+}
+
+func newError(kind Kind, reason, msg string) *Error {
+	return &Error{Kind: kind, Reason: reason, msg: msg}
+}
+
+func (e *Error) Error() string {
+	return e.msg
+}
+
+// ErrInvalidTag는 시뮬레이션을 조정하는 metadata tag(scenario, delay_*,
+// capture_method 등)의 값이 잘못되었을 때 반환한다.
+var ErrInvalidTag = newError(KindInvalidArgument, "INVALID_METADATA_TAG", "invalid metadata tag")
+
+// errInternal은 도메인 에러가 아닌 에러의 분류다.
+var errInternal = newError(KindInternal, "INTERNAL", "internal error")
+
+// Classification은 transport가 에러를 보고하는 데 필요한 정보다.
+type Classification struct {
+	Kind   Kind
+	Reason string
+	// Message는 감싼 문맥을 뺀 에러의 카탈로그 메시지다. internal과
+	// unavailable 에러는 세부 내용이 클라이언트에 가면 안 되므로 transport는
+	// 전체 에러 대신 이 메시지를 보낸다.
+	Message    string
+	Metadata   map[string]string
+	Violations []FieldViolation // 요청 검증 실패 시 필드별 사유
+}
+
+// Classify는 err의 kind와 reason을 반환한다. 도메인 에러가 아니면 reason이
+// INTERNAL인 KindInternal이다.
+func Classify(err error) Classification {
+	var validationErr *ValidationError
+	if errors.As(err, &validationErr) {
+		return Classification{
+			Kind:       KindInvalidArgument,
+			Reason:     "INVALID_REQUEST",
+			Violations: validationErr.Violations,
+		}
+	}
+
+	var transitionErr *TransitionError
+	if errors.As(err, &transitionErr) {
+		return Classification{
+			Kind:   KindFailedPrecondition,
+			Reason: "INVALID_STATUS_TRANSITION",
+			Metadata: map[string]string{
+				"payment_intent_id": transitionErr.PaymentID,
+				"from":              statusName(transitionErr.From),
+				"to":                statusName(transitionErr.To),
+			},
+		}
+	}
+
+	var domainErr *Error
+	if errors.As(err, &domainErr) {
+		return Classification{Kind: domainErr.Kind, Reason: domainErr.Reason, Message: domainErr.msg}
+	}
+
+	var kindErr kindError
+	if errors.As(err, &kindErr) {
+		return Classification{Kind: kindByName(kindErr.ErrorKind()), Reason: kindErr.ErrorReason(), Message: kindErr.Error()}
+	}
+
+	switch {
+	case errors.Is(err, context.Canceled):
+		return Classification{Kind: KindCanceled, Reason: "CANCELED", Message: err.Error()}
+	case errors.Is(err, context.DeadlineExceeded):
+		return Classification{Kind: KindDeadlineExceeded, Reason: "DEADLINE_EXCEEDED", Message: err.Error()}
+	}
+	return Classification{Kind: errInternal.Kind, Reason: errInternal.Reason, Message: errInternal.msg}
+}
@@ -0,0 +1,427 @@
+package service
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+
+	commonv1 "github.com/traffic-tacos/proto-contracts/gen/go/common/v1"
+	paymentv1 "github.com/traffic-tacos/proto-contracts/gen/go/payment/v1"
+)
+
+// FileStore is an append-only log of intent snapshots (one JSON line per
+// write) backed by an in-memory index. Every write is appended and synced to
+// the log before it becomes visible in memory. The log is replayed and
+// compacted when the store is opened, and compacted again once it grows past
+// compactRecords lines or compactBytes bytes with at least half of it stale.
+type FileStore struct {
+	logger *zap.Logger
+	mem    *MemoryStore
+	path   string
+
+	compactRecords int
+	compactBytes   int64
+
+	mu      sync.Mutex
+	file    *os.File
+	records int   // 현재 로그의 줄 수
+	size    int64 // 현재 로그의 크기
+	torn    bool  // 실패한 쓰기의 잔여 바이트를 아직 잘라내지 못함
+}
+
+type intentRecord struct {
+	ID            string             `json:"id"`
+	Version       int64              `json:"version"`
+	ReservationID string             `json:"reservation_id"`
+	UserID        string             `json:"user_id"`
+	Amount        int64              `json:"amount"`
+	Currency      string             `json:"currency"`
+	Status        string             `json:"status"`
+	Scenario      string             `json:"scenario"`
+	WebhookURL    string             `json:"webhook_url"`
+	Tags          map[string]string  `json:"tags,omitempty"`
+	RandomSeed    uint64             `json:"random_seed"`
+	CreatedAt     time.Time          `json:"created_at"`
+	UpdatedAt     time.Time          `json:"updated_at"`
+	ProcessedAt   *time.Time         `json:"processed_at,omitempty"`
+	History       []transitionRecord `json:"history,omitempty"`
+	Refunds       []Refund           `json:"refunds,omitempty"`
+
+	CaptureMethod  string     `json:"capture_method,omitempty"`
+	AuthorizedAt   *time.Time `json:"authorized_at,omitempty"`
+	CapturedAmount int64      `json:"captured_amount,omitempty"`
+
+	IdempotencyKey string `json:"idempotency_key,omitempty"`
+	RequestHash    string `json:"request_hash,omitempty"`
+	ResponseStatus string `json:"response_status,omitempty"`
+	DuplicateOf    string `json:"duplicate_of,omitempty"`
+	FailureCode    string `json:"failure_code,omitempty"`
+}
+
+type transitionRecord struct {
+	From   string    `json:"from"`
+	To     string    `json:"to"`
+	Reason string    `json:"reason,omitempty"`
+	At     time.Time `json:"at"`
+}
+
+// NewFileStore opens the log at path. A compactRecords or compactBytes of 0
+// disables that compaction threshold.
+func NewFileStore(logger *zap.Logger, path string, compactRecords int, compactBytes int64) (*FileStore, error) {
+	if dir := filepath.Dir(path); dir != "" {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return nil, fmt.Errorf("failed to create intent store directory: %w", err)
+		}
+	}
+
+	s := &FileStore{
+		logger:         logger,
+		mem:            NewMemoryStore(),
+		path:           path,
+		compactRecords: compactRecords,
+		compactBytes:   compactBytes,
+	}
+	if err := s.replay(); err != nil {
+		return nil, err
+	}
+	if err := s.compact(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *FileStore) Get(ctx context.Context, id string) (*PaymentIntent, error) {
+	return s.mem.Get(ctx, id)
+}
+
+func (s *FileStore) Put(ctx context.Context, intent *PaymentIntent) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// s.mu가 모든 쓰기를 직렬화하므로 검사 후 로그에 먼저 기록해도 메모리 반영은 실패하지 않는다
+	if s.mem.version(intent.ID) != 0 {
+		return fmt.Errorf("%w: %s", ErrIntentExists, intent.ID)
+	}
+	next := intent.clone()
+	next.Version = 1
+	if err := s.append(next); err != nil {
+		return err
+	}
+	if err := s.mem.Put(ctx, intent); err != nil {
+		return err
+	}
+	s.maybeCompact()
+	return nil
+}
+
+func (s *FileStore) Update(ctx context.Context, intent *PaymentIntent) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	switch stored := s.mem.version(intent.ID); {
+	case stored == 0:
+		return fmt.Errorf("%w: %s", ErrIntentNotFound, intent.ID)
+	case stored != intent.Version:
+		return fmt.Errorf("%w: %s (expected v%d, stored v%d)", ErrVersionConflict, intent.ID, intent.Version, stored)
+	}
+	next := intent.clone()
+	next.Version++
+	if err := s.append(next); err != nil {
+		return err
+	}
+	if err := s.mem.Update(ctx, intent); err != nil {
+		return err
+	}
+	s.maybeCompact()
+	return nil
+}
+
+func (s *FileStore) List(ctx context.Context) ([]*PaymentIntent, error) {
+	return s.mem.List(ctx)
+}
+
+func (s *FileStore) ListByReservation(ctx context.Context, reservationID string) ([]*PaymentIntent, error) {
+	return s.mem.ListByReservation(ctx, reservationID)
+}
+
+// Sync flushes the log to stable storage. Put and Update already sync each
+// record they append.
+func (s *FileStore) Sync() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.file.Sync()
+}
+
+func (s *FileStore) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.file.Sync(); err != nil {
+		s.file.Close()
+		return fmt.Errorf("failed to sync intent store: %w", err)
+	}
+	return s.file.Close()
+}
+
+// append writes and syncs one snapshot. A failed write or sync is cut off the
+// log again, so the next record does not land on a torn line. Callers must
+// hold s.mu.
+func (s *FileStore) append(intent *PaymentIntent) error {
+	if s.torn {
+		if err := s.truncateTail(); err != nil {
+			return fmt.Errorf("intent store log has an unrepaired partial record: %w", err)
+		}
+	}
+
+	line, err := json.Marshal(toIntentRecord(intent))
+	if err != nil {
+		return fmt.Errorf("failed to marshal payment intent: %w", err)
+	}
+	n, err := s.file.Write(append(line, '\n'))
+	if err != nil {
+		s.discardTail(n)
+		return fmt.Errorf("failed to append payment intent: %w", err)
+	}
+	if err := s.file.Sync(); err != nil {
+		s.discardTail(n)
+		return fmt.Errorf("failed to sync intent store: %w", err)
+	}
+	s.size += int64(n)
+	s.records++
+	return nil
+}
+
+// discardTail removes the n bytes a failed append left after s.size. When
+// that fails too, appends are refused until truncateTail succeeds.
+func (s *FileStore) discardTail(n int) {
+	if err := s.truncateTail(); err != nil {
+		s.logger.Error("Failed to discard partial intent store record",
+			zap.String("path", s.path),
+			zap.Int("bytes", n),
+			zap.Error(err))
+	}
+}
+
+func (s *FileStore) truncateTail() error {
+	if err := s.file.Truncate(s.size); err != nil {
+		s.torn = true
+		return err
+	}
+	s.torn = false
+	return nil
+}
+
+// maybeCompact compacts the log once it passes a threshold and at least half
+// of its lines are superseded snapshots. The write that triggered it is
+// already durable, so a failed compaction is only logged and retried on the
+// next write. Callers must hold s.mu.
+func (s *FileStore) maybeCompact() {
+	exceeded := (s.compactRecords > 0 && s.records >= s.compactRecords) ||
+		(s.compactBytes > 0 && s.size >= s.compactBytes)
+	if !exceeded || s.records < 2*s.mem.len() {
+		return
+	}
+
+	records, size := s.records, s.size
+	if err := s.compact(); err != nil {
+		s.logger.Error("Failed to compact intent store", zap.String("path", s.path), zap.Error(err))
+		return
+	}
+	s.logger.Info("Intent store compacted",
+		zap.String("path", s.path),
+		zap.Int("records_before", records),
+		zap.Int64("bytes_before", size),
+		zap.Int("records", s.records),
+		zap.Int64("bytes", s.size))
+}
+
+func (s *FileStore) replay() error {
+	f, err := os.Open(s.path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to open intent store: %w", err)
+	}
+	defer f.Close()
+
+	// 마지막 줄은 쓰다 만 상태일 수 있으므로 건너뛰지만, 그 뒤에 줄이 더 있으면
+	// 확인된 쓰기가 손상된 것이므로 열지 않는다
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 4*1024*1024)
+	var (
+		lineNo     int
+		corrupt    int
+		corruptErr error
+	)
+	for scanner.Scan() {
+		lineNo++
+		if corruptErr != nil {
+			return fmt.Errorf("intent store %s is corrupt at line %d: %w", s.path, corrupt, corruptErr)
+		}
+		var record intentRecord
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			corrupt, corruptErr = lineNo, err
+			continue
+		}
+		s.mem.load(record.toIntent())
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("failed to read intent store: %w", err)
+	}
+	if corruptErr != nil {
+		s.logger.Warn("Skipping partial last record of intent store",
+			zap.String("path", s.path),
+			zap.Int("line", corrupt),
+			zap.Error(corruptErr))
+	}
+	return nil
+}
+
+// compact rewrites the log with only the latest snapshot of each intent and
+// reopens it for appending. The previous log stays open until the new one
+// replaces it.
+func (s *FileStore) compact() error {
+	intents, err := s.mem.List(context.Background())
+	if err != nil {
+		return err
+	}
+
+	tmpPath := s.path + ".tmp"
+	tmp, err := os.Create(tmpPath)
+	if err != nil {
+		return fmt.Errorf("failed to create compacted intent store: %w", err)
+	}
+	w := bufio.NewWriter(tmp)
+	var size int64
+	for _, intent := range intents {
+		line, err := json.Marshal(toIntentRecord(intent))
+		if err != nil {
+			tmp.Close()
+			return fmt.Errorf("failed to marshal payment intent: %w", err)
+		}
+		n, _ := w.Write(append(line, '\n'))
+		size += int64(n)
+	}
+	if err := w.Flush(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write compacted intent store: %w", err)
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to sync compacted intent store: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmpPath, s.path); err != nil {
+		return fmt.Errorf("failed to replace intent store: %w", err)
+	}
+
+	file, err := os.OpenFile(s.path, os.O_APPEND|os.O_WRONLY|os.O_CREATE, 0o644)
+	if err != nil {
+		return fmt.Errorf("failed to open intent store for append: %w", err)
+	}
+	if s.file != nil {
+		s.file.Close()
+	}
+	s.file = file
+	s.records, s.size = len(intents), size
+	return nil
+}
+
+func toIntentRecord(intent *PaymentIntent) intentRecord {
+	record := intentRecord{
+		ID:            intent.ID,
+		Version:       intent.Version,
+		ReservationID: intent.ReservationID,
+		UserID:        intent.UserID,
+		Status:        statusName(intent.Status),
+		Scenario:      intent.Scenario.String(),
+		WebhookURL:    intent.WebhookURL,
+		Tags:          intent.Tags,
+		RandomSeed:    intent.RandomSeed,
+		CreatedAt:     intent.CreatedAt,
+		UpdatedAt:     intent.UpdatedAt,
+		ProcessedAt:   intent.ProcessedAt,
+		Refunds:       intent.Refunds,
+
+		CaptureMethod:  intent.CaptureMethod,
+		AuthorizedAt:   intent.AuthorizedAt,
+		CapturedAmount: intent.CapturedAmount,
+		IdempotencyKey: intent.IdempotencyKey,
+		RequestHash:    intent.RequestHash,
+		DuplicateOf:    intent.DuplicateOf,
+		FailureCode:    intent.FailureCode,
+	}
+	if intent.Amount != nil {
+		record.Amount = intent.Amount.Amount
+		record.Currency = intent.Amount.Currency
+	}
+	if intent.ResponseStatus != paymentv1.PaymentStatus_PAYMENT_STATUS_UNSPECIFIED {
+		record.ResponseStatus = statusName(intent.ResponseStatus)
+	}
+	for _, t := range intent.History {
+		record.History = append(record.History, transitionRecord{
+			From:   statusName(t.From),
+			To:     statusName(t.To),
+			Reason: t.Reason,
+			At:     t.At,
+		})
+	}
+	return record
+}
+
+func (r intentRecord) toIntent() *PaymentIntent {
+	intent := &PaymentIntent{
+		ID:            r.ID,
+		Version:       r.Version,
+		ReservationID: r.ReservationID,
+		UserID:        r.UserID,
+		Amount:        &commonv1.Money{Amount: r.Amount, Currency: r.Currency},
+		Status:        statusFromName(r.Status),
+		Scenario:      paymentv1.PaymentScenario(paymentv1.PaymentScenario_value[r.Scenario]),
+		WebhookURL:    r.WebhookURL,
+		Tags:          r.Tags,
+		RandomSeed:    r.RandomSeed,
+		CreatedAt:     r.CreatedAt,
+		UpdatedAt:     r.UpdatedAt,
+		ProcessedAt:   r.ProcessedAt,
+		Refunds:       r.Refunds,
+
+		CaptureMethod:  r.CaptureMethod,
+		AuthorizedAt:   r.AuthorizedAt,
+		CapturedAmount: r.CapturedAmount,
+		IdempotencyKey: r.IdempotencyKey,
+		RequestHash:    r.RequestHash,
+		DuplicateOf:    r.DuplicateOf,
+		FailureCode:    r.FailureCode,
+
+		// response_status 이전 레코드: 생성 응답은 항상 PENDING이었다
+		ResponseStatus: paymentv1.PaymentStatus_PAYMENT_STATUS_PENDING,
+	}
+	if r.ResponseStatus != "" {
+		intent.ResponseStatus = statusFromName(r.ResponseStatus)
+	}
+	for _, t := range r.History {
+		intent.History = append(intent.History, StatusTransition{
+			From:   statusFromName(t.From),
+			To:     statusFromName(t.To),
+			Reason: t.Reason,
+			At:     t.At,
+		})
+	}
+	return intent
+}
+
+// statusFromName maps unknown names to UNSPECIFIED, like a proto enum lookup.
+func statusFromName(name string) paymentv1.PaymentStatus {
+	status, _ := parseStatus(name)
+	return status
+}
@@ -0,0 +1,303 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+
+	commonv1 "github.com/traffic-tacos/proto-contracts/gen/go/common/v1"
+	paymentv1 "github.com/traffic-tacos/proto-contracts/gen/go/payment/v1"
+)
+
+func newTestIntent(id string) *PaymentIntent {
+	now := time.Now().UTC().Truncate(time.Millisecond)
+	return &PaymentIntent{
+		ID:            id,
+		ReservationID: "rsv-" + id,
+		UserID:        "user-1",
+		Amount:        &commonv1.Money{Amount: 10000, Currency: "KRW"},
+		Status:        pending,
+		Tags:          map[string]string{tagMerchantID: "m-1"},
+		CreatedAt:     now,
+		UpdatedAt:     now,
+	}
+}
+
+func openTestFileStore(t *testing.T, path string, compactRecords int) *FileStore {
+	t.Helper()
+	s, err := NewFileStore(zap.NewNop(), path, compactRecords, 0)
+	if err != nil {
+		t.Fatalf("NewFileStore: %v", err)
+	}
+	return s
+}
+
+func TestFileStoreReplayAfterReopen(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "intents.log")
+	m := NewStateMachine()
+
+	s := openTestFileStore(t, path, 0)
+	for _, id := range []string{"pay-1", "pay-2"} {
+		if err := s.Put(ctx, newTestIntent(id)); err != nil {
+			t.Fatalf("Put(%s): %v", id, err)
+		}
+	}
+	intent, err := s.Get(ctx, "pay-1")
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	for _, to := range []paymentv1.PaymentStatus{processing, StatusAuthorized, completed} {
+		if err := m.Apply(intent, to, "test"); err != nil {
+			t.Fatalf("Apply: %v", err)
+		}
+		if err := s.Update(ctx, intent); err != nil {
+			t.Fatalf("Update: %v", err)
+		}
+	}
+	intent.CapturedAmount = 5000
+	if err := s.Update(ctx, intent); err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+	if err := s.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	reopened := openTestFileStore(t, path, 0)
+	defer reopened.Close()
+
+	tests := []struct {
+		id       string
+		status   paymentv1.PaymentStatus
+		version  int64
+		history  int
+		captured int64
+	}{
+		{"pay-1", completed, 5, 3, 5000},
+		{"pay-2", pending, 1, 0, 0},
+	}
+	for _, tt := range tests {
+		got, err := reopened.Get(ctx, tt.id)
+		if err != nil {
+			t.Fatalf("Get(%s) after reopen: %v", tt.id, err)
+		}
+		if got.Status != tt.status || got.Version != tt.version || len(got.History) != tt.history || got.CapturedAmount != tt.captured {
+			t.Errorf("%s after reopen = status %s v%d history %d captured %d, want %s v%d history %d captured %d",
+				tt.id, statusName(got.Status), got.Version, len(got.History), got.CapturedAmount,
+				statusName(tt.status), tt.version, tt.history, tt.captured)
+		}
+		if got.Amount.GetAmount() != 10000 || got.Tags[tagMerchantID] != "m-1" {
+			t.Errorf("%s lost fields on replay: amount %v, tags %v", tt.id, got.Amount, got.Tags)
+		}
+	}
+	if reopened.records != 2 {
+		t.Errorf("log has %d records after reopen, want 2 (compacted)", reopened.records)
+	}
+	if byReservation, _ := reopened.ListByReservation(ctx, "rsv-pay-2"); len(byReservation) != 1 {
+		t.Errorf("reservation index after reopen has %d intents, want 1", len(byReservation))
+	}
+}
+
+func TestFileStoreVersionConflict(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "intents.log")
+	s := openTestFileStore(t, path, 0)
+	defer s.Close()
+
+	if err := s.Put(ctx, newTestIntent("pay-1")); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+	if err := s.Put(ctx, newTestIntent("pay-1")); !errors.Is(err, ErrIntentExists) {
+		t.Errorf("second Put error = %v, want ErrIntentExists", err)
+	}
+
+	first, _ := s.Get(ctx, "pay-1")
+	second, _ := s.Get(ctx, "pay-1")
+	first.Status = processing
+	if err := s.Update(ctx, first); err != nil {
+		t.Fatalf("first Update: %v", err)
+	}
+	second.Status = cancelled
+	if err := s.Update(ctx, second); !errors.Is(err, ErrVersionConflict) {
+		t.Fatalf("stale Update error = %v, want ErrVersionConflict", err)
+	}
+
+	missing := newTestIntent("pay-missing")
+	if err := s.Update(ctx, missing); !errors.Is(err, ErrIntentNotFound) {
+		t.Errorf("Update of unknown intent error = %v, want ErrIntentNotFound", err)
+	}
+
+	// 거절된 쓰기는 로그에도 남지 않아야 한다
+	if s.records != 2 {
+		t.Errorf("log has %d records, want 2 (put + first update)", s.records)
+	}
+	s.Close()
+	reopened := openTestFileStore(t, path, 0)
+	defer reopened.Close()
+	got, _ := reopened.Get(ctx, "pay-1")
+	if got.Status != processing || got.Version != 2 {
+		t.Errorf("after reopen = %s v%d, want PROCESSING v2", statusName(got.Status), got.Version)
+	}
+}
+
+func TestFileStoreAppendFailureLeavesMemory(t *testing.T) {
+	ctx := context.Background()
+	s := openTestFileStore(t, filepath.Join(t.TempDir(), "intents.log"), 0)
+
+	if err := s.Put(ctx, newTestIntent("pay-1")); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+	s.file.Close() // 이후 append는 실패한다
+
+	intent, _ := s.Get(ctx, "pay-1")
+	intent.Status = processing
+	if err := s.Update(ctx, intent); err == nil {
+		t.Fatal("Update succeeded without a writable log")
+	}
+	if err := s.Put(ctx, newTestIntent("pay-2")); err == nil {
+		t.Fatal("Put succeeded without a writable log")
+	}
+
+	got, _ := s.Get(ctx, "pay-1")
+	if got.Status != pending || got.Version != 1 {
+		t.Errorf("memory = %s v%d after failed append, want PENDING v1", statusName(got.Status), got.Version)
+	}
+	if _, err := s.Get(ctx, "pay-2"); !errors.Is(err, ErrIntentNotFound) {
+		t.Errorf("Get of unlogged intent error = %v, want ErrIntentNotFound", err)
+	}
+}
+
+func TestFileStoreCompactsAtThreshold(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "intents.log")
+	s := openTestFileStore(t, path, 10)
+	defer s.Close()
+
+	if err := s.Put(ctx, newTestIntent("pay-1")); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+	intent, _ := s.Get(ctx, "pay-1")
+	for i := 0; i < 9; i++ {
+		intent.UpdatedAt = intent.UpdatedAt.Add(time.Second)
+		if err := s.Update(ctx, intent); err != nil {
+			t.Fatalf("Update %d: %v", i, err)
+		}
+	}
+
+	// 10번째 기록에서 compaction되어 최신 snapshot 하나만 남는다
+	if s.records != 1 {
+		t.Errorf("log has %d records after reaching the threshold, want 1", s.records)
+	}
+	if err := s.Update(ctx, intent); err != nil {
+		t.Fatalf("Update after compaction: %v", err)
+	}
+	s.Close()
+
+	reopened := openTestFileStore(t, path, 10)
+	defer reopened.Close()
+	got, _ := reopened.Get(ctx, "pay-1")
+	if got.Version != 11 {
+		t.Errorf("version after reopen = %d, want 11", got.Version)
+	}
+}
+
+func TestPaymentIntentCloneIsDeep(t *testing.T) {
+	original := newTestIntent("pay-1")
+	c := original.clone()
+
+	c.Amount.Amount = 1
+	c.Tags[tagMerchantID] = "changed"
+	if original.Amount.Amount != 10000 {
+		t.Errorf("clone shares Amount: original amount = %d", original.Amount.Amount)
+	}
+	if original.Tags[tagMerchantID] != "m-1" {
+		t.Errorf("clone shares Tags: original merchant_id = %q", original.Tags[tagMerchantID])
+	}
+}
+
+func TestFileStoreReplayCorruptLines(t *testing.T) {
+	tests := []struct {
+		name    string
+		tail    string // 정상 레코드 두 개 뒤에 덧붙일 내용
+		wantErr bool
+	}{
+		{name: "partial last line", tail: `{"id":"pay-3","vers`},
+		{name: "corrupt line before a record", tail: "{\"id\":\"pay-3\",\"vers\n" + `{"id":"pay-4","version":1}` + "\n", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			path := filepath.Join(t.TempDir(), "intents.log")
+			s := openTestFileStore(t, path, 0)
+			for _, id := range []string{"pay-1", "pay-2"} {
+				if err := s.Put(ctx, newTestIntent(id)); err != nil {
+					t.Fatalf("Put(%s): %v", id, err)
+				}
+			}
+			s.Close()
+			appendRaw(t, path, tt.tail)
+
+			reopened, err := NewFileStore(zap.NewNop(), path, 0, 0)
+			if tt.wantErr {
+				if err == nil {
+					reopened.Close()
+					t.Fatal("NewFileStore succeeded on a log corrupt in the middle")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("NewFileStore: %v", err)
+			}
+			defer reopened.Close()
+			for _, id := range []string{"pay-1", "pay-2"} {
+				if _, err := reopened.Get(ctx, id); err != nil {
+					t.Errorf("Get(%s) after reopen: %v", id, err)
+				}
+			}
+		})
+	}
+}
+
+func TestFileStoreDiscardsTornAppend(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "intents.log")
+	s := openTestFileStore(t, path, 0)
+
+	if err := s.Put(ctx, newTestIntent("pay-1")); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+	// 쓰다 만 레코드가 남은 상태에서 append 실패 처리
+	appendRaw(t, path, `{"id":"pay-torn","vers`)
+	s.discardTail(len(`{"id":"pay-torn","vers`))
+
+	if err := s.Put(ctx, newTestIntent("pay-2")); err != nil {
+		t.Fatalf("Put after discard: %v", err)
+	}
+	s.Close()
+
+	reopened := openTestFileStore(t, path, 0)
+	defer reopened.Close()
+	for _, id := range []string{"pay-1", "pay-2"} {
+		if _, err := reopened.Get(ctx, id); err != nil {
+			t.Errorf("Get(%s) after reopen: %v", id, err)
+		}
+	}
+}
+
+func appendRaw(t *testing.T, path, data string) {
+	t.Helper()
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		t.Fatalf("open log: %v", err)
+	}
+	defer f.Close()
+	if _, err := f.WriteString(data); err != nil {
+		t.Fatalf("write log: %v", err)
+	}
+}
@@ -0,0 +1,188 @@
+package service
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/protobuf/proto"
+
+	paymentv1 "github.com/traffic-tacos/proto-contracts/gen/go/payment/v1"
+)
+
+const (
+	// idempotencyKeyMetadata는 gateway가 HTTP Idempotency-Key 헤더를 전달하는 gRPC metadata 키다.
+	idempotencyKeyMetadata = "idempotency-key"
+
+	idempotencySweepInterval = time.Minute
+)
+
+var (
+	ErrIdempotencyKeyReused   = newError(KindAlreadyExists, "IDEMPOTENCY_KEY_REUSED", "idempotency key reused with a different request")
+	ErrIdempotencyKeyMismatch = newError(KindInvalidArgument, "IDEMPOTENCY_KEY_MISMATCH", "idempotency key in metadata does not match request")
+	ErrIdempotencyKeyInFlight = newError(KindAborted, "IDEMPOTENCY_KEY_IN_FLIGHT", "request with the same idempotency key is still in progress")
+)
+
+// idempotencyEntry is the outcome of the first request made with a key. done
+// is closed once that request finished; response stays nil if it failed.
+type idempotencyEntry struct {
+	hash      string
+	response  *paymentv1.CreatePaymentIntentResponse
+	done      chan struct{}
+	expiresAt time.Time
+}
+
+// IdempotencyStore remembers CreatePaymentIntent responses by idempotency key
+// for a fixed TTL. Failed requests release their key so they can be retried.
+type IdempotencyStore struct {
+	mu        sync.Mutex
+	ttl       time.Duration
+	entries   map[string]*idempotencyEntry
+	nextSweep time.Time
+}
+
+func NewIdempotencyStore(ttl time.Duration) *IdempotencyStore {
+	return &IdempotencyStore{
+		ttl:     ttl,
+		entries: make(map[string]*idempotencyEntry),
+	}
+}
+
+// begin claims key for a request with the given hash. It returns owner=true
+// when the caller must run the request and then call complete or abort;
+// otherwise entry belongs to an earlier request with the same payload.
+func (s *IdempotencyStore) begin(key, hash string) (entry *idempotencyEntry, owner bool, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	s.sweep(now)
+
+	if existing, ok := s.entries[key]; ok && now.Before(existing.expiresAt) {
+		if existing.hash != hash {
+			return nil, false, fmt.Errorf("%w: %s", ErrIdempotencyKeyReused, key)
+		}
+		return existing, false, nil
+	}
+
+	entry = &idempotencyEntry{
+		hash:      hash,
+		done:      make(chan struct{}),
+		expiresAt: now.Add(s.ttl),
+	}
+	s.entries[key] = entry
+	return entry, true, nil
+}
+
+func (s *IdempotencyStore) complete(entry *idempotencyEntry, response *paymentv1.CreatePaymentIntentResponse) {
+	s.mu.Lock()
+	entry.response = proto.Clone(response).(*paymentv1.CreatePaymentIntentResponse)
+	s.mu.Unlock()
+	close(entry.done)
+}
+
+// abort releases key after the owning request failed.
+func (s *IdempotencyStore) abort(key string, entry *idempotencyEntry) {
+	s.mu.Lock()
+	if s.entries[key] == entry {
+		delete(s.entries, key)
+	}
+	s.mu.Unlock()
+	close(entry.done)
+}
+
+// restore records a response created before a restart.
+func (s *IdempotencyStore) restore(key, hash string, createdAt time.Time, response *paymentv1.CreatePaymentIntentResponse) {
+	expiresAt := createdAt.Add(s.ttl)
+	if !time.Now().Before(expiresAt) {
+		return
+	}
+
+	entry := &idempotencyEntry{
+		hash:      hash,
+		response:  response,
+		done:      make(chan struct{}),
+		expiresAt: expiresAt,
+	}
+	close(entry.done)
+
+	s.mu.Lock()
+	s.entries[key] = entry
+	s.mu.Unlock()
+}
+
+// sweep drops expired keys at most once per idempotencySweepInterval.
+// Callers must hold s.mu.
+func (s *IdempotencyStore) sweep(now time.Time) {
+	if now.Before(s.nextSweep) {
+		return
+	}
+	s.nextSweep = now.Add(idempotencySweepInterval)
+	for key, entry := range s.entries {
+		if !now.Before(entry.expiresAt) {
+			delete(s.entries, key)
+		}
+	}
+}
+
+// wait blocks until the request that owns entry finished and returns its
+// response, or nil if it failed and the key was released.
+func (e *idempotencyEntry) wait(ctx context.Context) (*paymentv1.CreatePaymentIntentResponse, error) {
+	select {
+	case <-e.done:
+	case <-ctx.Done():
+		return nil, fmt.Errorf("%w: %v", ErrIdempotencyKeyInFlight, ctx.Err())
+	}
+	if e.response == nil {
+		return nil, nil
+	}
+	return proto.Clone(e.response).(*paymentv1.CreatePaymentIntentResponse), nil
+}
+
+// idempotencyKey returns the key from the request field or the
+// idempotency-key metadata. Both may be set but then must be equal.
+func idempotencyKey(ctx context.Context, req *paymentv1.CreatePaymentIntentRequest) (string, error) {
+	key := req.IdempotencyKey
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if values := md.Get(idempotencyKeyMetadata); len(values) > 0 && values[0] != "" {
+			if key != "" && key != values[0] {
+				return "", fmt.Errorf("%w: %q != %q", ErrIdempotencyKeyMismatch, values[0], key)
+			}
+			key = values[0]
+		}
+	}
+	return key, nil
+}
+
+// requestHash fingerprints everything in the request except the key itself.
+func requestHash(req *paymentv1.CreatePaymentIntentRequest) (string, error) {
+	c := proto.Clone(req).(*paymentv1.CreatePaymentIntentRequest)
+	c.IdempotencyKey = ""
+	data, err := proto.MarshalOptions{Deterministic: true}.Marshal(c)
+	if err != nil {
+		return "", fmt.Errorf("failed to hash request: %w", err)
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// restoreIdempotencyKeys reloads the keys of stored intents so retries after a
+// restart replay the same response as before it.
+func (s *PaymentService) restoreIdempotencyKeys() {
+	intents, err := s.store.List(context.Background())
+	if err != nil {
+		s.logger.Warn("Failed to restore idempotency keys", zap.Error(err))
+		return
+	}
+	for _, intent := range intents {
+		if intent.IdempotencyKey == "" {
+			continue
+		}
+		s.idempotency.restore(intent.IdempotencyKey, intent.RequestHash, intent.CreatedAt, creationResponse(intent))
+	}
+}
@@ -0,0 +1,235 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+	"google.golang.org/protobuf/proto"
+
+	commonv1 "github.com/traffic-tacos/proto-contracts/gen/go/common/v1"
+	paymentv1 "github.com/traffic-tacos/proto-contracts/gen/go/payment/v1"
+	"github.com/traffic-tacos/payment-sim-api/internal/scenario"
+)
+
+func idempotentRequest(key string) *paymentv1.CreatePaymentIntentRequest {
+	return &paymentv1.CreatePaymentIntentRequest{
+		ReservationId:  "rsv-1",
+		UserId:         "user-1",
+		Amount:         &commonv1.Money{Amount: 10000, Currency: "KRW"},
+		IdempotencyKey: key,
+	}
+}
+
+type createResult struct {
+	response *paymentv1.CreatePaymentIntentResponse
+	err      error
+}
+
+func TestIdempotencyReplayWhileInFlight(t *testing.T) {
+	tests := []struct {
+		name   string
+		finish func(s *PaymentService, key string, entry *idempotencyEntry)
+		cancel bool
+		// replayed: the waiter returns the first request's response;
+		// otherwise it creates the intent itself
+		replayed bool
+		wantErr  error
+	}{
+		{
+			name: "first request succeeds",
+			finish: func(s *PaymentService, key string, entry *idempotencyEntry) {
+				s.idempotency.complete(entry, &paymentv1.CreatePaymentIntentResponse{
+					PaymentIntentId: "pay-first",
+					Status:          pending,
+				})
+			},
+			replayed: true,
+		},
+		{
+			name: "first request fails",
+			finish: func(s *PaymentService, key string, entry *idempotencyEntry) {
+				s.idempotency.abort(key, entry)
+			},
+		},
+		{
+			name:    "waiter gives up",
+			cancel:  true,
+			wantErr: ErrIdempotencyKeyInFlight,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestService(t, testConfig(t), &fakeSender{})
+			req := idempotentRequest("key-1")
+			hash, err := requestHash(req)
+			if err != nil {
+				t.Fatalf("requestHash: %v", err)
+			}
+
+			// 최초 요청이 key를 잡고 처리 중인 상태
+			entry, owner, err := s.idempotency.begin("key-1", hash)
+			if err != nil || !owner {
+				t.Fatalf("begin = owner %v, %v", owner, err)
+			}
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			results := make(chan createResult, 1)
+			go func() {
+				response, err := s.CreatePaymentIntent(ctx, idempotentRequest("key-1"))
+				results <- createResult{response, err}
+			}()
+
+			select {
+			case result := <-results:
+				t.Fatalf("replay returned while the first request was in flight: %+v", result)
+			case <-time.After(50 * time.Millisecond):
+			}
+
+			if tt.cancel {
+				cancel()
+			} else {
+				tt.finish(s, "key-1", entry)
+			}
+
+			var result createResult
+			select {
+			case result = <-results:
+			case <-time.After(time.Second):
+				t.Fatal("replay still waiting after the first request finished")
+			}
+
+			if tt.wantErr != nil {
+				if !errors.Is(result.err, tt.wantErr) {
+					t.Fatalf("replay error = %v, want %v", result.err, tt.wantErr)
+				}
+				return
+			}
+			if result.err != nil {
+				t.Fatalf("replay: %v", result.err)
+			}
+
+			intents, _ := s.store.ListByReservation(context.Background(), "rsv-1")
+			if tt.replayed {
+				if result.response.PaymentIntentId != "pay-first" {
+					t.Errorf("replay = %s, want the first response", result.response.PaymentIntentId)
+				}
+				if len(intents) != 0 {
+					t.Errorf("replay created %d intents, want none", len(intents))
+				}
+				return
+			}
+			if len(intents) != 1 || intents[0].ID != result.response.PaymentIntentId {
+				t.Errorf("retry after failure created %d intents, want the returned one", len(intents))
+			}
+		})
+	}
+}
+
+func TestIdempotencyConcurrentRequests(t *testing.T) {
+	ctx := context.Background()
+	s := newTestService(t, testConfig(t), &fakeSender{})
+
+	const n = 16
+	results := make([]createResult, n)
+	var wg sync.WaitGroup
+	for i := range n {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			response, err := s.CreatePaymentIntent(ctx, idempotentRequest("key-1"))
+			results[i] = createResult{response, err}
+		}()
+	}
+	wg.Wait()
+
+	for i, result := range results {
+		if result.err != nil {
+			t.Fatalf("request %d: %v", i, result.err)
+		}
+		if result.response.PaymentIntentId != results[0].response.PaymentIntentId {
+			t.Errorf("request %d got %s, want %s", i, result.response.PaymentIntentId, results[0].response.PaymentIntentId)
+		}
+	}
+	if intents, _ := s.store.ListByReservation(ctx, "rsv-1"); len(intents) != 1 {
+		t.Errorf("store has %d intents, want 1", len(intents))
+	}
+
+	other := idempotentRequest("key-1")
+	other.Amount.Amount = 20000
+	if _, err := s.CreatePaymentIntent(ctx, other); !errors.Is(err, ErrIdempotencyKeyReused) {
+		t.Errorf("different payload error = %v, want ErrIdempotencyKeyReused", err)
+	}
+}
+
+// 재시작 전후의 재시도는 intent 상태가 바뀌었어도 같은 최초 응답을 받는다.
+func TestIdempotencyReplayAfterRestart(t *testing.T) {
+	tests := []struct {
+		name string
+		path []string // UpdatePaymentStatus calls before the retries
+	}{
+		{
+			name: "pending",
+		},
+		{
+			name: "completed",
+			path: []string{processing.String(), completed.String()},
+		},
+		{
+			name: "authorized",
+			path: []string{processing.String(), statusAuthorizedName},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			cfg := testConfig(t)
+			mix, err := scenario.ParseMix(cfg.DefaultScenario)
+			if err != nil {
+				t.Fatalf("ParseMix: %v", err)
+			}
+			path := filepath.Join(t.TempDir(), "intents.log")
+
+			store := openTestFileStore(t, path, 0)
+			s := NewPaymentService(zap.NewNop(), cfg, store, &fakeSender{}, nil, nil, nil, mix)
+			created, err := s.CreatePaymentIntent(ctx, idempotentRequest("key-1"))
+			if err != nil {
+				t.Fatalf("CreatePaymentIntent: %v", err)
+			}
+			for _, status := range tt.path {
+				if err := s.UpdatePaymentStatus(created.PaymentIntentId, status, ""); err != nil {
+					t.Fatalf("UpdatePaymentStatus(%s): %v", status, err)
+				}
+			}
+			inProcess, err := s.CreatePaymentIntent(ctx, idempotentRequest("key-1"))
+			if err != nil {
+				t.Fatalf("replay: %v", err)
+			}
+			if err := store.Close(); err != nil {
+				t.Fatalf("Close: %v", err)
+			}
+
+			// 같은 파일로 재시작한 서비스가 key와 최초 응답을 복원한다
+			reopened := openTestFileStore(t, path, 0)
+			defer reopened.Close()
+			restarted := NewPaymentService(zap.NewNop(), cfg, reopened, &fakeSender{}, nil, nil, nil, mix)
+			afterRestart, err := restarted.CreatePaymentIntent(ctx, idempotentRequest("key-1"))
+			if err != nil {
+				t.Fatalf("replay after restart: %v", err)
+			}
+
+			for name, replay := range map[string]*paymentv1.CreatePaymentIntentResponse{"in-process": inProcess, "after restart": afterRestart} {
+				if !proto.Equal(replay, created) {
+					t.Errorf("%s replay = %v, want the original response %v", name, replay, created)
+				}
+			}
+		})
+	}
+}
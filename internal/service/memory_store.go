@@ -0,0 +1,130 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+)
+
+type MemoryStore struct {
+	mu      sync.RWMutex
+	intents map[string]*PaymentIntent
+	// reservation ID → intent IDs (생성 순). ReservationID는 변경되지 않으므로 Put/load에서만 갱신
+	byReservation map[string][]string
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		intents:       make(map[string]*PaymentIntent),
+		byReservation: make(map[string][]string),
+	}
+}
+
+func (m *MemoryStore) Get(ctx context.Context, id string) (*PaymentIntent, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	intent, exists := m.intents[id]
+	if !exists {
+		return nil, fmt.Errorf("%w: %s", ErrIntentNotFound, id)
+	}
+	return intent.clone(), nil
+}
+
+func (m *MemoryStore) Put(ctx context.Context, intent *PaymentIntent) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, exists := m.intents[intent.ID]; exists {
+		return fmt.Errorf("%w: %s", ErrIntentExists, intent.ID)
+	}
+	intent.Version = 1
+	m.intents[intent.ID] = intent.clone()
+	m.index(intent)
+	return nil
+}
+
+func (m *MemoryStore) Update(ctx context.Context, intent *PaymentIntent) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	current, exists := m.intents[intent.ID]
+	if !exists {
+		return fmt.Errorf("%w: %s", ErrIntentNotFound, intent.ID)
+	}
+	if current.Version != intent.Version {
+		return fmt.Errorf("%w: %s (expected v%d, stored v%d)", ErrVersionConflict, intent.ID, intent.Version, current.Version)
+	}
+	intent.Version++
+	m.intents[intent.ID] = intent.clone()
+	return nil
+}
+
+func (m *MemoryStore) List(ctx context.Context) ([]*PaymentIntent, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	intents := make([]*PaymentIntent, 0, len(m.intents))
+	for _, intent := range m.intents {
+		intents = append(intents, intent.clone())
+	}
+	sort.Slice(intents, func(i, j int) bool {
+		return intents[i].CreatedAt.Before(intents[j].CreatedAt)
+	})
+	return intents, nil
+}
+
+func (m *MemoryStore) ListByReservation(ctx context.Context, reservationID string) ([]*PaymentIntent, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	ids := m.byReservation[reservationID]
+	intents := make([]*PaymentIntent, 0, len(ids))
+	for _, id := range ids {
+		intents = append(intents, m.intents[id].clone())
+	}
+	sort.SliceStable(intents, func(i, j int) bool {
+		return intents[i].CreatedAt.Before(intents[j].CreatedAt)
+	})
+	return intents, nil
+}
+
+func (m *MemoryStore) Close() error {
+	return nil
+}
+
+// version returns the stored version of an intent, or 0 if it does not exist.
+func (m *MemoryStore) version(id string) int64 {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	if intent, exists := m.intents[id]; exists {
+		return intent.Version
+	}
+	return 0
+}
+
+// len returns the number of stored intents.
+func (m *MemoryStore) len() int {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return len(m.intents)
+}
+
+// load replaces the stored intent unconditionally. Used when replaying a log.
+func (m *MemoryStore) load(intent *PaymentIntent) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, exists := m.intents[intent.ID]; !exists {
+		m.index(intent)
+	}
+	m.intents[intent.ID] = intent
+}
+
+// index adds a new intent to the reservation index. Callers must hold m.mu.
+func (m *MemoryStore) index(intent *PaymentIntent) {
+	if intent.ReservationID == "" {
+		return
+	}
+	m.byReservation[intent.ReservationID] = append(m.byReservation[intent.ReservationID], intent.ID)
+}
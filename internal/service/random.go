@@ -0,0 +1,87 @@
+package service
+
+import (
+	"fmt"
+	"hash/fnv"
+	"math/rand/v2"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/traffic-tacos/payment-sim-api/internal/config"
+)
+
+// 요청 metadata.tags로 intent별 난수 설정을 덮어쓸 수 있다.
+const (
+	tagRandomSeed    = "random_seed"
+	tagApprovalRatio = "approval_ratio"
+)
+
+// randomSource hands out one PRNG per payment intent. Every random decision
+// for an intent (RANDOM outcome, DELAY sampling) is drawn from a generator
+// seeded by the intent's seed, so an intent replays exactly from its logged
+// seed. Without RANDOM_SEED_FROM_RESERVATION the seeds themselves come from
+// one shared sequence, so replaying a whole run by RANDOM_SEED alone also
+// needs the requests to arrive in the same order.
+type randomSource struct {
+	seed           uint64
+	perReservation bool
+
+	mu     sync.Mutex
+	shared *rand.Rand
+}
+
+func newRandomSource(cfg *config.Config) *randomSource {
+	seed := uint64(cfg.RandomSeed)
+	if seed == 0 {
+		seed = uint64(time.Now().UnixNano())
+	}
+	return &randomSource{
+		seed:           seed,
+		perReservation: cfg.RandomSeedFromReservation,
+		shared:         newRand(seed),
+	}
+}
+
+// intentSeed picks the seed for a new intent: an explicit random_seed tag,
+// a hash of the reservation ID mixed with the global seed, or the next value
+// of the globally seeded sequence.
+func (r *randomSource) intentSeed(reservationID string, tags map[string]string) (uint64, error) {
+	if v, ok := tags[tagRandomSeed]; ok {
+		seed, err := strconv.ParseUint(v, 10, 64)
+		if err != nil {
+			return 0, fmt.Errorf("%w: %s=%q", ErrInvalidTag, tagRandomSeed, v)
+		}
+		return seed, nil
+	}
+
+	if r.perReservation {
+		h := fnv.New64a()
+		h.Write([]byte(reservationID))
+		return r.seed ^ h.Sum64(), nil
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.shared.Uint64(), nil
+}
+
+func newRand(seed uint64) *rand.Rand {
+	return rand.New(rand.NewPCG(seed, seed>>32|seed<<32))
+}
+
+// approvalRatio returns the RANDOM scenario approval probability for an intent.
+func approvalRatio(cfg *config.Config, tags map[string]string) (float64, error) {
+	ratio := cfg.RandomApprovalRatio
+	if v, ok := tags[tagApprovalRatio]; ok {
+		parsed, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			return 0, fmt.Errorf("%w: %s=%q", ErrInvalidTag, tagApprovalRatio, v)
+		}
+		ratio = parsed
+	}
+	if ratio < 0 || ratio > 1 {
+		return 0, fmt.Errorf("%w: approval ratio must be between 0 and 1: %v", ErrInvalidTag, ratio)
+	}
+	return ratio, nil
+}
@@ -0,0 +1,144 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"testing"
+
+	"github.com/traffic-tacos/payment-sim-api/internal/config"
+)
+
+// decided is everything the random source decides for one intent.
+type decided struct {
+	Scenario string
+	Status   string
+	Delay    string
+	Code     string
+}
+
+// decide creates an intent per reservation ID with an unspecified scenario and
+// returns what was decided for each, keyed by reservation ID.
+func decide(t *testing.T, cfg *config.Config, reservationIDs []string) map[string]decided {
+	t.Helper()
+	ctx := context.Background()
+	sender := &fakeSender{}
+	s := newTestService(t, cfg, sender)
+
+	out := make(map[string]decided, len(reservationIDs))
+	for _, reservationID := range reservationIDs {
+		req := reservationRequest(reservationID)
+		req.Scenario = 0
+		resp, err := s.CreatePaymentIntent(ctx, req)
+		if err != nil {
+			t.Fatalf("CreatePaymentIntent(%s): %v", reservationID, err)
+		}
+		intent, err := s.store.Get(ctx, resp.PaymentIntentId)
+		if err != nil {
+			t.Fatalf("Get: %v", err)
+		}
+		jobs := sender.jobsFor(resp.PaymentIntentId)
+		if len(jobs) != 1 {
+			t.Fatalf("%s has %d jobs, want 1", reservationID, len(jobs))
+		}
+		final := jobs[0].Steps[len(jobs[0].Steps)-1]
+		out[reservationID] = decided{
+			Scenario: intent.Scenario.String(),
+			Status:   final.Status,
+			Delay:    fmt.Sprint(final.After),
+			Code:     final.Code,
+		}
+	}
+	return out
+}
+
+// randomConfig draws every decision at random: the scenario from an even mix,
+// the RANDOM outcome and decline code, and the uniform DELAY.
+func randomConfig(t *testing.T, seed int64) *config.Config {
+	t.Helper()
+	cfg := testConfig(t)
+	cfg.RandomSeed = seed
+	cfg.DefaultScenario = "approve:1,fail:1,delay:1,random:1"
+	cfg.RandomApprovalRatio = 0.5
+	cfg.DelayDistribution = DelayUniform
+	return cfg
+}
+
+func reservationIDs(n int) []string {
+	ids := make([]string, n)
+	for i := range ids {
+		ids[i] = fmt.Sprintf("rsv-%d", i)
+	}
+	return ids
+}
+
+func TestRandomSeedReplaysRun(t *testing.T) {
+	ids := reservationIDs(40)
+	first := decide(t, randomConfig(t, 7), ids)
+	replay := decide(t, randomConfig(t, 7), ids)
+	other := decide(t, randomConfig(t, 8), ids)
+
+	for _, id := range ids {
+		if first[id] != replay[id] {
+			t.Errorf("%s: seed 7 decided %+v, then %+v", id, first[id], replay[id])
+		}
+	}
+	if fmt.Sprint(first) == fmt.Sprint(other) {
+		t.Error("seeds 7 and 8 decided the same run")
+	}
+
+	// 한 run 안에서 모든 결정이 실제로 난수에 따라 갈려야 의미가 있다
+	scenarios := make(map[string]bool)
+	codes := make(map[string]bool)
+	delays := make(map[string]bool)
+	for _, d := range first {
+		scenarios[d.Scenario] = true
+		if d.Code != "" {
+			codes[d.Code] = true
+		}
+		if d.Scenario == "PAYMENT_SCENARIO_DELAY" {
+			delays[d.Delay] = true
+		}
+	}
+	if len(scenarios) < 4 || len(codes) < 2 || len(delays) < 2 {
+		t.Errorf("run drew %d scenarios, %d decline codes, %d delays; want variety", len(scenarios), len(codes), len(delays))
+	}
+}
+
+func TestRandomSeedFromReservation(t *testing.T) {
+	ids := reservationIDs(40)
+	reversed := slices.Clone(ids)
+	slices.Reverse(reversed)
+
+	cfg := randomConfig(t, 7)
+	cfg.RandomSeedFromReservation = true
+	inOrder := decide(t, cfg, ids)
+	// 요청 순서와 관계없이 같은 예약은 같은 결과
+	outOfOrder := decide(t, cfg, reversed)
+
+	for _, id := range ids {
+		if inOrder[id] != outOfOrder[id] {
+			t.Errorf("%s: decided %+v in order, %+v out of order", id, inOrder[id], outOfOrder[id])
+		}
+	}
+
+	// 전역 seed가 다르면 같은 예약도 다른 결과
+	other := randomConfig(t, 8)
+	other.RandomSeedFromReservation = true
+	if fmt.Sprint(inOrder) == fmt.Sprint(decide(t, other, ids)) {
+		t.Error("seeds 7 and 8 decided the same outcomes per reservation")
+	}
+}
+
+func TestRandomSeedTagOverrides(t *testing.T) {
+	cfg := randomConfig(t, 0) // 시각 기반 seed
+	r := newRandomSource(cfg)
+
+	seed, err := r.intentSeed("rsv-1", map[string]string{tagRandomSeed: "12345"})
+	if err != nil || seed != 12345 {
+		t.Errorf("intentSeed = %d, %v, want the tagged seed", seed, err)
+	}
+	if _, err := r.intentSeed("rsv-1", map[string]string{tagRandomSeed: "-1"}); err == nil {
+		t.Error("intentSeed accepted a negative seed tag")
+	}
+}
@@ -0,0 +1,340 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	commonv1 "github.com/traffic-tacos/proto-contracts/gen/go/common/v1"
+	paymentv1 "github.com/traffic-tacos/proto-contracts/gen/go/payment/v1"
+	"github.com/traffic-tacos/payment-sim-api/internal/decline"
+	"github.com/traffic-tacos/payment-sim-api/internal/scenario"
+	"github.com/traffic-tacos/payment-sim-api/internal/webhook"
+)
+
+type RefundStatus string
+
+const (
+	RefundPending   RefundStatus = "PENDING"
+	RefundSucceeded RefundStatus = "SUCCEEDED"
+	RefundFailed    RefundStatus = "FAILED"
+)
+
+// 환불 시나리오. 환불 요청의 gRPC metadata로 환불마다 지정하거나,
+// intent 생성 시 metadata.tags로 그 결제의 기본값을 지정한다 (metadata가 우선).
+const (
+	tagRefundScenario = "refund_scenario" // approve|fail|delay
+	tagRefundDelayMs  = "refund_delay_ms"
+
+	refundScenarioMetadata = "x-refund-scenario"
+	refundDelayMetadata    = "x-refund-delay-ms"
+
+	refundScenarioApprove = "approve"
+	refundScenarioFail    = "fail"
+	refundScenarioDelay   = "delay"
+)
+
+var (
+	ErrRefundNotAllowed = newError(KindFailedPrecondition, "REFUND_NOT_ALLOWED", "payment cannot be refunded")
+	ErrRefundAmount     = newError(KindInvalidArgument, "INVALID_REFUND_AMOUNT", "invalid refund amount")
+	ErrRefundNotFound   = newError(KindNotFound, "REFUND_NOT_FOUND", "refund not found")
+)
+
+// Refund is a full or partial refund of a completed payment. Several partial
+// refunds may exist as long as their total stays within the captured amount.
+type Refund struct {
+	ID             string       `json:"id"`
+	Amount         int64        `json:"amount"`
+	Status         RefundStatus `json:"status"`
+	Reason         string       `json:"reason,omitempty"`
+	FailureCode    string       `json:"failure_code,omitempty"`
+	IdempotencyKey string       `json:"idempotency_key,omitempty"`
+	CreatedAt      time.Time    `json:"created_at"`
+	UpdatedAt      time.Time    `json:"updated_at"`
+}
+
+// capturedAmount is the amount that can be refunded in total.
+func (i *PaymentIntent) capturedAmount() int64 {
+	if i.CapturedAmount > 0 {
+		return i.CapturedAmount
+	}
+	return i.Amount.GetAmount()
+}
+
+// refundedAmount sums the succeeded refunds.
+func (i *PaymentIntent) refundedAmount() int64 {
+	var total int64
+	for _, refund := range i.Refunds {
+		if refund.Status == RefundSucceeded {
+			total += refund.Amount
+		}
+	}
+	return total
+}
+
+// refundableAmount is what is left after succeeded and pending refunds.
+func (i *PaymentIntent) refundableAmount() int64 {
+	remaining := i.capturedAmount()
+	for _, refund := range i.Refunds {
+		if refund.Status != RefundFailed {
+			remaining -= refund.Amount
+		}
+	}
+	return remaining
+}
+
+func (i *PaymentIntent) refund(id string) (*Refund, bool) {
+	for n := range i.Refunds {
+		if i.Refunds[n].ID == id {
+			return &i.Refunds[n], true
+		}
+	}
+	return nil, false
+}
+
+// CancelPayment은 아직 처리 중인 결제는 취소하고, 승인만 된 결제는 void하며, 완료된 결제는 환불한다.
+// refund_amount가 없으면 남은 금액 전체를 환불한다. 환불은 PENDING으로 즉시 응답하고,
+// 환불 시나리오에 따라 비동기로 확정된 뒤 payment.refunded / payment.refund_failed 이벤트와 webhook이 발송된다.
+func (s *PaymentService) CancelPayment(ctx context.Context, req *paymentv1.CancelPaymentRequest) (*paymentv1.CancelPaymentResponse, error) {
+	intent, err := s.store.Get(ctx, req.PaymentIntentId)
+	if err != nil {
+		return nil, err
+	}
+
+	switch intent.Status {
+	case paymentv1.PaymentStatus_PAYMENT_STATUS_PENDING,
+		paymentv1.PaymentStatus_PAYMENT_STATUS_PROCESSING:
+		return s.cancelPayment(ctx, req)
+	case StatusAuthorized:
+		return s.voidPayment(ctx, req)
+	case paymentv1.PaymentStatus_PAYMENT_STATUS_COMPLETED,
+		paymentv1.PaymentStatus_PAYMENT_STATUS_REFUNDED: // 전액 환불 후 같은 idempotency key 재요청
+		step, err := s.refundStep(ctx, intent)
+		if err != nil {
+			return nil, err
+		}
+		return s.refundPayment(ctx, req, step)
+	}
+	return nil, fmt.Errorf("%w: %s is %s", ErrIntentFinal, intent.ID, statusName(intent.Status))
+}
+
+func (s *PaymentService) refundPayment(ctx context.Context, req *paymentv1.CancelPaymentRequest, step scenario.Step) (*paymentv1.CancelPaymentResponse, error) {
+	var (
+		refund  Refund
+		created bool
+	)
+	intent, err := s.mutate(ctx, req.PaymentIntentId, func(intent *PaymentIntent) error {
+		// 같은 idempotency key로 재요청하면 기존 환불을 그대로 돌려준다
+		if req.IdempotencyKey != "" {
+			for _, existing := range intent.Refunds {
+				if existing.IdempotencyKey == req.IdempotencyKey {
+					refund, created = existing, false
+					return nil
+				}
+			}
+		}
+
+		if intent.Status != paymentv1.PaymentStatus_PAYMENT_STATUS_COMPLETED {
+			return fmt.Errorf("%w: %s is %s", ErrRefundNotAllowed, intent.ID, statusName(intent.Status))
+		}
+
+		amount, err := refundAmount(intent, req.RefundAmount)
+		if err != nil {
+			return err
+		}
+
+		now := time.Now()
+		refund = Refund{
+			ID:             uuid.New().String(),
+			Amount:         amount,
+			Status:         RefundPending,
+			Reason:         req.Reason,
+			IdempotencyKey: req.IdempotencyKey,
+			CreatedAt:      now,
+			UpdatedAt:      now,
+		}
+		intent.Refunds = append(intent.Refunds, refund)
+		intent.UpdatedAt = now
+		created = true
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if created {
+		s.logger.Info("Refund requested",
+			zap.String("payment_id", intent.ID),
+			zap.String("refund_id", refund.ID),
+			zap.Int64("amount", refund.Amount),
+			zap.String("reason", refund.Reason))
+
+		if err := s.scheduleRefund(intent, refund, step); err != nil {
+			return nil, err
+		}
+	}
+
+	return &paymentv1.CancelPaymentResponse{
+		Status:         intent.Status,
+		CancelledAt:    timestamppb.New(refund.CreatedAt),
+		RefundedAmount: &commonv1.Money{Amount: refund.Amount, Currency: intent.Amount.GetCurrency()},
+		RefundId:       refund.ID,
+	}, nil
+}
+
+func refundAmount(intent *PaymentIntent, requested *commonv1.Money) (int64, error) {
+	remaining := intent.refundableAmount()
+	if requested == nil {
+		if remaining <= 0 {
+			return 0, fmt.Errorf("%w: nothing left to refund", ErrRefundAmount)
+		}
+		return remaining, nil
+	}
+
+	if requested.Currency != "" && !strings.EqualFold(requested.Currency, intent.Amount.GetCurrency()) {
+		return 0, fmt.Errorf("%w: currency %s does not match payment currency %s",
+			ErrRefundAmount, requested.Currency, intent.Amount.GetCurrency())
+	}
+	if requested.Amount <= 0 {
+		return 0, fmt.Errorf("%w: amount must be positive", ErrRefundAmount)
+	}
+	if requested.Amount > remaining {
+		return 0, fmt.Errorf("%w: %d exceeds refundable amount %d", ErrRefundAmount, requested.Amount, remaining)
+	}
+	if err := checkIncrement(intent.Amount.GetCurrency(), requested.Amount); err != nil {
+		return 0, fmt.Errorf("%w: %v", ErrRefundAmount, err)
+	}
+	return requested.Amount, nil
+}
+
+func (s *PaymentService) scheduleRefund(intent *PaymentIntent, refund Refund, step scenario.Step) error {
+	if s.webhook == nil {
+		return nil
+	}
+	err := s.webhook.SchedulePayment(webhook.Job{
+		PaymentID:     intent.ID,
+		RefundID:      refund.ID,
+		ReservationID: intent.ReservationID,
+		MerchantID:    intent.Tags[tagMerchantID],
+		WebhookURL:    intent.WebhookURL,
+		Amount:        refund.Amount,
+		Currency:      intent.Amount.GetCurrency(),
+		Steps:         []scenario.Step{step},
+	})
+	if err != nil {
+		s.settleUnscheduledRefund(intent.ID, refund.ID, err)
+		return fmt.Errorf("failed to schedule refund: %w", err)
+	}
+	return nil
+}
+
+// settleUnscheduledRefund fails a refund that never reached the dispatcher so
+// its amount becomes refundable again. The refund gets a fixed failure code;
+// the cause is only logged.
+func (s *PaymentService) settleUnscheduledRefund(paymentID, refundID string, cause error) {
+	s.logger.Warn("Failed to schedule refund",
+		zap.String("payment_id", paymentID),
+		zap.String("refund_id", refundID),
+		zap.Error(cause))
+	if _, err := s.UpdateRefundStatus(paymentID, refundID, string(RefundFailed), decline.RefundScheduleFailed); err != nil {
+		s.logger.Error("Failed to mark unscheduled refund as failed",
+			zap.String("payment_id", paymentID),
+			zap.String("refund_id", refundID),
+			zap.Error(err))
+	}
+}
+
+// refundStep은 이번 환불 요청의 환불 시나리오를 단일 dispatcher step으로 변환한다.
+// 요청 metadata에 없으면 intent tag를 쓴다.
+func (s *PaymentService) refundStep(ctx context.Context, intent *PaymentIntent) (scenario.Step, error) {
+	step := scenario.Step{
+		Action: scenario.ActionTransition,
+		Status: string(RefundSucceeded),
+		After:  scenario.Duration(time.Duration(s.config.DefaultDelayMs) * time.Millisecond),
+	}
+
+	key, name, _ := refundOption(ctx, intent, refundScenarioMetadata, tagRefundScenario)
+	switch name = strings.ToLower(name); name {
+	case "", refundScenarioApprove:
+	case refundScenarioFail:
+		step.Status, step.Code = string(RefundFailed), decline.RefundDeclined
+	case refundScenarioDelay:
+		step.After = scenario.Duration(time.Duration(s.config.DelayScenarioMs) * time.Millisecond)
+	default:
+		return scenario.Step{}, fmt.Errorf("%w: unknown refund scenario %s=%q", ErrInvalidTag, key, name)
+	}
+
+	if key, v, ok := refundOption(ctx, intent, refundDelayMetadata, tagRefundDelayMs); ok {
+		ms, err := strconv.Atoi(v)
+		if err != nil || ms < 0 {
+			return scenario.Step{}, fmt.Errorf("%w: %s=%q", ErrInvalidTag, key, v)
+		}
+		step.After = scenario.Duration(time.Duration(ms) * time.Millisecond)
+	}
+	return step, nil
+}
+
+// refundOption은 환불 요청 metadata 값을, 없으면 intent tag 값을 key 이름과 함께 돌려준다.
+func refundOption(ctx context.Context, intent *PaymentIntent, metadataKey, tag string) (key, value string, ok bool) {
+	if md, found := metadata.FromIncomingContext(ctx); found {
+		if values := md.Get(metadataKey); len(values) > 0 {
+			return metadataKey, values[0], true
+		}
+	}
+	value, ok = intent.Tags[tag]
+	return tag, value, ok
+}
+
+// UpdateRefundStatus는 webhook.Dispatcher가 환불 결과를 반영할 때 사용한다.
+// 성공한 환불 누적액이 결제 금액에 도달하면 결제는 REFUNDED로 전이한다.
+func (s *PaymentService) UpdateRefundStatus(paymentID, refundID, status, code string) (webhook.RefundOutcome, error) {
+	to := RefundStatus(status)
+	if to != RefundSucceeded && to != RefundFailed {
+		return webhook.RefundOutcome{}, fmt.Errorf("unknown refund status: %s", status)
+	}
+
+	intent, err := s.mutate(context.Background(), paymentID, func(intent *PaymentIntent) error {
+		refund, ok := intent.refund(refundID)
+		if !ok {
+			return fmt.Errorf("%w: %s", ErrRefundNotFound, refundID)
+		}
+		if refund.Status != RefundPending {
+			return fmt.Errorf("refund %s already %s", refundID, refund.Status)
+		}
+
+		now := time.Now()
+		refund.Status = to
+		refund.UpdatedAt = now
+		if to == RefundFailed {
+			refund.FailureCode = code
+		}
+		intent.UpdatedAt = now
+
+		if to == RefundSucceeded && intent.refundedAmount() >= intent.capturedAmount() {
+			return s.stateMachine.Apply(intent, paymentv1.PaymentStatus_PAYMENT_STATUS_REFUNDED, "fully refunded")
+		}
+		return nil
+	})
+	if err != nil {
+		return webhook.RefundOutcome{}, err
+	}
+
+	s.logger.Info("Refund settled",
+		zap.String("payment_id", paymentID),
+		zap.String("refund_id", refundID),
+		zap.String("status", status),
+		zap.String("payment_status", statusName(intent.Status)))
+
+	return webhook.RefundOutcome{
+		Succeeded:      to == RefundSucceeded,
+		PaymentStatus:  statusName(intent.Status),
+		RefundedAmount: intent.refundedAmount(),
+	}, nil
+}
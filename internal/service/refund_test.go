@@ -0,0 +1,381 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"google.golang.org/grpc/metadata"
+
+	commonv1 "github.com/traffic-tacos/proto-contracts/gen/go/common/v1"
+	paymentv1 "github.com/traffic-tacos/proto-contracts/gen/go/payment/v1"
+	"github.com/traffic-tacos/payment-sim-api/internal/decline"
+	"github.com/traffic-tacos/payment-sim-api/internal/scenario"
+	"github.com/traffic-tacos/payment-sim-api/internal/webhook"
+)
+
+// completedIntent creates an approved intent and runs its steps to COMPLETED.
+func completedIntent(t *testing.T, s *PaymentService, amount *commonv1.Money) string {
+	t.Helper()
+	created, err := s.CreatePaymentIntent(context.Background(), &paymentv1.CreatePaymentIntentRequest{
+		ReservationId: "rsv-refund",
+		UserId:        "user-1",
+		Amount:        amount,
+		Scenario:      paymentv1.PaymentScenario_PAYMENT_SCENARIO_APPROVE,
+	})
+	if err != nil {
+		t.Fatalf("CreatePaymentIntent: %v", err)
+	}
+	for _, status := range []paymentv1.PaymentStatus{paymentv1.PaymentStatus_PAYMENT_STATUS_PROCESSING, completed} {
+		if err := s.UpdatePaymentStatus(created.PaymentIntentId, status.String(), ""); err != nil {
+			t.Fatalf("UpdatePaymentStatus(%s): %v", status, err)
+		}
+	}
+	return created.PaymentIntentId
+}
+
+func refundRequest(paymentID string, amount int64, key string) *paymentv1.CancelPaymentRequest {
+	req := &paymentv1.CancelPaymentRequest{PaymentIntentId: paymentID, IdempotencyKey: key}
+	if amount > 0 {
+		req.RefundAmount = &commonv1.Money{Amount: amount, Currency: "KRW"}
+	}
+	return req
+}
+
+func TestRefundAmounts(t *testing.T) {
+	tests := []struct {
+		name string
+		// refunds are requested in order; 0 means the remaining amount
+		refunds []int64
+		// settle each refund with this status before the next one (empty: leave PENDING)
+		settle     RefundStatus
+		wantErr    error
+		wantAmount []int64
+		want       paymentv1.PaymentStatus
+	}{
+		{
+			name:       "full",
+			refunds:    []int64{0},
+			settle:     RefundSucceeded,
+			wantAmount: []int64{10000},
+			want:       paymentv1.PaymentStatus_PAYMENT_STATUS_REFUNDED,
+		},
+		{
+			name:       "partial",
+			refunds:    []int64{3000},
+			settle:     RefundSucceeded,
+			wantAmount: []int64{3000},
+			want:       completed,
+		},
+		{
+			name:       "multiple partial up to captured",
+			refunds:    []int64{3000, 4000, 0},
+			settle:     RefundSucceeded,
+			wantAmount: []int64{3000, 4000, 3000},
+			want:       paymentv1.PaymentStatus_PAYMENT_STATUS_REFUNDED,
+		},
+		{
+			name:       "pending refunds count against the remaining amount",
+			refunds:    []int64{6000, 5000},
+			wantAmount: []int64{6000},
+			wantErr:    ErrRefundAmount,
+			want:       completed,
+		},
+		{
+			name:    "over refund",
+			refunds: []int64{10001},
+			wantErr: ErrRefundAmount,
+			want:    completed,
+		},
+		{
+			name:       "nothing left while a full refund is pending",
+			refunds:    []int64{0, 0},
+			wantErr:    ErrRefundAmount,
+			wantAmount: []int64{10000},
+			want:       completed,
+		},
+		{
+			name:       "failed refund frees its amount",
+			refunds:    []int64{0, 0},
+			settle:     RefundFailed,
+			wantAmount: []int64{10000, 10000},
+			want:       completed,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			s := newTestService(t, testConfig(t), &fakeSender{})
+			id := completedIntent(t, s, &commonv1.Money{Amount: 10000, Currency: "KRW"})
+
+			var (
+				amounts []int64
+				err     error
+			)
+			for _, amount := range tt.refunds {
+				var response *paymentv1.CancelPaymentResponse
+				response, err = s.CancelPayment(ctx, refundRequest(id, amount, ""))
+				if err != nil {
+					break
+				}
+				amounts = append(amounts, response.RefundedAmount.GetAmount())
+				if tt.settle != "" {
+					if _, err := s.UpdateRefundStatus(id, response.RefundId, string(tt.settle), ""); err != nil {
+						t.Fatalf("UpdateRefundStatus: %v", err)
+					}
+				}
+			}
+
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("CancelPayment err = %v, want %v", err, tt.wantErr)
+			}
+			if len(amounts) != len(tt.wantAmount) {
+				t.Fatalf("refunded amounts = %v, want %v", amounts, tt.wantAmount)
+			}
+			for i := range amounts {
+				if amounts[i] != tt.wantAmount[i] {
+					t.Errorf("refunded amounts = %v, want %v", amounts, tt.wantAmount)
+					break
+				}
+			}
+
+			intent, err := s.store.Get(ctx, id)
+			if err != nil {
+				t.Fatalf("Get: %v", err)
+			}
+			if intent.Status != tt.want {
+				t.Errorf("status = %s, want %s", statusName(intent.Status), statusName(tt.want))
+			}
+		})
+	}
+}
+
+func TestRefundAmountRejects(t *testing.T) {
+	tests := []struct {
+		name     string
+		currency string
+		refund   *commonv1.Money
+	}{
+		{
+			name:     "currency mismatch",
+			currency: "KRW",
+			refund:   &commonv1.Money{Amount: 1000, Currency: "USD"},
+		},
+		{
+			name:     "not a positive amount",
+			currency: "KRW",
+			refund:   &commonv1.Money{Amount: -1, Currency: "KRW"},
+		},
+		{
+			name:     "not a whole currency unit",
+			currency: "HUF",
+			refund:   &commonv1.Money{Amount: 150, Currency: "HUF"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			s := newTestService(t, testConfig(t), &fakeSender{})
+			id := completedIntent(t, s, &commonv1.Money{Amount: 10000, Currency: tt.currency})
+
+			_, err := s.CancelPayment(ctx, &paymentv1.CancelPaymentRequest{PaymentIntentId: id, RefundAmount: tt.refund})
+			if !errors.Is(err, ErrRefundAmount) {
+				t.Fatalf("CancelPayment err = %v, want %v", err, ErrRefundAmount)
+			}
+			if c := Classify(err); c.Kind != KindInvalidArgument {
+				t.Errorf("kind = %v, want KindInvalidArgument", c.Kind)
+			}
+
+			intent, err := s.store.Get(ctx, id)
+			if err != nil {
+				t.Fatalf("Get: %v", err)
+			}
+			if len(intent.Refunds) != 0 {
+				t.Errorf("refunds = %+v, want none", intent.Refunds)
+			}
+		})
+	}
+}
+
+// refundRejectingSender accepts payment jobs but fails to queue refunds.
+type refundRejectingSender struct {
+	*fakeSender
+}
+
+func (f refundRejectingSender) SchedulePayment(job webhook.Job) error {
+	if job.RefundID != "" {
+		return webhook.ErrQueueFull
+	}
+	return f.fakeSender.SchedulePayment(job)
+}
+
+func TestRefundUnscheduledBecomesRefundable(t *testing.T) {
+	ctx := context.Background()
+	s := newTestService(t, testConfig(t), refundRejectingSender{&fakeSender{}})
+	id := completedIntent(t, s, &commonv1.Money{Amount: 10000, Currency: "KRW"})
+
+	_, err := s.CancelPayment(ctx, refundRequest(id, 0, ""))
+	if !errors.Is(err, webhook.ErrQueueFull) {
+		t.Fatalf("CancelPayment err = %v, want %v", err, webhook.ErrQueueFull)
+	}
+
+	intent, err := s.store.Get(ctx, id)
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	if len(intent.Refunds) != 1 || intent.Refunds[0].Status != RefundFailed || intent.Refunds[0].FailureCode != decline.RefundScheduleFailed {
+		t.Fatalf("refunds = %+v, want one FAILED refund with %s", intent.Refunds, decline.RefundScheduleFailed)
+	}
+	if got := intent.refundableAmount(); got != 10000 {
+		t.Errorf("refundable amount = %d, want 10000", got)
+	}
+	if intent.Status != completed {
+		t.Errorf("status = %s, want %s", statusName(intent.Status), statusName(completed))
+	}
+}
+
+func TestRefundIdempotencyKeyAfterRefunded(t *testing.T) {
+	ctx := context.Background()
+	sender := &fakeSender{}
+	s := newTestService(t, testConfig(t), sender)
+	id := completedIntent(t, s, &commonv1.Money{Amount: 10000, Currency: "KRW"})
+
+	first, err := s.CancelPayment(ctx, refundRequest(id, 0, "refund-key"))
+	if err != nil {
+		t.Fatalf("CancelPayment: %v", err)
+	}
+	outcome, err := s.UpdateRefundStatus(id, first.RefundId, string(RefundSucceeded), "")
+	if err != nil {
+		t.Fatalf("UpdateRefundStatus: %v", err)
+	}
+	if outcome.PaymentStatus != paymentv1.PaymentStatus_PAYMENT_STATUS_REFUNDED.String() || outcome.RefundedAmount != 10000 {
+		t.Fatalf("outcome = %+v, want REFUNDED with 10000 refunded", outcome)
+	}
+	jobs := len(sender.jobsFor(id))
+
+	replay, err := s.CancelPayment(ctx, refundRequest(id, 0, "refund-key"))
+	if err != nil {
+		t.Fatalf("replayed CancelPayment: %v", err)
+	}
+	if replay.RefundId != first.RefundId || replay.RefundedAmount.GetAmount() != 10000 {
+		t.Errorf("replay = %s/%d, want %s/10000", replay.RefundId, replay.RefundedAmount.GetAmount(), first.RefundId)
+	}
+	if replay.Status != paymentv1.PaymentStatus_PAYMENT_STATUS_REFUNDED {
+		t.Errorf("replay status = %s, want REFUNDED", replay.Status)
+	}
+	if got := len(sender.jobsFor(id)); got != jobs {
+		t.Errorf("jobs after replay = %d, want %d", got, jobs)
+	}
+
+	// 새 키로는 더 환불할 수 없다
+	if _, err := s.CancelPayment(ctx, refundRequest(id, 0, "other-key")); !errors.Is(err, ErrRefundNotAllowed) {
+		t.Errorf("CancelPayment with a new key err = %v, want %v", err, ErrRefundNotAllowed)
+	}
+}
+
+// 환불 시나리오는 환불 요청마다 metadata로 지정할 수 있고, 없으면 intent tag를 따른다.
+func TestRefundScenarioPerRequest(t *testing.T) {
+	type request struct {
+		metadata []string // x-refund-* metadata pairs
+		want     RefundStatus
+		wantCode string
+	}
+	tests := []struct {
+		name     string
+		tags     map[string]string
+		requests []request
+		wantErr  bool
+	}{
+		{
+			name: "failed partial refund then a successful one",
+			requests: []request{
+				{metadata: []string{refundScenarioMetadata, refundScenarioFail}, want: RefundFailed, wantCode: decline.RefundDeclined},
+				{want: RefundSucceeded},
+			},
+		},
+		{
+			name: "metadata overrides the intent tag",
+			tags: map[string]string{tagRefundScenario: refundScenarioFail},
+			requests: []request{
+				{want: RefundFailed, wantCode: decline.RefundDeclined},
+				{metadata: []string{refundScenarioMetadata, refundScenarioApprove}, want: RefundSucceeded},
+			},
+		},
+		{
+			name:     "unknown scenario",
+			requests: []request{{metadata: []string{refundScenarioMetadata, "maybe"}}},
+			wantErr:  true,
+		},
+		{
+			name:     "negative delay",
+			requests: []request{{metadata: []string{refundDelayMetadata, "-1"}}},
+			wantErr:  true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			sender := &fakeSender{}
+			s := newTestService(t, testConfig(t), sender)
+			created, err := s.CreatePaymentIntent(ctx, &paymentv1.CreatePaymentIntentRequest{
+				ReservationId: "rsv-refund",
+				UserId:        "user-1",
+				Amount:        &commonv1.Money{Amount: 10000, Currency: "KRW"},
+				Scenario:      paymentv1.PaymentScenario_PAYMENT_SCENARIO_APPROVE,
+				Metadata:      &paymentv1.PaymentMetadata{Tags: tt.tags},
+			})
+			if err != nil {
+				t.Fatalf("CreatePaymentIntent: %v", err)
+			}
+			id := created.PaymentIntentId
+			for _, status := range []paymentv1.PaymentStatus{processing, completed} {
+				if err := s.UpdatePaymentStatus(id, status.String(), ""); err != nil {
+					t.Fatalf("UpdatePaymentStatus(%s): %v", status, err)
+				}
+			}
+
+			for i, r := range tt.requests {
+				refundCtx := ctx
+				if len(r.metadata) > 0 {
+					refundCtx = metadata.NewIncomingContext(ctx, metadata.Pairs(r.metadata...))
+				}
+				resp, err := s.CancelPayment(refundCtx, refundRequest(id, 3000, ""))
+				if tt.wantErr {
+					if !errors.Is(err, ErrInvalidTag) {
+						t.Fatalf("CancelPayment err = %v, want %v", err, ErrInvalidTag)
+					}
+					return
+				}
+				if err != nil {
+					t.Fatalf("CancelPayment #%d: %v", i, err)
+				}
+
+				// dispatcher 대신 예약된 환불 step을 실행한다
+				var step *scenario.Step
+				for _, job := range sender.jobsFor(id) {
+					if job.RefundID == resp.RefundId {
+						step = &job.Steps[0]
+					}
+				}
+				if step == nil {
+					t.Fatalf("refund #%d was not scheduled", i)
+				}
+				if _, err := s.UpdateRefundStatus(id, resp.RefundId, step.Status, step.Code); err != nil {
+					t.Fatalf("UpdateRefundStatus #%d: %v", i, err)
+				}
+
+				intent, err := s.store.Get(ctx, id)
+				if err != nil {
+					t.Fatalf("Get: %v", err)
+				}
+				refund, _ := intent.refund(resp.RefundId)
+				if refund.Status != r.want || refund.FailureCode != r.wantCode {
+					t.Errorf("refund #%d = %s %q, want %s %q", i, refund.Status, refund.FailureCode, r.want, r.wantCode)
+				}
+			}
+		})
+	}
+}
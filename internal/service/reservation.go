@@ -0,0 +1,104 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"sync"
+
+	"go.uber.org/zap"
+
+	paymentv1 "github.com/traffic-tacos/proto-contracts/gen/go/payment/v1"
+)
+
+// 같은 예약에 활성 intent가 이미 있을 때의 처리 정책 (RESERVATION_INTENT_POLICY)
+const (
+	ReservationPolicyReject         = "reject"
+	ReservationPolicyReturnExisting = "return_existing"
+	ReservationPolicyAllowAndFlag   = "allow_and_flag"
+
+	// tagDuplicateOf는 allow_and_flag로 생성된 중복 intent의 GetPaymentStatus metadata에 노출된다.
+	tagDuplicateOf = "duplicate_of"
+
+	reservationLockStripes = 64
+)
+
+var ErrReservationActiveIntent = newError(KindAlreadyExists, "RESERVATION_HAS_ACTIVE_INTENT", "reservation already has an active payment intent")
+
+// ValidateReservationIntentPolicy checks RESERVATION_INTENT_POLICY at startup
+// so a typo fails fast instead of rejecting every CreatePaymentIntent.
+func ValidateReservationIntentPolicy(policy string) error {
+	switch policy {
+	case "", ReservationPolicyReject, ReservationPolicyReturnExisting, ReservationPolicyAllowAndFlag:
+		return nil
+	}
+	return fmt.Errorf("unknown reservation intent policy %q (want reject, return_existing or allow_and_flag)", policy)
+}
+
+// isActive reports whether an intent still holds or may still take the
+// reservation's money. Failed, cancelled, expired and refunded intents do not.
+func isActive(status paymentv1.PaymentStatus) bool {
+	switch status {
+	case paymentv1.PaymentStatus_PAYMENT_STATUS_PENDING,
+		paymentv1.PaymentStatus_PAYMENT_STATUS_PROCESSING,
+		paymentv1.PaymentStatus_PAYMENT_STATUS_COMPLETED,
+		StatusAuthorized:
+		return true
+	}
+	return false
+}
+
+// reservationLocks serializes intent creation per reservation so two
+// concurrent requests cannot both pass the active intent check.
+type reservationLocks [reservationLockStripes]sync.Mutex
+
+func (l *reservationLocks) lock(reservationID string) func() {
+	h := fnv.New32a()
+	h.Write([]byte(reservationID))
+	mu := &l[h.Sum32()%reservationLockStripes]
+	mu.Lock()
+	return mu.Unlock
+}
+
+// activeIntent returns the oldest active intent of a reservation, or nil.
+func (s *PaymentService) activeIntent(ctx context.Context, reservationID string) (*PaymentIntent, error) {
+	intents, err := s.store.ListByReservation(ctx, reservationID)
+	if err != nil {
+		return nil, err
+	}
+	for _, intent := range intents {
+		if isActive(intent.Status) {
+			return intent, nil
+		}
+	}
+	return nil, nil
+}
+
+// checkReservation applies the reservation policy to a new intent. It returns
+// the existing intent when the policy is return_existing and one is active;
+// with allow_and_flag the new intent is marked as a duplicate instead. The
+// policy is checked at startup by ValidateReservationIntentPolicy.
+func (s *PaymentService) checkReservation(ctx context.Context, intent *PaymentIntent) (*PaymentIntent, error) {
+	active, err := s.activeIntent(ctx, intent.ReservationID)
+	if err != nil || active == nil {
+		return nil, err
+	}
+
+	switch s.config.ReservationIntentPolicy {
+	case ReservationPolicyReturnExisting:
+		s.logger.Info("Returning existing payment intent for reservation",
+			zap.String("reservation_id", intent.ReservationID),
+			zap.String("payment_id", active.ID))
+		return active, nil
+	case ReservationPolicyAllowAndFlag:
+		s.logger.Warn("Duplicate payment intent for reservation",
+			zap.String("reservation_id", intent.ReservationID),
+			zap.String("payment_id", intent.ID),
+			zap.String("duplicate_of", active.ID))
+		intent.DuplicateOf = active.ID
+		return nil, nil
+	default:
+		return nil, fmt.Errorf("%w: %s (%s is %s)", ErrReservationActiveIntent,
+			intent.ReservationID, active.ID, statusName(active.Status))
+	}
+}
@@ -0,0 +1,164 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+
+	commonv1 "github.com/traffic-tacos/proto-contracts/gen/go/common/v1"
+	paymentv1 "github.com/traffic-tacos/proto-contracts/gen/go/payment/v1"
+)
+
+func reservationRequest(reservationID string) *paymentv1.CreatePaymentIntentRequest {
+	return &paymentv1.CreatePaymentIntentRequest{
+		ReservationId: reservationID,
+		UserId:        "user-1",
+		Amount:        &commonv1.Money{Amount: 10000, Currency: "KRW"},
+		Scenario:      paymentv1.PaymentScenario_PAYMENT_SCENARIO_APPROVE,
+	}
+}
+
+func TestReservationIntentPolicy(t *testing.T) {
+	tests := []struct {
+		policy string
+		// wantErr: the second create is rejected
+		wantErr bool
+		// wantExisting: the second create returns the first intent
+		wantExisting bool
+		// wantFlagged: the second create is a new intent marked duplicate_of the first
+		wantFlagged bool
+	}{
+		{policy: "", wantErr: true},
+		{policy: ReservationPolicyReject, wantErr: true},
+		{policy: ReservationPolicyReturnExisting, wantExisting: true},
+		{policy: ReservationPolicyAllowAndFlag, wantFlagged: true},
+	}
+
+	for _, tt := range tests {
+		t.Run("policy="+tt.policy, func(t *testing.T) {
+			ctx := context.Background()
+			cfg := testConfig(t)
+			cfg.ReservationIntentPolicy = tt.policy
+			s := newTestService(t, cfg, &fakeSender{})
+
+			first, err := s.CreatePaymentIntent(ctx, reservationRequest("rsv-1"))
+			if err != nil {
+				t.Fatalf("first CreatePaymentIntent: %v", err)
+			}
+			second, err := s.CreatePaymentIntent(ctx, reservationRequest("rsv-1"))
+
+			if tt.wantErr {
+				if !errors.Is(err, ErrReservationActiveIntent) {
+					t.Fatalf("second CreatePaymentIntent err = %v, want %v", err, ErrReservationActiveIntent)
+				}
+				if c := Classify(err); c.Kind != KindAlreadyExists {
+					t.Errorf("kind = %v, want KindAlreadyExists", c.Kind)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("second CreatePaymentIntent: %v", err)
+			}
+
+			if got := second.PaymentIntentId == first.PaymentIntentId; got != tt.wantExisting {
+				t.Errorf("second returned first intent = %v, want %v", got, tt.wantExisting)
+			}
+
+			status, err := s.GetPaymentStatus(ctx, &paymentv1.GetPaymentStatusRequest{PaymentIntentId: second.PaymentIntentId})
+			if err != nil {
+				t.Fatalf("GetPaymentStatus: %v", err)
+			}
+			dup := status.Payment.GetMetadata().GetTags()[tagDuplicateOf]
+			want := ""
+			if tt.wantFlagged {
+				want = first.PaymentIntentId
+			}
+			if dup != want {
+				t.Errorf("duplicate_of = %q, want %q", dup, want)
+			}
+		})
+	}
+}
+
+func TestReservationPolicyIgnoresInactiveIntents(t *testing.T) {
+	ctx := context.Background()
+	s := newTestService(t, testConfig(t), &fakeSender{})
+
+	first, err := s.CreatePaymentIntent(ctx, reservationRequest("rsv-1"))
+	if err != nil {
+		t.Fatalf("first CreatePaymentIntent: %v", err)
+	}
+	if _, err := s.CancelPayment(ctx, &paymentv1.CancelPaymentRequest{PaymentIntentId: first.PaymentIntentId}); err != nil {
+		t.Fatalf("CancelPayment: %v", err)
+	}
+
+	if _, err := s.CreatePaymentIntent(ctx, reservationRequest("rsv-1")); err != nil {
+		t.Errorf("CreatePaymentIntent after cancel: %v", err)
+	}
+}
+
+func TestReservationConcurrentCreate(t *testing.T) {
+	for _, policy := range []string{ReservationPolicyReject, ReservationPolicyReturnExisting} {
+		t.Run("policy="+policy, func(t *testing.T) {
+			ctx := context.Background()
+			cfg := testConfig(t)
+			cfg.ReservationIntentPolicy = policy
+			s := newTestService(t, cfg, &fakeSender{})
+
+			const callers = 32
+			var wg sync.WaitGroup
+			results := make(chan createResult, callers)
+			for range callers {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					response, err := s.CreatePaymentIntent(ctx, reservationRequest("rsv-1"))
+					results <- createResult{response: response, err: err}
+				}()
+			}
+			wg.Wait()
+			close(results)
+
+			ids := make(map[string]bool)
+			for r := range results {
+				switch {
+				case r.err == nil:
+					ids[r.response.PaymentIntentId] = true
+				case !errors.Is(r.err, ErrReservationActiveIntent):
+					t.Errorf("CreatePaymentIntent: %v", r.err)
+				}
+			}
+			if len(ids) != 1 {
+				t.Errorf("distinct intents returned = %d, want 1", len(ids))
+			}
+
+			intents, err := s.store.ListByReservation(ctx, "rsv-1")
+			if err != nil {
+				t.Fatalf("ListByReservation: %v", err)
+			}
+			active := 0
+			for _, intent := range intents {
+				if isActive(intent.Status) {
+					active++
+				}
+			}
+			if active != 1 {
+				t.Errorf("active intents = %d (of %d stored), want 1", active, len(intents))
+			}
+		})
+	}
+}
+
+func TestValidateReservationIntentPolicy(t *testing.T) {
+	for _, policy := range []string{"", ReservationPolicyReject, ReservationPolicyReturnExisting, ReservationPolicyAllowAndFlag} {
+		if err := ValidateReservationIntentPolicy(policy); err != nil {
+			t.Errorf("ValidateReservationIntentPolicy(%q) = %v", policy, err)
+		}
+	}
+	for _, policy := range []string{"rejct", "REJECT", "1"} {
+		if err := ValidateReservationIntentPolicy(policy); err == nil {
+			t.Errorf("ValidateReservationIntentPolicy(%q) = nil, want error", policy)
+		}
+	}
+}
@@ -2,159 +2,661 @@ package service
 
 import (
 	"context"
+	"errors"
 	"fmt"
-	"sync"
+	"maps"
+	"math/rand/v2"
 	"time"
 
 	"github.com/google/uuid"
 	"go.uber.org/zap"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/timestamppb"
 
 	commonv1 "github.com/traffic-tacos/proto-contracts/gen/go/common/v1"
 	paymentv1 "github.com/traffic-tacos/proto-contracts/gen/go/payment/v1"
 	"github.com/traffic-tacos/payment-sim-api/internal/config"
+	"github.com/traffic-tacos/payment-sim-api/internal/decline"
 	"github.com/traffic-tacos/payment-sim-api/internal/events"
+	"github.com/traffic-tacos/payment-sim-api/internal/scenario"
+	"github.com/traffic-tacos/payment-sim-api/internal/testvalues"
+	"github.com/traffic-tacos/payment-sim-api/internal/webhook"
 )
 
 type PaymentIntent struct {
 	ID            string
+	Version       int64
 	ReservationID string
 	UserID        string
 	Amount        *commonv1.Money
 	Status        paymentv1.PaymentStatus
 	Scenario      paymentv1.PaymentScenario
 	WebhookURL    string
+	Tags          map[string]string
+	RandomSeed    uint64
 	CreatedAt     time.Time
+	UpdatedAt     time.Time
 	ProcessedAt   *time.Time
+	History       []StatusTransition
+	Refunds       []Refund
+
+	// 2단계 결제: manual이면 AUTHORIZED에서 capture/void를 기다린다
+	CaptureMethod  string
+	AuthorizedAt   *time.Time
+	CapturedAmount int64 // 매입 금액 (부분 매입 시 Amount보다 작음, 0이면 Amount 전체)
+
+	// CreatePaymentIntent 멱등성: 재시작 후에도 키를 복원할 수 있도록 intent에 함께 저장
+	IdempotencyKey string
+	RequestHash    string
+	// ResponseStatus는 최초 응답의 상태다. 재시작 후 재시도에도 같은 응답을 돌려준다.
+	ResponseStatus paymentv1.PaymentStatus
+
+	// allow_and_flag 정책에서 같은 예약의 활성 intent가 이미 있을 때 그 intent ID
+	DuplicateOf string
+
+	// FAILED/EXPIRED 결제의 PG 코드 (decline code, AUTHORIZATION_EXPIRED 등)
+	FailureCode string
+}
+
+func (i *PaymentIntent) clone() *PaymentIntent {
+	c := *i
+	if i.Amount != nil {
+		c.Amount = proto.Clone(i.Amount).(*commonv1.Money)
+	}
+	c.Tags = maps.Clone(i.Tags)
+	c.History = append([]StatusTransition(nil), i.History...)
+	c.Refunds = append([]Refund(nil), i.Refunds...)
+	return &c
 }
 
 type PaymentService struct {
-	logger    *zap.Logger
-	config    *config.Config
-	intents   map[string]*PaymentIntent
-	intentsMu sync.RWMutex
-	webhook   WebhookSender
-	publisher *events.Publisher
+	logger       *zap.Logger
+	config       *config.Config
+	store        Store
+	stateMachine *StateMachine
+	webhook      WebhookSender
+	publisher    *events.Publisher
+	scenarios    *scenario.Registry
+	testValues   *testvalues.Engine
+	random       *randomSource
+
+	// PAYMENT_SCENARIO_UNSPECIFIED 요청에 적용할 시나리오 (DEFAULT_SCENARIO)
+	defaultScenario scenario.Mix
+	idempotency     *IdempotencyStore
+
+	reservationLocks reservationLocks
 }
 
 type WebhookSender interface {
-	SendPaymentWebhookAsync(paymentID, reservationID, finalStatus, webhookURL string, amount int64, currency string, delaySeconds int)
+	SchedulePayment(job webhook.Job) error
+	// CancelScheduled는 결제의 아직 실행되지 않은 시나리오 step을 제거한다.
+	// kinds가 있으면 그 종류의 job만 제거한다.
+	CancelScheduled(paymentID string, kinds ...webhook.JobKind) int
 }
 
-func NewPaymentService(logger *zap.Logger, config *config.Config, webhook WebhookSender, publisher *events.Publisher) *PaymentService {
-	return &PaymentService{
-		logger:    logger,
-		config:    config,
-		intents:   make(map[string]*PaymentIntent),
-		webhook:   webhook,
-		publisher: publisher,
+// metadata.tags["scenario"]로 등록된 스크립트 시나리오를 이름으로 선택한다.
+const tagScenarioName = "scenario"
+
+// metadata.tags["merchant_id"]로 webhook 서명 secret을 merchant별로 선택한다.
+const tagMerchantID = "merchant_id"
+
+func NewPaymentService(logger *zap.Logger, config *config.Config, store Store, webhook WebhookSender, publisher *events.Publisher, scenarios *scenario.Registry, testValues *testvalues.Engine, defaultScenario scenario.Mix) *PaymentService {
+	random := newRandomSource(config)
+	logger.Info("Payment simulation random source initialized",
+		zap.Uint64("seed", random.seed),
+		zap.Bool("seed_from_reservation", random.perReservation),
+		zap.String("default_scenario", defaultScenario.String()))
+
+	// 규칙 파일이 없어도 decline 테스트 카드는 동작해야 한다
+	if testValues == nil {
+		testValues = testvalues.NewEngine()
+	}
+
+	s := &PaymentService{
+		logger:       logger,
+		config:       config,
+		store:        store,
+		stateMachine: NewStateMachine(),
+		webhook:      webhook,
+		publisher:    publisher,
+		scenarios:    scenarios,
+		testValues:   testValues,
+		random:       random,
+		idempotency:  NewIdempotencyStore(time.Duration(config.IdempotencyTTLMs) * time.Millisecond),
+
+		defaultScenario: defaultScenario,
 	}
+	s.restoreIdempotencyKeys()
+	return s
 }
 
+// CreatePaymentIntent는 Idempotency-Key(request 필드 또는 idempotency-key metadata)가 있으면
+// 같은 요청의 재시도에 최초 응답을 그대로 돌려주고, 같은 키로 다른 요청을 보내면 거절한다.
 func (s *PaymentService) CreatePaymentIntent(ctx context.Context, req *paymentv1.CreatePaymentIntentRequest) (*paymentv1.CreatePaymentIntentResponse, error) {
+	req = normalizeCreatePaymentIntent(req)
+	if err := validateCreatePaymentIntent(req); err != nil {
+		return nil, err
+	}
+
+	key, err := idempotencyKey(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	if key == "" {
+		return s.createPaymentIntent(ctx, req, "", "")
+	}
+
+	hash, err := requestHash(req)
+	if err != nil {
+		return nil, err
+	}
+	for {
+		entry, owner, err := s.idempotency.begin(key, hash)
+		if err != nil {
+			return nil, err
+		}
+		if owner {
+			response, err := s.createPaymentIntent(ctx, req, key, hash)
+			if err != nil {
+				s.idempotency.abort(key, entry)
+				return nil, err
+			}
+			s.idempotency.complete(entry, response)
+			return response, nil
+		}
+
+		response, err := entry.wait(ctx)
+		if err != nil {
+			return nil, err
+		}
+		if response != nil {
+			s.logger.Info("Replaying payment intent for idempotency key",
+				zap.String("idempotency_key", key),
+				zap.String("payment_id", response.PaymentIntentId))
+			return response, nil
+		}
+		// 먼저 온 요청이 실패해 키가 해제되었으므로 이 요청이 다시 시도한다
+	}
+}
+
+func (s *PaymentService) createPaymentIntent(ctx context.Context, req *paymentv1.CreatePaymentIntentRequest, idempotencyKey, requestHash string) (*paymentv1.CreatePaymentIntentResponse, error) {
 	s.logger.Info("Creating payment intent",
 		zap.String("reservation_id", req.ReservationId),
 		zap.String("user_id", req.UserId),
 		zap.String("scenario", req.Scenario.String()))
 
+	tags := req.GetMetadata().GetTags()
+	seed, err := s.random.intentSeed(req.ReservationId, tags)
+	if err != nil {
+		return nil, err
+	}
+	capture, err := captureMethod(tags)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
 	intent := &PaymentIntent{
 		ID:            uuid.New().String(),
 		ReservationID: req.ReservationId,
 		UserID:        req.UserId,
 		Amount:        req.Amount,
 		Status:        paymentv1.PaymentStatus_PAYMENT_STATUS_PENDING, // 실제 PG사처럼 PENDING
-		Scenario:      req.Scenario,
+		Scenario:      s.resolveScenario(req.Scenario, seed),
 		WebhookURL:    req.WebhookUrl,
-		CreatedAt:     time.Now(),
+		Tags:          tags,
+		RandomSeed:    seed,
+		CaptureMethod: capture,
+		CreatedAt:     now,
+		UpdatedAt:     now,
+
+		IdempotencyKey: idempotencyKey,
+		RequestHash:    requestHash,
+		ResponseStatus: paymentv1.PaymentStatus_PAYMENT_STATUS_PENDING, // PENDING 상태로 즉시 응답
+	}
+
+	steps, expireAfter, err := s.buildSteps(intent)
+	if err != nil {
+		return nil, err
 	}
 
-	s.intentsMu.Lock()
-	s.intents[intent.ID] = intent
-	s.intentsMu.Unlock()
+	// 예약당 활성 intent 하나 (RESERVATION_INTENT_POLICY)
+	if intent.ReservationID != "" {
+		unlock := s.reservationLocks.lock(intent.ReservationID)
+		defer unlock()
 
-	// 실제 PG사처럼 비동기 webhook 발송 시작
-	if intent.WebhookURL != "" && s.webhook != nil {
-		finalStatus := s.determineFinalStatus(intent.Scenario)
-		delaySeconds := s.config.DefaultDelayMs / 1000 // ms를 초로 변환
-		if delaySeconds == 0 {
-			delaySeconds = 2 // 기본 2초
+		existing, err := s.checkReservation(ctx, intent)
+		if err != nil {
+			return nil, err
+		}
+		if existing != nil {
+			return &paymentv1.CreatePaymentIntentResponse{
+				PaymentIntentId: existing.ID,
+				Status:          wireStatus(existing.Status),
+			}, nil
 		}
+	}
+
+	if err := s.store.Put(ctx, intent); err != nil {
+		return nil, fmt.Errorf("failed to store payment intent: %w", err)
+	}
+	s.logger.Info("Payment intent created",
+		zap.String("payment_id", intent.ID),
+		zap.Uint64("random_seed", intent.RandomSeed),
+		zap.String("scenario", intent.Scenario.String()))
 
-		s.webhook.SendPaymentWebhookAsync(
-			intent.ID,
-			intent.ReservationID,
-			finalStatus,
-			intent.WebhookURL,
-			intent.Amount.Amount,
-			intent.Amount.Currency,
-			delaySeconds,
-		)
+	// 실제 PG사처럼 비동기 처리 + webhook 발송 시작
+	if s.webhook != nil {
+		err := s.webhook.SchedulePayment(webhook.Job{
+			PaymentID:     intent.ID,
+			ReservationID: intent.ReservationID,
+			MerchantID:    intent.Tags[tagMerchantID],
+			WebhookURL:    intent.WebhookURL,
+			Amount:        intent.Amount.Amount,
+			Currency:      intent.Amount.Currency,
+			Steps:         steps,
+		})
+		if err != nil {
+			// 큐가 가득 차면 intent를 FAILED로 확정하고 호출자에게 backpressure 전달
+			s.logger.Warn("Failed to schedule payment intent",
+				zap.String("payment_id", intent.ID),
+				zap.Error(err))
+			// 키도 해제해 재시작 후 복원되지 않게 한다 (호출자는 같은 키로 재시도할 수 있음)
+			_, terr := s.mutate(ctx, intent.ID, func(intent *PaymentIntent) error {
+				intent.IdempotencyKey, intent.RequestHash = "", ""
+				return s.stateMachine.Apply(intent, paymentv1.PaymentStatus_PAYMENT_STATUS_FAILED, err.Error())
+			})
+			if terr != nil {
+				s.logger.Error("Failed to mark unscheduled payment intent as failed",
+					zap.String("payment_id", intent.ID),
+					zap.Error(terr))
+			}
+			return nil, fmt.Errorf("failed to schedule payment intent: %w", err)
+		}
+		// 승인 만료는 별도 job으로 예약해 capture/void가 앞선 step과 상관없이 그것만 취소한다
+		if expireAfter > 0 {
+			s.scheduleExpiry(intent, expireAfter)
+		}
 	}
 
+	return creationResponse(intent), nil
+}
+
+// creationResponse는 intent를 만든 CreatePaymentIntent의 응답이다.
+func creationResponse(intent *PaymentIntent) *paymentv1.CreatePaymentIntentResponse {
 	return &paymentv1.CreatePaymentIntentResponse{
 		PaymentIntentId: intent.ID,
-		Status:          intent.Status, // PENDING 상태로 즉시 응답
-	}, nil
+		Status:          intent.ResponseStatus,
+	}
 }
 
 func (s *PaymentService) GetPaymentStatus(ctx context.Context, req *paymentv1.GetPaymentStatusRequest) (*paymentv1.GetPaymentStatusResponse, error) {
-	s.intentsMu.RLock()
-	intent, exists := s.intents[req.PaymentIntentId]
-	s.intentsMu.RUnlock()
+	intent, err := s.store.Get(ctx, req.PaymentIntentId)
+	if err != nil {
+		return nil, err
+	}
 
-	if !exists {
-		return nil, fmt.Errorf("payment intent not found: %s", req.PaymentIntentId)
+	response := &paymentv1.GetPaymentStatusResponse{
+		Payment: toPayment(intent),
 	}
 
+	return response, nil
+}
+
+// ListPayments는 reservation_id / user_id / status로 결제를 조회한다. reservation_id가 있으면
+// 예약 인덱스를 사용하며, 결과는 생성 순이고 pagination.page(1부터)/page_size로 나눈다.
+func (s *PaymentService) ListPayments(ctx context.Context, req *paymentv1.ListPaymentsRequest) (*paymentv1.ListPaymentsResponse, error) {
+	var (
+		intents []*PaymentIntent
+		err     error
+	)
+	if req.ReservationId != "" {
+		intents, err = s.store.ListByReservation(ctx, req.ReservationId)
+	} else {
+		intents, err = s.store.List(ctx)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	matched := intents[:0]
+	for _, intent := range intents {
+		if req.UserId != "" && intent.UserID != req.UserId {
+			continue
+		}
+		if req.Status != paymentv1.PaymentStatus_PAYMENT_STATUS_UNSPECIFIED && wireStatus(intent.Status) != req.Status {
+			continue
+		}
+		matched = append(matched, intent)
+	}
+
+	page, pageSize := int(req.GetPagination().GetPage()), int(req.GetPagination().GetPageSize())
+	if page <= 0 {
+		page = 1
+	}
+	if pageSize <= 0 || pageSize > maxPageSize {
+		pageSize = defaultPageSize
+	}
+	start := min((page-1)*pageSize, len(matched))
+	end := min(start+pageSize, len(matched))
+
+	payments := make([]*paymentv1.Payment, 0, end-start)
+	for _, intent := range matched[start:end] {
+		payments = append(payments, toPayment(intent))
+	}
+
+	return &paymentv1.ListPaymentsResponse{
+		Payments: payments,
+		PageInfo: &commonv1.PageInfo{
+			TotalCount:      int32(len(matched)),
+			HasNextPage:     end < len(matched),
+			HasPreviousPage: start > 0,
+		},
+	}, nil
+}
+
+const (
+	defaultPageSize = 20
+	maxPageSize     = 100
+)
+
+func toPayment(intent *PaymentIntent) *paymentv1.Payment {
 	payment := &paymentv1.Payment{
 		PaymentIntentId: intent.ID,
 		ReservationId:   intent.ReservationID,
 		UserId:          intent.UserID,
 		Amount:          intent.Amount,
-		Status:          intent.Status,
+		Status:          wireStatus(intent.Status),
+		Result:          paymentResult(intent),
+		CreatedAt:       timestamppb.New(intent.CreatedAt),
+		UpdatedAt:       timestamppb.New(intent.UpdatedAt),
+	}
+	if intent.ProcessedAt != nil {
+		payment.ProcessedAt = timestamppb.New(*intent.ProcessedAt)
 	}
+	// allow_and_flag 정책으로 생성된 중복 intent 표시
+	if intent.DuplicateOf != "" {
+		payment.Metadata = &paymentv1.PaymentMetadata{
+			Scenario: intent.Scenario,
+			Tags:     map[string]string{tagDuplicateOf: intent.DuplicateOf},
+		}
+	}
+	return payment
+}
 
-	response := &paymentv1.GetPaymentStatusResponse{
-		Payment: payment,
+func (s *PaymentService) ProcessPayment(ctx context.Context, req *paymentv1.ProcessPaymentRequest) (*paymentv1.ProcessPaymentResponse, error) {
+	intent, err := s.store.Get(ctx, req.PaymentIntentId)
+	if err != nil {
+		return nil, err
+	}
+
+	// 승인된 결제의 ProcessPayment는 매입(capture)이다
+	if intent.Status == StatusAuthorized {
+		return s.capturePayment(ctx, intent.ID)
+	}
+
+	// Manual trigger - 즉시 상태 변경 (PENDING이면 PROCESSING을 거쳐 최종 상태로)
+	// 같은 seed를 쓰므로 비동기 처리와 동일한 결과가 나온다.
+	// 요청의 카드 번호도 테스트 값 규칙에 매칭한다.
+	outcome, err := s.decideOutcome(intent, req.GetPaymentDetails().GetCreditCard().GetCardNumber())
+	if err != nil {
+		return nil, err
+	}
+	finalStatus, _ := parseStatus(outcome.Status)
+	var ttl time.Duration
+	if finalStatus == paymentv1.PaymentStatus_PAYMENT_STATUS_COMPLETED && intent.CaptureMethod == CaptureManual {
+		finalStatus = StatusAuthorized
+		ttl, err = s.authorizationTTL(intent.Tags)
+		if err != nil {
+			return nil, err
+		}
+	}
+	intent, err = s.settle(ctx, intent.ID, outcome.Code, "manual process",
+		paymentv1.PaymentStatus_PAYMENT_STATUS_PROCESSING,
+		finalStatus,
+	)
+	if err != nil {
+		return nil, err
 	}
 
+	// 비동기 job의 남은 step은 같은 결과를 한 번 더 알리므로 제거하고, 결과는 여기서 알린다.
+	// 수동 매입 승인이면 함께 제거된 만료 job을 지금부터 TTL 뒤로 다시 예약한다.
+	removed := 0
+	if s.webhook != nil {
+		removed = s.webhook.CancelScheduled(intent.ID)
+	}
+	s.logger.Info("Payment processed manually",
+		zap.String("payment_id", intent.ID),
+		zap.String("status", statusName(intent.Status)),
+		zap.Int("cancelled_steps", removed))
+	s.notify(intent, intent.Amount.GetAmount(), "")
+	if intent.Status == StatusAuthorized {
+		s.scheduleExpiry(intent, ttl)
+	}
+
+	response := &paymentv1.ProcessPaymentResponse{
+		PaymentId: intent.ID,
+		Status:    wireStatus(intent.Status),
+		Result:    paymentResult(intent),
+	}
+	if intent.ProcessedAt != nil {
+		response.ProcessedAt = timestamppb.New(*intent.ProcessedAt)
+	}
 
 	return response, nil
 }
 
-func (s *PaymentService) ProcessPayment(ctx context.Context, req *paymentv1.ProcessPaymentRequest) (*paymentv1.ProcessPaymentResponse, error) {
-	s.intentsMu.RLock()
-	intent, exists := s.intents[req.PaymentIntentId]
-	s.intentsMu.RUnlock()
+// UpdatePaymentStatus는 webhook.Dispatcher가 비동기 처리 결과를 반영할 때 사용한다.
+// code는 step의 PG 결과 코드로, FAILED/EXPIRED면 intent의 FailureCode로 남는다.
+func (s *PaymentService) UpdatePaymentStatus(paymentID, status, code string) error {
+	to, ok := parseStatus(status)
+	if !ok {
+		return fmt.Errorf("unknown payment status: %s", status)
+	}
 
-	if !exists {
-		return nil, fmt.Errorf("payment intent not found: %s", req.PaymentIntentId)
+	reason := code
+	if reason == "" {
+		reason = "scenario step"
 	}
+	_, err := s.settle(context.Background(), paymentID, code, reason, to)
+	return err
+}
 
-	// Manual trigger - 즉시 상태 변경
-	finalStatus := s.determineFinalStatus(intent.Scenario)
-	if finalStatus == "PAYMENT_STATUS_COMPLETED" {
-		intent.Status = paymentv1.PaymentStatus_PAYMENT_STATUS_COMPLETED
-	} else {
-		intent.Status = paymentv1.PaymentStatus_PAYMENT_STATUS_FAILED
+// transition은 상태 머신을 통해 path의 각 상태로 순서대로 전이한다.
+// 이미 해당 상태에 있는 단계는 건너뛰며, 중간에 실패하면 아무 것도 반영하지 않는다.
+func (s *PaymentService) transition(ctx context.Context, paymentID, reason string, path ...paymentv1.PaymentStatus) (*PaymentIntent, error) {
+	return s.settle(ctx, paymentID, "", reason, path...)
+}
+
+// settle은 transition과 같되, path가 FAILED나 EXPIRED로 끝나면 실패 코드도
+// 함께 기록한다.
+func (s *PaymentService) settle(ctx context.Context, paymentID, code, reason string, path ...paymentv1.PaymentStatus) (*PaymentIntent, error) {
+	intent, err := s.mutate(ctx, paymentID, func(intent *PaymentIntent) error {
+		for _, to := range path {
+			if intent.Status == to {
+				continue
+			}
+			if err := s.stateMachine.Apply(intent, to, reason); err != nil {
+				return err
+			}
+		}
+		switch intent.Status {
+		case paymentv1.PaymentStatus_PAYMENT_STATUS_FAILED, paymentv1.PaymentStatus_PAYMENT_STATUS_EXPIRED:
+			if code != "" {
+				intent.FailureCode = code
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
 	}
-	now := time.Now()
-	intent.ProcessedAt = &now
 
-	return &paymentv1.ProcessPaymentResponse{
-		PaymentId: intent.ID,
-		Status:    intent.Status,
+	s.logger.Debug("Payment intent status updated",
+		zap.String("payment_id", paymentID),
+		zap.String("status", statusName(intent.Status)),
+		zap.String("reason", reason))
+
+	return intent, nil
+}
+
+// mutate는 최신 intent에 fn을 적용해 저장한다. fn이 에러를 반환하면 아무 것도
+// 반영하지 않으며, 동시 수정으로 CAS가 실패하면 최신 상태를 다시 읽어 재시도한다.
+func (s *PaymentService) mutate(ctx context.Context, paymentID string, fn func(intent *PaymentIntent) error) (*PaymentIntent, error) {
+	for {
+		intent, err := s.store.Get(ctx, paymentID)
+		if err != nil {
+			return nil, err
+		}
+
+		if err := fn(intent); err != nil {
+			return nil, err
+		}
+
+		err = s.store.Update(ctx, intent)
+		if errors.Is(err, ErrVersionConflict) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		return intent, nil
+	}
+}
+
+// buildSteps는 intent의 비동기 처리 단계를 만든다. 이름으로 지정된 스크립트 시나리오가
+// 있으면 그대로 사용하고, 없으면 enum 시나리오를 PROCESSING → 최종 상태 두 단계로 변환한다.
+// 수동 매입 승인이면 expireAfter는 job 시작부터 승인 만료까지의 시간이다 (아니면 0).
+func (s *PaymentService) buildSteps(intent *PaymentIntent) (steps []scenario.Step, expireAfter time.Duration, err error) {
+	steps, err = s.scenarioSteps(intent)
+	if err != nil {
+		return nil, 0, err
+	}
+	if intent.CaptureMethod == CaptureManual {
+		return s.authorizeSteps(intent, steps)
+	}
+	return steps, 0, nil
+}
+
+// defaultScenarioStream은 DEFAULT_SCENARIO 추첨을 decideOutcome이 쓰는 난수열과
+// 분리해, 기존 seed의 결과가 바뀌지 않게 한다.
+const defaultScenarioStream = 0x5ce7a210
+
+// resolveScenario는 UNSPECIFIED를 기본 시나리오 mix에서 추첨한 시나리오로 바꾼다.
+func (s *PaymentService) resolveScenario(requested paymentv1.PaymentScenario, seed uint64) paymentv1.PaymentScenario {
+	if requested != paymentv1.PaymentScenario_PAYMENT_SCENARIO_UNSPECIFIED {
+		return requested
+	}
+	return s.defaultScenario.Pick(newRand(seed ^ defaultScenarioStream))
+}
+
+func (s *PaymentService) scenarioSteps(intent *PaymentIntent) ([]scenario.Step, error) {
+	if name, ok := intent.Tags[tagScenarioName]; ok && s.scenarios != nil {
+		def, found := s.scenarios.Get(name)
+		if !found {
+			return nil, fmt.Errorf("%w: unknown scenario %q", ErrInvalidTag, name)
+		}
+		// 코드 없는 FAILED 단계는 일반 거절로 보고한다
+		steps := append([]scenario.Step(nil), def.Steps...)
+		for i := range steps {
+			if steps[i].Status == paymentv1.PaymentStatus_PAYMENT_STATUS_FAILED.String() && steps[i].Code == "" {
+				steps[i].Code = decline.Generic
+			}
+		}
+		return steps, nil
+	}
+
+	outcome, err := s.decideOutcome(intent, "")
+	if err != nil {
+		return nil, err
+	}
+
+	return []scenario.Step{
+		{Action: scenario.ActionTransition, Status: paymentv1.PaymentStatus_PAYMENT_STATUS_PROCESSING.String()},
+		{Action: scenario.ActionTransition, Status: outcome.Status, After: scenario.Duration(outcome.Delay), Code: outcome.Code},
 	}, nil
 }
 
+// decideOutcome은 intent의 seed로 만든 난수 생성기로 최종 상태, 지연, decline 코드를 결정한다.
+// 결정 순서(상태 → 지연 → 코드)가 바뀌면 기존 seed의 재현 결과도 바뀌므로 유지해야 한다.
+// card가 비어 있으면 metadata.tags["test_card"]를 테스트 값 규칙에 매칭한다.
+func (s *PaymentService) decideOutcome(intent *PaymentIntent, card string) (outcome, error) {
+	rng := newRand(intent.RandomSeed)
+
+	// 매칭된 테스트 값 규칙은 요청 시나리오, delay_*, decline_code 태그보다 우선한다
+	scenario := intent.Scenario
+	rule, matched := s.matchTestValues(intent, card)
+	if matched && rule.ScenarioValue() != paymentv1.PaymentScenario_PAYMENT_SCENARIO_UNSPECIFIED {
+		scenario = rule.ScenarioValue()
+	}
+
+	ratio, err := approvalRatio(s.config, intent.Tags)
+	if err != nil {
+		return outcome{}, err
+	}
+	out := outcome{Status: s.determineFinalStatus(scenario, ratio, rng)}
+
+	out.Delay, err = s.determineDelay(scenario, intent.Tags, rng)
+	if err != nil {
+		return outcome{}, err
+	}
+	if matched && rule.Delay != nil {
+		out.Delay = time.Duration(*rule.Delay)
+	}
+
+	// 잘못된 decline_code 태그는 승인 시나리오에서도 생성 시점에 거부한다
+	code, err := declineCode(scenario, intent.Tags, rng)
+	if err != nil {
+		return outcome{}, err
+	}
+	if matched && rule.DeclineCode != "" {
+		code = rule.DeclineCode
+	}
+	if out.Status == paymentv1.PaymentStatus_PAYMENT_STATUS_FAILED.String() {
+		out.Code = code
+	}
+	return out, nil
+}
+
+func (s *PaymentService) matchTestValues(intent *PaymentIntent, card string) (testvalues.Rule, bool) {
+	if card == "" {
+		card = intent.Tags[tagTestCard]
+	}
+	req := testvalues.Request{
+		UserID:        intent.UserID,
+		ReservationID: intent.ReservationID,
+		TestCard:      card,
+	}
+	if intent.Amount != nil {
+		req.Amount = intent.Amount.Amount
+	}
+	return s.testValues.Match(req)
+}
+
+// 시나리오에 따른 처리 지연 결정. DELAY 시나리오만 분포/metadata 설정을 따른다.
+func (s *PaymentService) determineDelay(scenario paymentv1.PaymentScenario, tags map[string]string, rng *rand.Rand) (time.Duration, error) {
+	if scenario != paymentv1.PaymentScenario_PAYMENT_SCENARIO_DELAY {
+		return time.Duration(s.config.DefaultDelayMs) * time.Millisecond, nil
+	}
+
+	spec, err := delaySpecFromConfig(s.config).withTags(tags)
+	if err != nil {
+		return 0, err
+	}
+	return spec.Sample(rng), nil
+}
+
 // 시나리오에 따른 최종 상태 결정 (가라 데이터)
-func (s *PaymentService) determineFinalStatus(scenario paymentv1.PaymentScenario) string {
+func (s *PaymentService) determineFinalStatus(scenario paymentv1.PaymentScenario, approvalRatio float64, rng *rand.Rand) string {
 	switch scenario {
 	case paymentv1.PaymentScenario_PAYMENT_SCENARIO_APPROVE:
 		return "PAYMENT_STATUS_COMPLETED"
 	case paymentv1.PaymentScenario_PAYMENT_SCENARIO_FAIL:
 		return "PAYMENT_STATUS_FAILED"
+	case paymentv1.PaymentScenario_PAYMENT_SCENARIO_DELAY:
+		return "PAYMENT_STATUS_COMPLETED" // 지연 후 승인
 	case paymentv1.PaymentScenario_PAYMENT_SCENARIO_RANDOM:
-		if time.Now().UnixNano()%2 == 0 {
+		if rng.Float64() < approvalRatio {
 			return "PAYMENT_STATUS_COMPLETED"
 		} else {
 			return "PAYMENT_STATUS_FAILED"
@@ -163,4 +665,3 @@ func (s *PaymentService) determineFinalStatus(scenario paymentv1.PaymentScenario
 		return "PAYMENT_STATUS_COMPLETED"
 	}
 }
-
@@ -0,0 +1,173 @@
+package service
+
+import (
+	"context"
+	"slices"
+	"sync"
+	"testing"
+
+	"github.com/kelseyhightower/envconfig"
+	"go.uber.org/zap"
+
+	commonv1 "github.com/traffic-tacos/proto-contracts/gen/go/common/v1"
+	paymentv1 "github.com/traffic-tacos/proto-contracts/gen/go/payment/v1"
+	"github.com/traffic-tacos/payment-sim-api/internal/config"
+	"github.com/traffic-tacos/payment-sim-api/internal/scenario"
+	"github.com/traffic-tacos/payment-sim-api/internal/webhook"
+)
+
+// fakeSender stands in for webhook.Dispatcher. It keeps every scheduled job
+// until CancelScheduled, which with kinds removes the jobs of those kinds only.
+type fakeSender struct {
+	mu   sync.Mutex
+	jobs []webhook.Job
+}
+
+func (f *fakeSender) SchedulePayment(job webhook.Job) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.jobs = append(f.jobs, job)
+	return nil
+}
+
+func (f *fakeSender) CancelScheduled(paymentID string, kinds ...webhook.JobKind) int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	removed := 0
+	kept := f.jobs[:0]
+	for _, job := range f.jobs {
+		if job.PaymentID == paymentID && job.RefundID == "" && (len(kinds) == 0 || slices.Contains(kinds, job.Kind)) {
+			removed += len(job.Steps)
+			continue
+		}
+		kept = append(kept, job)
+	}
+	f.jobs = kept
+	return removed
+}
+
+// queued reports whether a job of the given kind is queued for a payment.
+func (f *fakeSender) queued(paymentID string, kind webhook.JobKind) bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return slices.ContainsFunc(f.jobs, func(job webhook.Job) bool {
+		return job.PaymentID == paymentID && job.Kind == kind
+	})
+}
+
+// jobsFor returns the queued jobs of a payment.
+func (f *fakeSender) jobsFor(paymentID string) []webhook.Job {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	var jobs []webhook.Job
+	for _, job := range f.jobs {
+		if job.PaymentID == paymentID {
+			jobs = append(jobs, job)
+		}
+	}
+	return jobs
+}
+
+// testConfig returns the config defaults with a fixed random seed.
+func testConfig(t *testing.T) *config.Config {
+	t.Helper()
+	var cfg config.Config
+	if err := envconfig.Process("", &cfg); err != nil {
+		t.Fatalf("envconfig: %v", err)
+	}
+	cfg.RandomSeed = 1
+	return &cfg
+}
+
+func newTestService(t *testing.T, cfg *config.Config, sender WebhookSender) *PaymentService {
+	t.Helper()
+	mix, err := scenario.ParseMix(cfg.DefaultScenario)
+	if err != nil {
+		t.Fatalf("ParseMix: %v", err)
+	}
+	return NewPaymentService(zap.NewNop(), cfg, NewMemoryStore(), sender, nil, nil, nil, mix)
+}
+
+func TestProcessPaymentNotifies(t *testing.T) {
+	tests := []struct {
+		name      string
+		scenario  paymentv1.PaymentScenario
+		tags      map[string]string
+		want      paymentv1.PaymentStatus
+		wantSteps []string
+	}{
+		{
+			name:      "approve",
+			scenario:  paymentv1.PaymentScenario_PAYMENT_SCENARIO_APPROVE,
+			want:      completed,
+			wantSteps: []string{completed.String()},
+		},
+		{
+			name:      "fail",
+			scenario:  paymentv1.PaymentScenario_PAYMENT_SCENARIO_FAIL,
+			want:      failed,
+			wantSteps: []string{failed.String()},
+		},
+		{
+			name:      "authorize",
+			scenario:  paymentv1.PaymentScenario_PAYMENT_SCENARIO_APPROVE,
+			tags:      map[string]string{tagCaptureMethod: CaptureManual},
+			want:      StatusAuthorized,
+			wantSteps: []string{statusAuthorizedName, expired.String()},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			sender := &fakeSender{}
+			s := newTestService(t, testConfig(t), sender)
+
+			created, err := s.CreatePaymentIntent(ctx, &paymentv1.CreatePaymentIntentRequest{
+				ReservationId: "rsv-" + tt.name,
+				UserId:        "user-1",
+				Amount:        &commonv1.Money{Amount: 10000, Currency: "KRW"},
+				Scenario:      tt.scenario,
+				Metadata:      &paymentv1.PaymentMetadata{Tags: tt.tags},
+			})
+			if err != nil {
+				t.Fatalf("CreatePaymentIntent: %v", err)
+			}
+			id := created.PaymentIntentId
+
+			if _, err := s.ProcessPayment(ctx, &paymentv1.ProcessPaymentRequest{PaymentIntentId: id}); err != nil {
+				t.Fatalf("ProcessPayment: %v", err)
+			}
+
+			// 비동기 시나리오 job은 제거되고 알림 job(수동 매입 승인이면 만료 job도)만 남는다
+			jobs := sender.jobsFor(id)
+			var steps []string
+			for _, job := range jobs {
+				if len(job.Steps) != 1 {
+					t.Fatalf("queued jobs = %+v, want one step each", jobs)
+				}
+				steps = append(steps, job.Steps[0].Status)
+			}
+			if !slices.Equal(steps, tt.wantSteps) {
+				t.Fatalf("queued steps = %v, want %v", steps, tt.wantSteps)
+			}
+			if jobs[0].Steps[0].Action != scenario.ActionNotify {
+				t.Errorf("notification action = %q, want %q", jobs[0].Steps[0].Action, scenario.ActionNotify)
+			}
+			if tt.want == failed && jobs[0].Steps[0].Code == "" {
+				t.Error("failure notification has no decline code")
+			}
+
+			intent, err := s.store.Get(ctx, id)
+			if err != nil {
+				t.Fatalf("Get: %v", err)
+			}
+			if intent.Status != tt.want {
+				t.Errorf("status = %s, want %s", statusName(intent.Status), statusName(tt.want))
+			}
+		})
+	}
+}
@@ -0,0 +1,147 @@
+package service
+
+import (
+	"fmt"
+	"time"
+
+	paymentv1 "github.com/traffic-tacos/proto-contracts/gen/go/payment/v1"
+)
+
+// TransitionError는 상태 머신이 허용하지 않는 전이를 시도했을 때 반환된다.
+type TransitionError struct {
+	PaymentID string
+	From      paymentv1.PaymentStatus
+	To        paymentv1.PaymentStatus
+}
+
+func (e *TransitionError) Error() string {
+	return fmt.Sprintf("invalid payment status transition for %s: %s -> %s", e.PaymentID, statusName(e.From), statusName(e.To))
+}
+
+// StatusAuthorized는 proto에 없는 시뮬레이터 전용 상태로, 승인만 되고 아직 매입(capture)되지
+// 않은 결제를 뜻한다. gRPC 응답에서는 PROCESSING으로 노출된다 (wireStatus 참고).
+const StatusAuthorized paymentv1.PaymentStatus = 100
+
+const statusAuthorizedName = "PAYMENT_STATUS_AUTHORIZED"
+
+// statusName은 status의 enum 이름을 반환한다. StatusAuthorized도 포함한다.
+func statusName(status paymentv1.PaymentStatus) string {
+	if status == StatusAuthorized {
+		return statusAuthorizedName
+	}
+	return status.String()
+}
+
+// parseStatus는 statusName의 역이다.
+func parseStatus(name string) (paymentv1.PaymentStatus, bool) {
+	if name == statusAuthorizedName {
+		return StatusAuthorized, true
+	}
+	v, ok := paymentv1.PaymentStatus_value[name]
+	return paymentv1.PaymentStatus(v), ok
+}
+
+// wireStatus는 내부 상태를 proto enum으로 바꾼다.
+func wireStatus(status paymentv1.PaymentStatus) paymentv1.PaymentStatus {
+	if status == StatusAuthorized {
+		return paymentv1.PaymentStatus_PAYMENT_STATUS_PROCESSING
+	}
+	return status
+}
+
+// StatusTransition은 결제 intent 상태 이력의 항목 하나다.
+type StatusTransition struct {
+	From   paymentv1.PaymentStatus
+	To     paymentv1.PaymentStatus
+	Reason string
+	At     time.Time
+}
+
+// 실제 PG사 결제 상태 흐름
+//
+//	PENDING ─► PROCESSING ─► COMPLETED ─► REFUNDED
+//	   │            ├──────► FAILED            ▲ capture
+//	   │            ├──────► CANCELLED         │
+//	   │            └──────► AUTHORIZED ───────┤ (capture_method=manual)
+//	   │                          └──► CANCELLED (void) / EXPIRED
+//	   ├─► CANCELLED / EXPIRED / FAILED
+var defaultTransitions = map[paymentv1.PaymentStatus][]paymentv1.PaymentStatus{
+	paymentv1.PaymentStatus_PAYMENT_STATUS_PENDING: {
+		paymentv1.PaymentStatus_PAYMENT_STATUS_PROCESSING,
+		paymentv1.PaymentStatus_PAYMENT_STATUS_FAILED,
+		paymentv1.PaymentStatus_PAYMENT_STATUS_CANCELLED,
+		paymentv1.PaymentStatus_PAYMENT_STATUS_EXPIRED,
+	},
+	paymentv1.PaymentStatus_PAYMENT_STATUS_PROCESSING: {
+		paymentv1.PaymentStatus_PAYMENT_STATUS_COMPLETED,
+		paymentv1.PaymentStatus_PAYMENT_STATUS_FAILED,
+		paymentv1.PaymentStatus_PAYMENT_STATUS_CANCELLED,
+		StatusAuthorized,
+	},
+	StatusAuthorized: {
+		paymentv1.PaymentStatus_PAYMENT_STATUS_COMPLETED,
+		paymentv1.PaymentStatus_PAYMENT_STATUS_CANCELLED,
+		paymentv1.PaymentStatus_PAYMENT_STATUS_EXPIRED,
+	},
+	paymentv1.PaymentStatus_PAYMENT_STATUS_COMPLETED: {
+		paymentv1.PaymentStatus_PAYMENT_STATUS_REFUNDED,
+	},
+}
+
+// StateMachine은 PaymentIntent의 모든 상태 변경을 맡는다. 호출자는 Apply를
+// 부르는 동안 intent를 보호하는 lock을 잡고 있어야 한다.
+type StateMachine struct {
+	transitions map[paymentv1.PaymentStatus]map[paymentv1.PaymentStatus]bool
+}
+
+func NewStateMachine() *StateMachine {
+	transitions := make(map[paymentv1.PaymentStatus]map[paymentv1.PaymentStatus]bool, len(defaultTransitions))
+	for from, targets := range defaultTransitions {
+		transitions[from] = make(map[paymentv1.PaymentStatus]bool, len(targets))
+		for _, to := range targets {
+			transitions[from][to] = true
+		}
+	}
+	return &StateMachine{transitions: transitions}
+}
+
+func (m *StateMachine) CanTransition(from, to paymentv1.PaymentStatus) bool {
+	return m.transitions[from][to]
+}
+
+// CanTransitionName은 시나리오 step에서 쓰는 상태 enum 이름용 CanTransition이다.
+// 모르는 이름은 허용하지 않는다.
+func (m *StateMachine) CanTransitionName(from, to string) bool {
+	fromStatus, ok := parseStatus(from)
+	if !ok {
+		return false
+	}
+	toStatus, ok := parseStatus(to)
+	return ok && m.CanTransition(fromStatus, toStatus)
+}
+
+// Apply는 intent를 주어진 상태로 옮기고 전이를 기록한다.
+func (m *StateMachine) Apply(intent *PaymentIntent, to paymentv1.PaymentStatus, reason string) error {
+	if !m.CanTransition(intent.Status, to) {
+		return &TransitionError{PaymentID: intent.ID, From: intent.Status, To: to}
+	}
+
+	now := time.Now()
+	intent.History = append(intent.History, StatusTransition{
+		From:   intent.Status,
+		To:     to,
+		Reason: reason,
+		At:     now,
+	})
+	intent.Status = to
+	intent.UpdatedAt = now
+
+	switch to {
+	case paymentv1.PaymentStatus_PAYMENT_STATUS_COMPLETED, paymentv1.PaymentStatus_PAYMENT_STATUS_FAILED:
+		intent.ProcessedAt = &now
+	case StatusAuthorized:
+		intent.AuthorizedAt = &now
+	}
+
+	return nil
+}
@@ -0,0 +1,129 @@
+package service
+
+import (
+	"errors"
+	"testing"
+
+	paymentv1 "github.com/traffic-tacos/proto-contracts/gen/go/payment/v1"
+	"github.com/traffic-tacos/payment-sim-api/internal/scenario"
+)
+
+const (
+	pending    = paymentv1.PaymentStatus_PAYMENT_STATUS_PENDING
+	processing = paymentv1.PaymentStatus_PAYMENT_STATUS_PROCESSING
+	completed  = paymentv1.PaymentStatus_PAYMENT_STATUS_COMPLETED
+	failed     = paymentv1.PaymentStatus_PAYMENT_STATUS_FAILED
+	cancelled  = paymentv1.PaymentStatus_PAYMENT_STATUS_CANCELLED
+	refunded   = paymentv1.PaymentStatus_PAYMENT_STATUS_REFUNDED
+	expired    = paymentv1.PaymentStatus_PAYMENT_STATUS_EXPIRED
+)
+
+func TestStateMachineCanTransition(t *testing.T) {
+	tests := []struct {
+		from, to paymentv1.PaymentStatus
+		want     bool
+	}{
+		{pending, processing, true},
+		{pending, failed, true},
+		{pending, cancelled, true},
+		{pending, expired, true},
+		{pending, completed, false}, // PROCESSING을 건너뛸 수 없다
+		{pending, StatusAuthorized, false},
+		{pending, refunded, false},
+		{processing, completed, true},
+		{processing, failed, true},
+		{processing, cancelled, true},
+		{processing, StatusAuthorized, true},
+		{processing, pending, false},
+		{processing, expired, false},
+		{StatusAuthorized, completed, true},
+		{StatusAuthorized, cancelled, true},
+		{StatusAuthorized, expired, true},
+		{StatusAuthorized, failed, false},
+		{StatusAuthorized, refunded, false},
+		{completed, refunded, true},
+		{completed, cancelled, false},
+		{completed, failed, false},
+		{failed, processing, false},
+		{cancelled, processing, false},
+		{expired, completed, false},
+		{refunded, completed, false},
+		{completed, completed, false},
+	}
+
+	m := NewStateMachine()
+	for _, tt := range tests {
+		if got := m.CanTransition(tt.from, tt.to); got != tt.want {
+			t.Errorf("CanTransition(%s, %s) = %v, want %v", statusName(tt.from), statusName(tt.to), got, tt.want)
+		}
+	}
+}
+
+func TestStateMachineApply(t *testing.T) {
+	tests := []struct {
+		name          string
+		from, to      paymentv1.PaymentStatus
+		wantErr       bool
+		wantProcessed bool
+		wantAuthorize bool
+	}{
+		{name: "processing", from: pending, to: processing},
+		{name: "complete", from: processing, to: completed, wantProcessed: true},
+		{name: "fail", from: processing, to: failed, wantProcessed: true},
+		{name: "authorize", from: processing, to: StatusAuthorized, wantAuthorize: true},
+		{name: "capture", from: StatusAuthorized, to: completed, wantProcessed: true},
+		{name: "expire authorization", from: StatusAuthorized, to: expired},
+		{name: "skip processing", from: pending, to: completed, wantErr: true},
+		{name: "reopen failed", from: failed, to: processing, wantErr: true},
+		{name: "refund pending", from: pending, to: refunded, wantErr: true},
+	}
+
+	m := NewStateMachine()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			intent := &PaymentIntent{ID: "pay-1", Status: tt.from}
+			err := m.Apply(intent, tt.to, "test")
+
+			if tt.wantErr {
+				var transitionErr *TransitionError
+				if !errors.As(err, &transitionErr) {
+					t.Fatalf("Apply error = %v, want *TransitionError", err)
+				}
+				if transitionErr.From != tt.from || transitionErr.To != tt.to {
+					t.Errorf("TransitionError = %s -> %s, want %s -> %s",
+						statusName(transitionErr.From), statusName(transitionErr.To), statusName(tt.from), statusName(tt.to))
+				}
+				if intent.Status != tt.from || len(intent.History) != 0 {
+					t.Errorf("rejected transition changed the intent: status %s, history %v", statusName(intent.Status), intent.History)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("Apply: %v", err)
+			}
+			if intent.Status != tt.to {
+				t.Errorf("status = %s, want %s", statusName(intent.Status), statusName(tt.to))
+			}
+			if len(intent.History) != 1 || intent.History[0].From != tt.from || intent.History[0].To != tt.to || intent.History[0].Reason != "test" {
+				t.Errorf("history = %+v, want one %s -> %s entry", intent.History, statusName(tt.from), statusName(tt.to))
+			}
+			if (intent.ProcessedAt != nil) != tt.wantProcessed {
+				t.Errorf("ProcessedAt set = %v, want %v", intent.ProcessedAt != nil, tt.wantProcessed)
+			}
+			if (intent.AuthorizedAt != nil) != tt.wantAuthorize {
+				t.Errorf("AuthorizedAt set = %v, want %v", intent.AuthorizedAt != nil, tt.wantAuthorize)
+			}
+		})
+	}
+}
+
+func TestExampleScenariosPassStateMachine(t *testing.T) {
+	r := scenario.NewRegistry(NewStateMachine().CanTransitionName)
+	if _, err := r.LoadFile("../../scenarios/examples.yaml"); err != nil {
+		t.Fatalf("examples.yaml: %v", err)
+	}
+	if _, err := r.Load([]byte("name: skip\nsteps:\n  - status: COMPLETED\n")); err == nil {
+		t.Error("PENDING -> COMPLETED script was accepted")
+	}
+}
@@ -0,0 +1,43 @@
+package service
+
+import (
+	"context"
+	"fmt"
+
+	"go.uber.org/zap"
+
+	"github.com/traffic-tacos/payment-sim-api/internal/config"
+)
+
+var (
+	ErrIntentNotFound  = newError(KindNotFound, "PAYMENT_INTENT_NOT_FOUND", "payment intent not found")
+	ErrIntentExists    = newError(KindAlreadyExists, "PAYMENT_INTENT_EXISTS", "payment intent already exists")
+	ErrVersionConflict = newError(KindAborted, "VERSION_CONFLICT", "payment intent version conflict")
+)
+
+// Store는 결제 intent를 저장한다. 구현은 동시에 써도 안전해야 하며 내부 상태의
+// 포인터를 밖으로 내주면 안 된다.
+type Store interface {
+	Get(ctx context.Context, id string) (*PaymentIntent, error)
+	// Put은 새 intent를 저장한다. ID가 이미 있으면 ErrIntentExists로 실패한다.
+	Put(ctx context.Context, intent *PaymentIntent) error
+	// Update는 저장된 Version이 아직 intent.Version과 같을 때만 교체하고
+	// (compare-and-swap), 성공하면 version을 올린다.
+	Update(ctx context.Context, intent *PaymentIntent) error
+	List(ctx context.Context) ([]*PaymentIntent, error)
+	// ListByReservation은 예약의 intent를 오래된 것부터 반환한다.
+	ListByReservation(ctx context.Context, reservationID string) ([]*PaymentIntent, error)
+	Close() error
+}
+
+// NewStore는 cfg.IntentStore로 고른 intent store를 반환한다.
+func NewStore(logger *zap.Logger, cfg *config.Config) (Store, error) {
+	switch cfg.IntentStore {
+	case "", "memory":
+		return NewMemoryStore(), nil
+	case "file":
+		return NewFileStore(logger, cfg.IntentStorePath, cfg.IntentStoreCompactRecords, cfg.IntentStoreCompactBytes)
+	default:
+		return nil, fmt.Errorf("unknown intent store: %s", cfg.IntentStore)
+	}
+}
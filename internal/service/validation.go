@@ -0,0 +1,123 @@
+package service
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+
+	"google.golang.org/protobuf/proto"
+
+	paymentv1 "github.com/traffic-tacos/proto-contracts/gen/go/payment/v1"
+)
+
+const (
+	maxIDLength             = 128
+	maxIdempotencyKeyLength = 255
+)
+
+// FieldViolation은 잘못된 요청 필드 하나를 설명한다. Field는 proto 필드
+// 경로다 (예: "amount.currency").
+type FieldViolation struct {
+	Field       string
+	Description string
+}
+
+// ValidationError는 요청의 잘못된 필드를 모두 담는다.
+type ValidationError struct {
+	Violations []FieldViolation
+}
+
+func (e *ValidationError) Error() string {
+	parts := make([]string, 0, len(e.Violations))
+	for _, v := range e.Violations {
+		parts = append(parts, v.Field+": "+v.Description)
+	}
+	return "invalid request: " + strings.Join(parts, "; ")
+}
+
+// validator는 필드 위반 사유를 모은다.
+type validator struct {
+	violations []FieldViolation
+}
+
+func (v *validator) addf(field, format string, args ...interface{}) {
+	v.violations = append(v.violations, FieldViolation{Field: field, Description: fmt.Sprintf(format, args...)})
+}
+
+func (v *validator) err() error {
+	if len(v.violations) == 0 {
+		return nil
+	}
+	return &ValidationError{Violations: v.violations}
+}
+
+func (v *validator) requireID(field, value string) {
+	switch {
+	case strings.TrimSpace(value) == "":
+		v.addf(field, "must not be empty")
+	case len(value) > maxIDLength:
+		v.addf(field, "must be at most %d characters", maxIDLength)
+	}
+}
+
+// normalizeCreatePaymentIntent는 통화 코드를 대문자로 바꿔 "krw"를 KRW로
+// 저장하고 보고한다. 호출자의 요청은 바꾸지 않는다.
+func normalizeCreatePaymentIntent(req *paymentv1.CreatePaymentIntentRequest) *paymentv1.CreatePaymentIntentRequest {
+	currency := req.GetAmount().GetCurrency()
+	if currency == strings.ToUpper(currency) {
+		return req
+	}
+	req = proto.Clone(req).(*paymentv1.CreatePaymentIntentRequest)
+	req.Amount.Currency = strings.ToUpper(currency)
+	return req
+}
+
+// validateCreatePaymentIntent는 다른 처리보다 먼저 요청을 검사한다.
+func validateCreatePaymentIntent(req *paymentv1.CreatePaymentIntentRequest) error {
+	var v validator
+
+	v.requireID("reservation_id", req.ReservationId)
+	v.requireID("user_id", req.UserId)
+
+	if req.Amount == nil {
+		v.addf("amount", "is required")
+	} else {
+		rule, ok := lookupCurrency(req.Amount.Currency)
+		switch {
+		case req.Amount.Currency == "":
+			v.addf("amount.currency", "is required")
+		case !ok:
+			v.addf("amount.currency", "%q is not a supported ISO 4217 currency code", req.Amount.Currency)
+		}
+
+		amount := req.Amount.Amount
+		switch {
+		case amount <= 0:
+			v.addf("amount.amount", "must be a positive number of minor units")
+		case amount > maxAmountMinorUnits:
+			v.addf("amount.amount", "must be at most %d minor units", int64(maxAmountMinorUnits))
+		case ok && amount%rule.Increment != 0:
+			v.addf("amount.amount", "%s amounts must be a multiple of %d minor units (exponent %d)",
+				strings.ToUpper(req.Amount.Currency), rule.Increment, rule.Exponent)
+		}
+	}
+
+	if _, ok := paymentv1.PaymentScenario_name[int32(req.Scenario)]; !ok {
+		v.addf("scenario", "unknown scenario %d", req.Scenario)
+	}
+	if _, ok := paymentv1.PaymentMethod_name[int32(req.Method)]; !ok {
+		v.addf("method", "unknown payment method %d", req.Method)
+	}
+
+	if req.WebhookUrl != "" {
+		if u, err := url.Parse(req.WebhookUrl); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			v.addf("webhook_url", "must be an absolute http(s) URL")
+		}
+	}
+
+	if len(req.IdempotencyKey) > maxIdempotencyKeyLength {
+		v.addf("idempotency_key", "must be at most %d characters", maxIdempotencyKeyLength)
+	}
+
+	return v.err()
+}
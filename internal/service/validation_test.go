@@ -0,0 +1,167 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"strings"
+	"testing"
+
+	"google.golang.org/grpc/metadata"
+
+	commonv1 "github.com/traffic-tacos/proto-contracts/gen/go/common/v1"
+	paymentv1 "github.com/traffic-tacos/proto-contracts/gen/go/payment/v1"
+)
+
+func TestValidateCreatePaymentIntentAmount(t *testing.T) {
+	tests := []struct {
+		currency  string
+		amount    int64
+		wantField string // "" = valid
+		wantDesc  string
+	}{
+		{currency: "KRW", amount: 1},
+		{currency: "KRW", amount: 15000},
+		{currency: "JPY", amount: 99_999_999},
+		{currency: "USD", amount: 1},
+		{currency: "USD", amount: 1505},
+		{currency: "KWD", amount: 1500},
+		{currency: "HUF", amount: 12300},
+		{currency: "HUF", amount: 12345, wantField: "amount.amount", wantDesc: "multiple of 100"},
+		{currency: "TWD", amount: 100},
+		{currency: "TWD", amount: 150, wantField: "amount.amount", wantDesc: "multiple of 100"},
+		{currency: "krw", amount: 1000},
+		{currency: "Usd", amount: 1},
+		{currency: "huf", amount: 150, wantField: "amount.amount", wantDesc: "HUF amounts"},
+		{currency: "", amount: 1000, wantField: "amount.currency", wantDesc: "is required"},
+		{currency: "XAU", amount: 1000, wantField: "amount.currency", wantDesc: "not a supported"},
+		{currency: "KRW", amount: 0, wantField: "amount.amount", wantDesc: "positive"},
+		{currency: "KRW", amount: 100_000_000, wantField: "amount.amount", wantDesc: "at most"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.currency+"/"+strconv.FormatInt(tt.amount, 10), func(t *testing.T) {
+			err := validateCreatePaymentIntent(&paymentv1.CreatePaymentIntentRequest{
+				ReservationId: "rsv-1",
+				UserId:        "user-1",
+				Amount:        &commonv1.Money{Amount: tt.amount, Currency: tt.currency},
+			})
+			if tt.wantField == "" {
+				if err != nil {
+					t.Fatalf("validate = %v, want nil", err)
+				}
+				return
+			}
+
+			var validationErr *ValidationError
+			if !errors.As(err, &validationErr) {
+				t.Fatalf("validate = %v, want *ValidationError", err)
+			}
+			if len(validationErr.Violations) != 1 {
+				t.Fatalf("violations = %+v, want one", validationErr.Violations)
+			}
+			got := validationErr.Violations[0]
+			if got.Field != tt.wantField || !strings.Contains(got.Description, tt.wantDesc) {
+				t.Errorf("violation = %s: %s, want %s containing %q", got.Field, got.Description, tt.wantField, tt.wantDesc)
+			}
+		})
+	}
+}
+
+func TestCreatePaymentIntentNormalizesCurrency(t *testing.T) {
+	ctx := context.Background()
+	s := newTestService(t, testConfig(t), &fakeSender{})
+
+	req := &paymentv1.CreatePaymentIntentRequest{
+		ReservationId: "rsv-1",
+		UserId:        "user-1",
+		Amount:        &commonv1.Money{Amount: 10000, Currency: "krw"},
+	}
+	created, err := s.CreatePaymentIntent(ctx, req)
+	if err != nil {
+		t.Fatalf("CreatePaymentIntent: %v", err)
+	}
+	if req.Amount.Currency != "krw" {
+		t.Errorf("caller's request was modified: currency %q", req.Amount.Currency)
+	}
+	intent, err := s.store.Get(ctx, created.PaymentIntentId)
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	if intent.Amount.GetCurrency() != "KRW" {
+		t.Errorf("stored currency = %q, want KRW", intent.Amount.GetCurrency())
+	}
+}
+
+func TestPartialAmountIncrement(t *testing.T) {
+	tests := []struct {
+		currency string
+		amount   int64
+		wantErr  bool
+	}{
+		{currency: "KRW", amount: 5050},
+		{currency: "HUF", amount: 5000},
+		{currency: "HUF", amount: 5050, wantErr: true},
+		{currency: "TWD", amount: 1, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		name := tt.currency + "/" + strconv.FormatInt(tt.amount, 10)
+
+		t.Run("capture "+name, func(t *testing.T) {
+			s := newTestService(t, testConfig(t), &fakeSender{})
+			id := authorizedIntent(t, s, tt.currency)
+
+			ctx := metadata.NewIncomingContext(context.Background(),
+				metadata.Pairs(captureAmountMetadata, strconv.FormatInt(tt.amount, 10)))
+			_, err := s.ProcessPayment(ctx, &paymentv1.ProcessPaymentRequest{PaymentIntentId: id})
+			if tt.wantErr && !errors.Is(err, ErrCaptureAmount) {
+				t.Errorf("capture %d %s = %v, want ErrCaptureAmount", tt.amount, tt.currency, err)
+			}
+			if !tt.wantErr && err != nil {
+				t.Errorf("capture %d %s: %v", tt.amount, tt.currency, err)
+			}
+		})
+
+		t.Run("refund "+name, func(t *testing.T) {
+			intent := &PaymentIntent{
+				Amount: &commonv1.Money{Amount: 10000, Currency: tt.currency},
+				Status: completed,
+			}
+			got, err := refundAmount(intent, &commonv1.Money{Amount: tt.amount, Currency: tt.currency})
+			if tt.wantErr {
+				if !errors.Is(err, ErrRefundAmount) {
+					t.Errorf("refund %d %s = %v, want ErrRefundAmount", tt.amount, tt.currency, err)
+				}
+				return
+			}
+			if err != nil || got != tt.amount {
+				t.Errorf("refund %d %s = %d, %v", tt.amount, tt.currency, got, err)
+			}
+		})
+	}
+}
+
+// authorizedIntent creates a manual-capture intent of 10000 minor units and
+// moves it to AUTHORIZED like the dispatcher would.
+func authorizedIntent(t *testing.T, s *PaymentService, currency string) string {
+	t.Helper()
+	created, err := s.CreatePaymentIntent(context.Background(), &paymentv1.CreatePaymentIntentRequest{
+		ReservationId: "rsv-" + currency,
+		UserId:        "user-1",
+		Amount:        &commonv1.Money{Amount: 10000, Currency: currency},
+		Scenario:      paymentv1.PaymentScenario_PAYMENT_SCENARIO_APPROVE,
+		Metadata: &paymentv1.PaymentMetadata{
+			Tags: map[string]string{tagCaptureMethod: CaptureManual},
+		},
+	})
+	if err != nil {
+		t.Fatalf("CreatePaymentIntent: %v", err)
+	}
+	for _, status := range []string{processing.String(), statusAuthorizedName} {
+		if err := s.UpdatePaymentStatus(created.PaymentIntentId, status, ""); err != nil {
+			t.Fatalf("UpdatePaymentStatus(%s): %v", status, err)
+		}
+	}
+	return created.PaymentIntentId
+}
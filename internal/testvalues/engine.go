@@ -0,0 +1,119 @@
+package testvalues
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+
+	"go.yaml.in/yaml/v2"
+
+	"github.com/traffic-tacos/payment-sim-api/internal/decline"
+)
+
+// Engine은 요청을 설정된 규칙, 그다음 내장 거절 테스트 카드 순으로 맞춰 본다.
+// 처음 맞는 규칙이 이긴다.
+type Engine struct {
+	mu       sync.RWMutex
+	rules    []Rule
+	defaults []Rule
+}
+
+func NewEngine() *Engine {
+	return &Engine{
+		defaults: Defaults(),
+	}
+}
+
+// Defaults는 내장 규칙을 반환한다. 거절 테스트 카드와 그 tok_<code> 토큰은
+// 모두 해당 사유로 실패한다.
+func Defaults() []Rule {
+	var rules []Rule
+	for _, r := range decline.All() {
+		token := "tok_" + strings.ToLower(r.Code)
+		for _, card := range []string{r.TestCard, token} {
+			rule := Rule{
+				Name:        "test-card-" + card,
+				Match:       Match{TestCard: card},
+				DeclineCode: r.Code,
+			}
+			if err := rule.Validate(); err != nil {
+				panic(err)
+			}
+			rules = append(rules, rule)
+		}
+	}
+	return rules
+}
+
+// document는 파일과 admin API의 형식이다. YAML은 JSON의 상위 집합이므로 둘 다
+// 받는다.
+type document struct {
+	Rules []Rule `yaml:"rules"`
+}
+
+// Parse는 {rules: [...]} 문서를 디코딩한다.
+func Parse(data []byte) ([]Rule, error) {
+	var doc document
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("failed to parse test value rules: %w", err)
+	}
+
+	names := make(map[string]bool, len(doc.Rules))
+	for i := range doc.Rules {
+		if err := doc.Rules[i].Validate(); err != nil {
+			return nil, err
+		}
+		if names[doc.Rules[i].Name] {
+			return nil, fmt.Errorf("duplicate test value rule %s", doc.Rules[i].Name)
+		}
+		names[doc.Rules[i].Name] = true
+	}
+	return doc.Rules, nil
+}
+
+// LoadFile은 설정된 규칙을 YAML/JSON 파일의 규칙으로 교체한다.
+func (e *Engine) LoadFile(path string) ([]Rule, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read test value file: %w", err)
+	}
+	rules, err := Parse(data)
+	if err != nil {
+		return nil, err
+	}
+	e.Replace(rules)
+	return rules, nil
+}
+
+// Replace는 설정된 규칙을 교체한다. 내장 규칙은 유지한다.
+func (e *Engine) Replace(rules []Rule) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.rules = rules
+}
+
+// Rules는 설정된 규칙과 내장 규칙을 매칭 순서대로 반환한다.
+func (e *Engine) Rules() []Rule {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	rules := make([]Rule, 0, len(e.rules)+len(e.defaults))
+	rules = append(rules, e.rules...)
+	return append(rules, e.defaults...)
+}
+
+// Match는 req에 맞는 첫 규칙을 반환한다.
+func (e *Engine) Match(req Request) (Rule, bool) {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	for _, rules := range [][]Rule{e.rules, e.defaults} {
+		for _, rule := range rules {
+			if rule.matches(req) {
+				return rule, true
+			}
+		}
+	}
+	return Rule{}, false
+}
@@ -0,0 +1,233 @@
+package testvalues
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	paymentv1 "github.com/traffic-tacos/proto-contracts/gen/go/payment/v1"
+	"github.com/traffic-tacos/payment-sim-api/internal/decline"
+)
+
+// exampleEngine은 scenarios/test-values.yaml에 포함된 규칙을 읽는다.
+func exampleEngine(t *testing.T) *Engine {
+	t.Helper()
+	e := NewEngine()
+	if _, err := e.LoadFile(filepath.Join("..", "..", "scenarios", "test-values.yaml")); err != nil {
+		t.Fatalf("LoadFile: %v", err)
+	}
+	return e
+}
+
+func TestEngineMatch(t *testing.T) {
+	tests := []struct {
+		name         string
+		req          Request
+		wantRule     string // 맞는 규칙이 없으면 ""
+		wantScenario paymentv1.PaymentScenario
+		wantDecline  string
+		wantDelay    time.Duration // 규칙이 요청의 지연을 유지하면 0
+	}{
+		{
+			name:         "amount suffix",
+			req:          Request{Amount: 10013},
+			wantRule:     "amount-ending-13-fails",
+			wantScenario: paymentv1.PaymentScenario_PAYMENT_SCENARIO_FAIL,
+			wantDecline:  decline.InsufficientFunds,
+		},
+		{
+			name:         "amount suffix with delay",
+			req:          Request{Amount: 5091},
+			wantRule:     "amount-ending-91-issuer-down",
+			wantScenario: paymentv1.PaymentScenario_PAYMENT_SCENARIO_FAIL,
+			wantDecline:  decline.IssuerUnavailable,
+			wantDelay:    3 * time.Second,
+		},
+		{
+			name:         "reservation prefix",
+			req:          Request{Amount: 10000, ReservationID: "lt-slow-42"},
+			wantRule:     "slow-reservations",
+			wantScenario: paymentv1.PaymentScenario_PAYMENT_SCENARIO_DELAY,
+			wantDelay:    20 * time.Second,
+		},
+		{
+			name:         "user id",
+			req:          Request{Amount: 10000, UserID: "chaos-monkey"},
+			wantRule:     "chaos-user",
+			wantScenario: paymentv1.PaymentScenario_PAYMENT_SCENARIO_RANDOM,
+		},
+		{
+			name:         "card number ignores separators",
+			req:          Request{Amount: 10000, TestCard: "4242-4242-4242-4242"},
+			wantRule:     "success-card",
+			wantScenario: paymentv1.PaymentScenario_PAYMENT_SCENARIO_APPROVE,
+		},
+		{
+			name:         "built-in decline card",
+			req:          Request{Amount: 10000, TestCard: "4000 0000 0000 9995"},
+			wantRule:     "test-card-4000000000009995",
+			wantScenario: paymentv1.PaymentScenario_PAYMENT_SCENARIO_FAIL,
+			wantDecline:  decline.InsufficientFunds,
+		},
+		{
+			name:         "built-in decline token",
+			req:          Request{Amount: 10000, TestCard: "TOK_CARD_EXPIRED"},
+			wantRule:     "test-card-tok_card_expired",
+			wantScenario: paymentv1.PaymentScenario_PAYMENT_SCENARIO_FAIL,
+			wantDecline:  decline.CardExpired,
+		},
+		{
+			// 여러 규칙에 걸리면 파일에서 먼저 나온 규칙이 이긴다
+			name: "amount suffix before every other condition",
+			req: Request{
+				Amount:        10013,
+				UserID:        "chaos-monkey",
+				ReservationID: "lt-slow-1",
+				TestCard:      "4242424242424242",
+			},
+			wantRule:     "amount-ending-13-fails",
+			wantScenario: paymentv1.PaymentScenario_PAYMENT_SCENARIO_FAIL,
+			wantDecline:  decline.InsufficientFunds,
+		},
+		{
+			name:         "reservation prefix before user id and card",
+			req:          Request{Amount: 10000, UserID: "chaos-monkey", ReservationID: "lt-slow-1", TestCard: "4242424242424242"},
+			wantRule:     "slow-reservations",
+			wantScenario: paymentv1.PaymentScenario_PAYMENT_SCENARIO_DELAY,
+			wantDelay:    20 * time.Second,
+		},
+		{
+			name:         "user id before card",
+			req:          Request{Amount: 10000, UserID: "chaos-monkey", TestCard: "4242424242424242"},
+			wantRule:     "chaos-user",
+			wantScenario: paymentv1.PaymentScenario_PAYMENT_SCENARIO_RANDOM,
+		},
+		{
+			name:         "configured rule before built-in card",
+			req:          Request{Amount: 10013, TestCard: "tok_card_expired"},
+			wantRule:     "amount-ending-13-fails",
+			wantScenario: paymentv1.PaymentScenario_PAYMENT_SCENARIO_FAIL,
+			wantDecline:  decline.InsufficientFunds,
+		},
+		{
+			name: "no match",
+			req:  Request{Amount: 10000, UserID: "user-1", ReservationID: "rsv-1", TestCard: "4111111111111111"},
+		},
+	}
+
+	e := exampleEngine(t)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rule, ok := e.Match(tt.req)
+			if !ok {
+				if tt.wantRule != "" {
+					t.Fatalf("no rule matched, want %s", tt.wantRule)
+				}
+				return
+			}
+			if rule.Name != tt.wantRule {
+				t.Fatalf("matched %s, want %q", rule.Name, tt.wantRule)
+			}
+			if rule.ScenarioValue() != tt.wantScenario {
+				t.Errorf("scenario = %s, want %s", rule.ScenarioValue(), tt.wantScenario)
+			}
+			if rule.DeclineCode != tt.wantDecline {
+				t.Errorf("decline code = %q, want %q", rule.DeclineCode, tt.wantDecline)
+			}
+			var delay time.Duration
+			if rule.Delay != nil {
+				delay = time.Duration(*rule.Delay)
+			}
+			if delay != tt.wantDelay {
+				t.Errorf("delay = %s, want %s", delay, tt.wantDelay)
+			}
+		})
+	}
+}
+
+func TestParseRejectsMalformedRules(t *testing.T) {
+	tests := []struct {
+		name    string
+		doc     string
+		wantErr string
+	}{
+		{
+			name:    "not yaml",
+			doc:     "rules: [",
+			wantErr: "failed to parse test value rules",
+		},
+		{
+			name:    "missing name",
+			doc:     "rules: [{match: {amount: 100}, scenario: fail}]",
+			wantErr: "name is required",
+		},
+		{
+			name:    "no match condition",
+			doc:     "rules: [{name: r, scenario: fail}]",
+			wantErr: "at least one match condition",
+		},
+		{
+			name:    "amount suffix not a number",
+			doc:     "rules: [{name: r, match: {amount_suffix: 1x}, scenario: fail}]",
+			wantErr: "is not a number",
+		},
+		{
+			name:    "unknown decline code",
+			doc:     "rules: [{name: r, match: {amount: 100}, decline_code: NOPE}]",
+			wantErr: "unknown decline code",
+		},
+		{
+			name:    "decline code with approve",
+			doc:     "rules: [{name: r, match: {amount: 100}, scenario: approve, decline_code: INSUFFICIENT_FUNDS}]",
+			wantErr: "decline_code cannot be combined with scenario approve",
+		},
+		{
+			name:    "unknown scenario",
+			doc:     "rules: [{name: r, match: {amount: 100}, scenario: explode}]",
+			wantErr: "unknown payment scenario",
+		},
+		{
+			name:    "negative delay",
+			doc:     "rules: [{name: r, match: {amount: 100}, delay: -1s}]",
+			wantErr: "negative delay",
+		},
+		{
+			name:    "no outcome",
+			doc:     "rules: [{name: r, match: {amount: 100}}]",
+			wantErr: "scenario, decline_code or delay is required",
+		},
+		{
+			name: "duplicate name",
+			doc: `rules:
+  - {name: r, match: {amount: 100}, scenario: fail}
+  - {name: r, match: {amount: 200}, scenario: approve}`,
+			wantErr: "duplicate test value rule r",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Parse([]byte(tt.doc))
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Parse err = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestLoadFileKeepsRulesOnError(t *testing.T) {
+	e := exampleEngine(t)
+	path := filepath.Join(t.TempDir(), "broken.yaml")
+	if err := os.WriteFile(path, []byte("rules: [{name: r, match: {amount: 100}, decline_code: NOPE}]"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := e.LoadFile(path); err == nil {
+		t.Fatal("LoadFile accepted a malformed file")
+	}
+	if rule, ok := e.Match(Request{Amount: 10013}); !ok || rule.Name != "amount-ending-13-fails" {
+		t.Errorf("rules after failed load matched %q, want the previous rules kept", rule.Name)
+	}
+}
@@ -0,0 +1,138 @@
+// Package testvalues는 Stripe 테스트 카드처럼 요청의 특정 값(금액, user ID,
+// 예약 ID prefix, 테스트 카드)으로 시뮬레이션 결과를 고른다. gateway를 거치는
+// 등 scenario enum을 지정할 수 없는 호출자는 보내는 값으로 결과를 고른다.
+package testvalues
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	paymentv1 "github.com/traffic-tacos/proto-contracts/gen/go/payment/v1"
+	"github.com/traffic-tacos/payment-sim-api/internal/decline"
+	"github.com/traffic-tacos/payment-sim-api/internal/scenario"
+)
+
+// Rule은 Match의 모든 조건에 맞는 요청의 결과를 고른다.
+//
+//	name: amount-ending-13-fails
+//	match:
+//	  amount_suffix: "13"
+//	scenario: fail
+//	decline_code: INSUFFICIENT_FUNDS
+type Rule struct {
+	Name        string `json:"name" yaml:"name"`
+	Description string `json:"description,omitempty" yaml:"description,omitempty"`
+	Match       Match  `json:"match" yaml:"match"`
+
+	// Scenario는 요청의 시나리오를 덮어쓴다 ("fail" 또는 "PAYMENT_SCENARIO_FAIL").
+	// DeclineCode만 있으면 fail이다. approve는 실패하지 않으므로 DeclineCode와
+	// 함께 쓸 수 없다.
+	Scenario string `json:"scenario,omitempty" yaml:"scenario,omitempty"`
+	// DeclineCode는 결제가 실패할 때 보고한다 (internal/decline 참고).
+	DeclineCode string `json:"decline_code,omitempty" yaml:"decline_code,omitempty"`
+	// Delay는 처리 지연을 덮어쓴다.
+	Delay *scenario.Duration `json:"delay,omitempty" yaml:"delay,omitempty"`
+
+	resolved paymentv1.PaymentScenario
+}
+
+// Match는 규칙의 조건이다. 빈 조건은 무엇이든 맞지만 규칙에는 조건이 하나
+// 이상 있어야 한다.
+type Match struct {
+	// Amount는 최소 단위 금액이 정확히 같으면 맞는다.
+	Amount int64 `json:"amount,omitempty" yaml:"amount,omitempty"`
+	// AmountSuffix는 최소 단위 금액이 이 숫자로 끝나면 맞는다.
+	AmountSuffix string `json:"amount_suffix,omitempty" yaml:"amount_suffix,omitempty"`
+	UserID       string `json:"user_id,omitempty" yaml:"user_id,omitempty"`
+	// ReservationPrefix는 이 prefix로 시작하는 예약 ID에 맞는다.
+	ReservationPrefix string `json:"reservation_prefix,omitempty" yaml:"reservation_prefix,omitempty"`
+	// TestCard는 카드 번호(공백과 대시 무시)나 tok_insufficient_funds 같은
+	// 토큰에 맞는다.
+	TestCard string `json:"test_card,omitempty" yaml:"test_card,omitempty"`
+}
+
+// Request는 규칙과 맞춰 볼 요청 값이다.
+type Request struct {
+	Amount        int64
+	UserID        string
+	ReservationID string
+	TestCard      string
+}
+
+// Validate는 규칙을 검사하고 시나리오를 확정한다.
+func (r *Rule) Validate() error {
+	if r.Name == "" {
+		return fmt.Errorf("test value rule name is required")
+	}
+
+	m := r.Match
+	if m == (Match{}) {
+		return fmt.Errorf("test value rule %s: at least one match condition is required", r.Name)
+	}
+	if m.Amount < 0 {
+		return fmt.Errorf("test value rule %s: negative amount", r.Name)
+	}
+	if m.AmountSuffix != "" {
+		if _, err := strconv.ParseUint(m.AmountSuffix, 10, 64); err != nil {
+			return fmt.Errorf("test value rule %s: amount_suffix %q is not a number", r.Name, m.AmountSuffix)
+		}
+	}
+
+	if r.DeclineCode != "" {
+		if _, ok := decline.Lookup(r.DeclineCode); !ok {
+			return fmt.Errorf("test value rule %s: unknown decline code %q", r.Name, r.DeclineCode)
+		}
+		if r.Scenario == "" {
+			r.Scenario = "fail"
+		}
+	}
+	if r.Scenario != "" {
+		sc, err := scenario.ParseName(r.Scenario)
+		if err != nil {
+			return fmt.Errorf("test value rule %s: %w", r.Name, err)
+		}
+		r.resolved = sc
+	}
+	if r.DeclineCode != "" && r.resolved == paymentv1.PaymentScenario_PAYMENT_SCENARIO_APPROVE {
+		return fmt.Errorf("test value rule %s: decline_code cannot be combined with scenario approve", r.Name)
+	}
+	if r.Delay != nil && *r.Delay < 0 {
+		return fmt.Errorf("test value rule %s: negative delay", r.Name)
+	}
+	if r.resolved == paymentv1.PaymentScenario_PAYMENT_SCENARIO_UNSPECIFIED && r.Delay == nil {
+		return fmt.Errorf("test value rule %s: scenario, decline_code or delay is required", r.Name)
+	}
+	return nil
+}
+
+// ScenarioValue는 규칙이 고른 시나리오를 반환한다. UNSPECIFIED면 요청의
+// 시나리오를 그대로 쓴다.
+func (r Rule) ScenarioValue() paymentv1.PaymentScenario {
+	return r.resolved
+}
+
+func (r Rule) matches(req Request) bool {
+	m := r.Match
+	if m.Amount != 0 && req.Amount != m.Amount {
+		return false
+	}
+	if m.AmountSuffix != "" && !strings.HasSuffix(strconv.FormatInt(req.Amount, 10), m.AmountSuffix) {
+		return false
+	}
+	if m.UserID != "" && req.UserID != m.UserID {
+		return false
+	}
+	if m.ReservationPrefix != "" && !strings.HasPrefix(req.ReservationID, m.ReservationPrefix) {
+		return false
+	}
+	if m.TestCard != "" && (req.TestCard == "" || normalizeCard(req.TestCard) != normalizeCard(m.TestCard)) {
+		return false
+	}
+	return true
+}
+
+// normalizeCard는 카드 번호의 구분자를 지우고 토큰을 소문자로 바꾼다.
+func normalizeCard(card string) string {
+	return strings.ToLower(strings.NewReplacer(" ", "", "-", "").Replace(card))
+}
@@ -0,0 +1,157 @@
+package webhook
+
+import (
+	"math"
+	"math/rand/v2"
+	"sync"
+	"time"
+
+	"github.com/traffic-tacos/payment-sim-api/internal/config"
+)
+
+type DeliveryStatus string
+
+const (
+	DeliveryPending   DeliveryStatus = "PENDING"
+	DeliverySucceeded DeliveryStatus = "SUCCEEDED"
+	// DeliveryExhausted is terminal: every attempt of the retry schedule failed.
+	DeliveryExhausted DeliveryStatus = "EXHAUSTED"
+)
+
+// responseExcerptBytes caps how much of the receiver's response body is kept
+// per attempt.
+const responseExcerptBytes = 512
+
+// Attempt records a single HTTP POST of a webhook.
+type Attempt struct {
+	Number          int           `json:"number"`
+	StartedAt       time.Time     `json:"started_at"`
+	StatusCode      int           `json:"status_code,omitempty"`
+	Latency         time.Duration `json:"latency_ns"`
+	ResponseExcerpt string        `json:"response_excerpt,omitempty"`
+	Error           string        `json:"error,omitempty"`
+}
+
+func (a Attempt) succeeded() bool {
+	return a.Error == "" && a.StatusCode >= 200 && a.StatusCode < 300
+}
+
+// Delivery is one webhook notification and every attempt made to deliver it.
+type Delivery struct {
+	WebhookID string         `json:"webhook_id"`
+	PaymentID string         `json:"payment_id"`
+	URL       string         `json:"url"`
+	Payload   WebhookPayload `json:"payload"`
+	Status    DeliveryStatus `json:"status"`
+	Attempts  []Attempt      `json:"attempts"`
+	CreatedAt time.Time      `json:"created_at"`
+	UpdatedAt time.Time      `json:"updated_at"`
+}
+
+// RetryPolicy is an exponential backoff schedule with jitter:
+// delay(n) = min(Base * 2^(n-1), Max) ± Jitter.
+type RetryPolicy struct {
+	MaxAttempts int
+	Base        time.Duration
+	Max         time.Duration
+	Jitter      float64 // 0~1, fraction of the delay
+}
+
+func retryPolicyFromConfig(cfg *config.Config) RetryPolicy {
+	return RetryPolicy{
+		MaxAttempts: cfg.WebhookMaxAttempts,
+		Base:        time.Duration(cfg.WebhookRetryBaseMs) * time.Millisecond,
+		Max:         time.Duration(cfg.WebhookRetryMaxMs) * time.Millisecond,
+		Jitter:      cfg.WebhookRetryJitter,
+	}
+}
+
+// Backoff returns the wait after the given (1-based) failed attempt.
+func (p RetryPolicy) Backoff(attempt int) time.Duration {
+	delay := float64(p.Base) * math.Pow(2, float64(attempt-1))
+	if p.Max > 0 && delay > float64(p.Max) {
+		delay = float64(p.Max)
+	}
+	if p.Jitter > 0 {
+		delay += delay * p.Jitter * (2*rand.Float64() - 1)
+	}
+	if delay < 0 {
+		delay = 0
+	}
+	return time.Duration(delay)
+}
+
+// DeliveryLog keeps the deliveries of the most recent payments in memory.
+type DeliveryLog struct {
+	mu          sync.RWMutex
+	maxPayments int
+	byPayment   map[string][]*Delivery
+	order       []string // 오래된 payment부터 제거하기 위한 순서
+}
+
+func NewDeliveryLog(maxPayments int) *DeliveryLog {
+	return &DeliveryLog{
+		maxPayments: maxPayments,
+		byPayment:   make(map[string][]*Delivery),
+	}
+}
+
+func (l *DeliveryLog) start(webhookID string, payload WebhookPayload, url string) *Delivery {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	delivery := &Delivery{
+		WebhookID: webhookID,
+		PaymentID: payload.PaymentID,
+		URL:       url,
+		Payload:   payload,
+		Status:    DeliveryPending,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+
+	if _, exists := l.byPayment[payload.PaymentID]; !exists {
+		l.order = append(l.order, payload.PaymentID)
+		if l.maxPayments > 0 && len(l.order) > l.maxPayments {
+			delete(l.byPayment, l.order[0])
+			l.order = l.order[1:]
+		}
+	}
+	l.byPayment[payload.PaymentID] = append(l.byPayment[payload.PaymentID], delivery)
+	return delivery
+}
+
+func (l *DeliveryLog) record(delivery *Delivery, attempt Attempt) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	delivery.Attempts = append(delivery.Attempts, attempt)
+	delivery.UpdatedAt = time.Now()
+}
+
+func (l *DeliveryLog) finish(delivery *Delivery, status DeliveryStatus) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	delivery.Status = status
+	delivery.UpdatedAt = time.Now()
+}
+
+func (l *DeliveryLog) attempts(delivery *Delivery) []Attempt {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return append([]Attempt(nil), delivery.Attempts...)
+}
+
+// Deliveries returns copies of every delivery recorded for a payment intent.
+func (l *DeliveryLog) Deliveries(paymentID string) []Delivery {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	deliveries := make([]Delivery, 0, len(l.byPayment[paymentID]))
+	for _, delivery := range l.byPayment[paymentID] {
+		c := *delivery
+		c.Attempts = append([]Attempt(nil), delivery.Attempts...)
+		deliveries = append(deliveries, c)
+	}
+	return deliveries
+}
@@ -0,0 +1,164 @@
+package webhook
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRetryPolicyBackoff(t *testing.T) {
+	policy := RetryPolicy{MaxAttempts: 6, Base: time.Second, Max: 10 * time.Second}
+	want := []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 8 * time.Second, 10 * time.Second, 10 * time.Second}
+	for i, w := range want {
+		if got := policy.Backoff(i + 1); got != w {
+			t.Errorf("Backoff(%d) = %s, want %s", i+1, got, w)
+		}
+	}
+}
+
+func TestRetryPolicyJitterBounds(t *testing.T) {
+	policy := RetryPolicy{Base: time.Second, Max: 30 * time.Second, Jitter: 0.2}
+	for attempt := 1; attempt <= 4; attempt++ {
+		base := float64(policy.Base) * float64(int(1)<<(attempt-1))
+		low, high := time.Duration(base*0.8), time.Duration(base*1.2)
+
+		var spread bool
+		first := policy.Backoff(attempt)
+		for range 200 {
+			got := policy.Backoff(attempt)
+			if got < low || got > high {
+				t.Fatalf("Backoff(%d) = %s, want within [%s, %s]", attempt, got, low, high)
+			}
+			spread = spread || got != first
+		}
+		if !spread {
+			t.Errorf("Backoff(%d) returned %s every time, want jitter", attempt, first)
+		}
+	}
+}
+
+// waitDelivery waits until the payment's first delivery is no longer pending.
+func waitDelivery(t *testing.T, d *Dispatcher, paymentID string) Delivery {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		if deliveries := d.Deliveries(paymentID); len(deliveries) > 0 && deliveries[0].Status != DeliveryPending {
+			return deliveries[0]
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("delivery of %s still pending: %+v", paymentID, d.Deliveries(paymentID))
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestDeliveryRetriesUntilSuccessOrExhausted(t *testing.T) {
+	tests := []struct {
+		name         string
+		fail         int
+		wantStatus   DeliveryStatus
+		wantAttempts []int // status code of each attempt
+	}{
+		{
+			name:         "succeeds first time",
+			wantStatus:   DeliverySucceeded,
+			wantAttempts: []int{200},
+		},
+		{
+			name:         "succeeds after failures",
+			fail:         2,
+			wantStatus:   DeliverySucceeded,
+			wantAttempts: []int{503, 503, 200},
+		},
+		{
+			name:         "exhausted",
+			fail:         5,
+			wantStatus:   DeliveryExhausted,
+			wantAttempts: []int{503, 503, 503},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := testConfig(t)
+			cfg.WebhookMaxAttempts = 3
+			receiver, url := newWebhookReceiver(t, tt.fail)
+
+			d := newTestDispatcher(t, cfg, nil)
+			d.Start()
+			defer d.Shutdown(t.Context())
+
+			d.deliver("wh-1", "", WebhookPayload{PaymentID: "pay-1", Status: "PAYMENT_STATUS_COMPLETED"}, url)
+			delivery := waitDelivery(t, d, "pay-1")
+
+			if delivery.Status != tt.wantStatus {
+				t.Errorf("status = %s, want %s", delivery.Status, tt.wantStatus)
+			}
+			if len(delivery.Attempts) != len(tt.wantAttempts) {
+				t.Fatalf("attempts = %+v, want %d", delivery.Attempts, len(tt.wantAttempts))
+			}
+			for i, attempt := range delivery.Attempts {
+				if attempt.Number != i+1 || attempt.StatusCode != tt.wantAttempts[i] {
+					t.Errorf("attempt %d = #%d status %d, want #%d status %d",
+						i, attempt.Number, attempt.StatusCode, i+1, tt.wantAttempts[i])
+				}
+				if attempt.Latency <= 0 || attempt.StartedAt.IsZero() {
+					t.Errorf("attempt %d has no latency or start time: %+v", i, attempt)
+				}
+				failed := attempt.StatusCode != 200
+				if failed != (attempt.Error != "") {
+					t.Errorf("attempt %d error = %q with status %d", i, attempt.Error, attempt.StatusCode)
+				}
+				if failed && attempt.ResponseExcerpt != "receiver down" {
+					t.Errorf("attempt %d response excerpt = %q, want the receiver's body", i, attempt.ResponseExcerpt)
+				}
+			}
+			if got := len(receiver.received()); got != len(tt.wantAttempts) {
+				t.Errorf("receiver got %d requests, want %d", got, len(tt.wantAttempts))
+			}
+		})
+	}
+}
+
+func TestDeliveryTruncatesResponseExcerpt(t *testing.T) {
+	body := strings.Repeat("x", 2*responseExcerptBytes)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(body))
+	}))
+	t.Cleanup(srv.Close)
+
+	cfg := testConfig(t)
+	cfg.WebhookMaxAttempts = 1
+	d := newTestDispatcher(t, cfg, nil)
+	d.Start()
+	defer d.Shutdown(t.Context())
+
+	d.deliver("wh-1", "", WebhookPayload{PaymentID: "pay-1"}, srv.URL)
+	delivery := waitDelivery(t, d, "pay-1")
+
+	if delivery.Status != DeliveryExhausted || len(delivery.Attempts) != 1 {
+		t.Fatalf("delivery = %s with %d attempts, want EXHAUSTED after 1", delivery.Status, len(delivery.Attempts))
+	}
+	if got := delivery.Attempts[0].ResponseExcerpt; got != body[:responseExcerptBytes] {
+		t.Errorf("response excerpt has %d bytes, want the first %d", len(got), responseExcerptBytes)
+	}
+}
+
+func TestDeliveryLogKeepsRecentPayments(t *testing.T) {
+	log := NewDeliveryLog(2)
+	for _, id := range []string{"pay-1", "pay-2", "pay-1", "pay-3"} {
+		log.start("wh-"+id, WebhookPayload{PaymentID: id}, "http://merchant.test")
+	}
+
+	if got := log.Deliveries("pay-1"); len(got) != 0 {
+		t.Errorf("oldest payment still has %d deliveries", len(got))
+	}
+	for _, id := range []string{"pay-2", "pay-3"} {
+		if got := log.Deliveries(id); len(got) != 1 {
+			t.Errorf("%s has %d deliveries, want 1", id, len(got))
+		}
+	}
+}
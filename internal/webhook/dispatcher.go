@@ -3,139 +3,537 @@ package webhook
 import (
 	"bytes"
 	"context"
-	"crypto/hmac"
-	"crypto/sha256"
-	"encoding/hex"
 	"encoding/json"
 	"fmt"
+	"io"
 	"net/http"
+	"slices"
+	"strconv"
+	"sync"
+	"sync/atomic"
 	"time"
 
+	"github.com/google/uuid"
 	"go.uber.org/zap"
 
 	"github.com/traffic-tacos/payment-sim-api/internal/config"
+	"github.com/traffic-tacos/payment-sim-api/internal/decline"
 	"github.com/traffic-tacos/payment-sim-api/internal/events"
+	"github.com/traffic-tacos/payment-sim-api/internal/scenario"
+	"github.com/traffic-tacos/payment-sim-api/pkg/paymentevent"
+	"github.com/traffic-tacos/payment-sim-api/pkg/webhooksig"
 )
 
 type WebhookPayload struct {
 	PaymentID     string `json:"payment_id"`
 	ReservationID string `json:"reservation_id"`
 	Status        string `json:"status"`
+	FailureCode   string `json:"failure_code,omitempty"`
+	// FailureReason is the decline message of FailureCode (see internal/decline).
+	FailureReason string `json:"failure_reason,omitempty"`
 	Amount        int64  `json:"amount"`
 	Currency      string `json:"currency"`
 	Timestamp     int64  `json:"timestamp"`
 	EventType     string `json:"event_type"`
+	// 환불 webhook에서만 사용 (Amount는 이번 환불 금액)
+	RefundID       string `json:"refund_id,omitempty"`
+	RefundedAmount int64  `json:"refunded_amount,omitempty"`
 }
 
+// StatusUpdater applies an asynchronously decided payment status to the stored
+// payment intent. PaymentService implements it via its state machine.
+type StatusUpdater interface {
+	// code is the step's PG result code (the decline code of a FAILED step), empty if none.
+	UpdatePaymentStatus(paymentID, status, code string) error
+	// UpdateRefundStatus settles a pending refund of the payment. code is the
+	// failure code of a FAILED refund.
+	UpdateRefundStatus(paymentID, refundID, status, code string) (RefundOutcome, error)
+}
+
+// RefundOutcome is the state of a payment right after one of its refunds settled.
+type RefundOutcome struct {
+	Succeeded      bool
+	PaymentStatus  string
+	RefundedAmount int64 // 성공한 환불 누적 금액
+}
+
+// Job is the scripted outcome of a single payment intent. Steps run in order,
+// each one After the previous step finished. A job with RefundID settles that
+// refund instead of changing the payment status; Amount is then the refund amount.
+type Job struct {
+	PaymentID     string          `json:"payment_id"`
+	RefundID      string          `json:"refund_id,omitempty"`
+	ReservationID string          `json:"reservation_id"`
+	MerchantID    string          `json:"merchant_id,omitempty"` // 서명 secret 선택용
+	WebhookURL    string          `json:"webhook_url,omitempty"`
+	Amount        int64           `json:"amount"`
+	Currency      string          `json:"currency"`
+	Steps         []scenario.Step `json:"steps"`
+	// EventType overrides the event and webhook type of status steps
+	// (default payment.status_updated).
+	EventType paymentevent.EventType `json:"event_type,omitempty"`
+	// Kind lets CancelScheduled remove one kind of job of a payment.
+	Kind JobKind `json:"kind,omitempty"`
+}
+
+// JobKind tells the jobs of one payment apart.
+type JobKind string
+
+// Job kinds
+const (
+	// JobScenario (기본값) runs the scenario steps and their notifications.
+	JobScenario JobKind = ""
+	// JobAuthorizationExpiry expires an authorization that was neither
+	// captured nor voided in time.
+	JobAuthorizationExpiry JobKind = "authorization_expiry"
+)
+
 type Dispatcher struct {
-	logger     *zap.Logger
-	config     *config.Config
-	httpClient *http.Client
-	publisher  *events.Publisher
+	logger        *zap.Logger
+	config        *config.Config
+	httpClient    *http.Client
+	publisher     *events.Publisher
+	statusUpdater StatusUpdater
+	retry         RetryPolicy
+	deliveries    *DeliveryLog
+	dlq           *DeadLetterQueue
+	secrets       *Secrets
+
+	// 지연 큐 + 고정 크기 worker pool (payment당 goroutine을 띄우지 않음)
+	queue   *delayQueue
+	workers int
+	stop    chan struct{}
+	wg      sync.WaitGroup
+	busy    atomic.Int32
+	closed  atomic.Bool
 }
 
-func NewDispatcher(logger *zap.Logger, config *config.Config, publisher *events.Publisher) *Dispatcher {
+func NewDispatcher(logger *zap.Logger, config *config.Config, publisher *events.Publisher, dlq *DeadLetterQueue, secrets *Secrets) *Dispatcher {
+	workers := config.WebhookWorkers
+	if workers <= 0 {
+		workers = 1
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.MaxIdleConns = workers
+	transport.MaxIdleConnsPerHost = workers
+
 	return &Dispatcher{
 		logger:    logger,
 		config:    config,
 		publisher: publisher,
 		httpClient: &http.Client{
-			Timeout: 30 * time.Second,
+			Timeout:   time.Duration(config.WebhookTimeoutMs) * time.Millisecond,
+			Transport: transport,
 		},
+		retry:      retryPolicyFromConfig(config),
+		deliveries: NewDeliveryLog(config.WebhookDeliveryLogSize),
+		dlq:        dlq,
+		secrets:    secrets,
+		queue:      newDelayQueue(config.WebhookQueueDepth, workers),
+		workers:    workers,
+		stop:       make(chan struct{}),
 	}
 }
 
-func (d *Dispatcher) SendPaymentWebhookAsync(paymentID, reservationID, finalStatus, webhookURL string, amount int64, currency string, delaySeconds int) {
-	// 비동기 실행 (실제 PG사처럼 지연 후 webhook 발송)
+func (d *Dispatcher) SetStatusUpdater(updater StatusUpdater) {
+	d.statusUpdater = updater
+}
+
+// Start resumes tasks persisted by a previous Shutdown and launches the delay
+// queue scheduler and the worker pool.
+func (d *Dispatcher) Start() {
+	d.resume()
+
+	d.wg.Add(1 + d.workers)
 	go func() {
-		// 가라 지연 (실제 PG 처리 시뮬레이션)
-		time.Sleep(time.Duration(delaySeconds) * time.Second)
-
-		payload := WebhookPayload{
-			PaymentID:     paymentID,
-			ReservationID: reservationID,
-			Status:        finalStatus,
-			Amount:        amount,
-			Currency:      currency,
-			Timestamp:     time.Now().Unix(),
-			EventType:     "payment.status_updated",
+		defer d.wg.Done()
+		d.queue.run(d.stop)
+	}()
+	for i := 0; i < d.workers; i++ {
+		go func() {
+			defer d.wg.Done()
+			d.work()
+		}()
+	}
+
+	d.logger.Info("Webhook dispatcher started",
+		zap.Int("workers", d.workers),
+		zap.Int("queue_depth", d.config.WebhookQueueDepth))
+}
+
+func (d *Dispatcher) work() {
+	for {
+		select {
+		case item := <-d.queue.ready:
+			d.busy.Add(1)
+			dispatchWorkersBusy.Inc()
+			item.task.run(d)
+			dispatchWorkersBusy.Dec()
+			d.busy.Add(-1)
+			dispatchTasksTotal.WithLabelValues(item.task.kind()).Inc()
+		case <-d.stop:
+			return
 		}
+	}
+}
 
-		// EventBridge로 실제 이벤트 발송 (SQS로 라우팅됨)
-		if d.publisher != nil {
-			event := events.PaymentEvent{
-				PaymentID:     paymentID,
-				ReservationID: reservationID,
-				Status:        finalStatus,
-				Amount:        amount,
-				Currency:      currency,
-			}
-
-			ctx := context.Background()
-			if err := d.publisher.PublishPaymentEvent(ctx, event); err != nil {
-				d.logger.Error("Failed to publish payment event to EventBridge",
-					zap.String("payment_id", paymentID),
-					zap.Error(err))
-			}
+// SchedulePayment queues the first step of a job. It returns ErrQueueFull when
+// the dispatch queue is at capacity and ErrDispatcherClosed once Shutdown has
+// begun; the caller should fail the payment.
+func (d *Dispatcher) SchedulePayment(job Job) error {
+	if d.closed.Load() {
+		return ErrDispatcherClosed
+	}
+	if len(job.Steps) == 0 {
+		return nil
+	}
+
+	// 비동기 실행 (실제 PG사처럼 지연 후 webhook 발송)
+	t := &stepTask{job: &jobState{Job: job}}
+	if err := d.queue.push(t, time.Now().Add(time.Duration(job.Steps[0].After)), true); err != nil {
+		dispatchRejectedTotal.Inc()
+		return err
+	}
+	return nil
+}
+
+// CancelScheduled removes the queued scenario steps of a payment so its
+// scripted outcome and webhooks never happen. With kinds only the jobs of
+// those kinds are removed. Refund jobs and webhook deliveries already queued
+// are kept. It returns the number of removed steps.
+func (d *Dispatcher) CancelScheduled(paymentID string, kinds ...JobKind) int {
+	removed := d.queue.remove(func(t task) bool {
+		step, ok := t.(*stepTask)
+		if !ok || step.job.PaymentID != paymentID || step.job.RefundID != "" {
+			return false
 		}
+		return len(kinds) == 0 || slices.Contains(kinds, step.job.Kind)
+	})
+	if removed > 0 {
+		d.logger.Info("Scheduled payment steps cancelled",
+			zap.String("payment_id", paymentID),
+			zap.Int("steps", removed))
+	}
+	return removed
+}
+
+// jobState carries a job across its steps. Steps of one job never run
+// concurrently, so it needs no locking.
+type jobState struct {
+	Job
+	last   *WebhookPayload
+	lastID string
+}
+
+// stepTask runs Steps[index] of a job and schedules the next step.
+type stepTask struct {
+	job   *jobState
+	index int
+}
+
+func (t *stepTask) kind() string { return "step" }
 
-		// HTTP Webhook도 여전히 발송 (기존 시스템 호환성)
-		err := d.sendWebhook(payload, webhookURL)
-		if err != nil {
-			d.logger.Error("Failed to send webhook",
-				zap.String("payment_id", paymentID),
-				zap.String("webhook_url", webhookURL),
+func (t *stepTask) run(d *Dispatcher) {
+	if !d.runStep(t.job, t.job.Steps[t.index]) {
+		return
+	}
+
+	next := t.index + 1
+	if next >= len(t.job.Steps) {
+		return
+	}
+	// 가라 지연 (실제 PG 처리 시뮬레이션): sleep 대신 다음 step을 큐에 예약
+	d.queue.push(&stepTask{job: t.job, index: next},
+		time.Now().Add(time.Duration(t.job.Steps[next].After)), false)
+}
+
+// runStep executes one scenario step and reports whether the job continues.
+func (d *Dispatcher) runStep(job *jobState, step scenario.Step) bool {
+	if step.Action == scenario.ActionDuplicateWebhook {
+		if job.last == nil {
+			d.logger.Warn("No webhook to duplicate yet", zap.String("payment_id", job.PaymentID))
+			return true
+		}
+		d.deliver(job.lastID, job.MerchantID, *job.last, job.WebhookURL)
+		return true
+	}
+
+	if job.RefundID != "" {
+		return d.runRefundStep(job, step)
+	}
+
+	// 저장된 intent에 상태 반영 (이미 ProcessPayment 등으로 확정된 경우 중단).
+	// notify step의 상태는 서비스가 이미 반영했으므로 그 사이 매입 등으로 바뀌었어도 알린다.
+	if step.Action != scenario.ActionNotify {
+		if err := d.updateStatus(job.PaymentID, step.Status, step.Code); err != nil {
+			d.logger.Warn("Stopping scheduled payment, status transition rejected",
+				zap.String("payment_id", job.PaymentID),
+				zap.String("status", step.Status),
 				zap.Error(err))
-		} else {
-			d.logger.Info("Webhook sent successfully",
-				zap.String("payment_id", paymentID),
-				zap.String("status", finalStatus),
-				zap.String("webhook_url", webhookURL))
+			return false
 		}
-	}()
+	}
+
+	if !step.Notify() {
+		return true
+	}
+
+	eventType := job.EventType
+	if eventType == "" {
+		eventType = paymentevent.EventTypeStatusUpdated
+	}
+
+	payload := WebhookPayload{
+		PaymentID:     job.PaymentID,
+		ReservationID: job.ReservationID,
+		Status:        step.Status,
+		FailureCode:   step.Code,
+		FailureReason: decline.Message(step.Code),
+		Amount:        job.Amount,
+		Currency:      job.Currency,
+		Timestamp:     time.Now().Unix(),
+		EventType:     string(eventType),
+	}
+	job.last, job.lastID = &payload, uuid.New().String()
+
+	// EventBridge로 실제 이벤트 발송 (SQS로 라우팅됨)
+	d.publish(paymentevent.Event{
+		PaymentID:     job.PaymentID,
+		ReservationID: job.ReservationID,
+		Status:        paymentevent.Status(step.Status),
+		Amount:        job.Amount,
+		Currency:      job.Currency,
+		EventType:     eventType,
+		FailureCode:   step.Code,
+		FailureReason: decline.Message(step.Code),
+	})
+
+	// HTTP Webhook도 여전히 발송 (기존 시스템 호환성)
+	d.deliver(job.lastID, job.MerchantID, payload, job.WebhookURL)
+	return true
+}
+
+// runRefundStep settles the job's refund and notifies the merchant with a
+// payment.refunded or payment.refund_failed event and webhook.
+func (d *Dispatcher) runRefundStep(job *jobState, step scenario.Step) bool {
+	if d.statusUpdater == nil {
+		return false
+	}
+	outcome, err := d.statusUpdater.UpdateRefundStatus(job.PaymentID, job.RefundID, step.Status, step.Code)
+	if err != nil {
+		d.logger.Warn("Stopping scheduled refund, refund update rejected",
+			zap.String("payment_id", job.PaymentID),
+			zap.String("refund_id", job.RefundID),
+			zap.String("status", step.Status),
+			zap.Error(err))
+		return false
+	}
+
+	if !step.Notify() {
+		return true
+	}
+
+	eventType := paymentevent.EventTypeRefunded
+	if !outcome.Succeeded {
+		eventType = paymentevent.EventTypeRefundFailed
+	}
+
+	payload := WebhookPayload{
+		PaymentID:      job.PaymentID,
+		ReservationID:  job.ReservationID,
+		Status:         outcome.PaymentStatus,
+		FailureCode:    step.Code,
+		FailureReason:  decline.Message(step.Code),
+		Amount:         job.Amount,
+		Currency:       job.Currency,
+		Timestamp:      time.Now().Unix(),
+		EventType:      string(eventType),
+		RefundID:       job.RefundID,
+		RefundedAmount: outcome.RefundedAmount,
+	}
+	job.last, job.lastID = &payload, uuid.New().String()
+
+	d.publish(paymentevent.Event{
+		PaymentID:      job.PaymentID,
+		ReservationID:  job.ReservationID,
+		Status:         paymentevent.Status(outcome.PaymentStatus),
+		Amount:         job.Amount,
+		Currency:       job.Currency,
+		EventType:      eventType,
+		RefundID:       job.RefundID,
+		RefundedAmount: outcome.RefundedAmount,
+		FailureCode:    step.Code,
+		FailureReason:  decline.Message(step.Code),
+	})
+
+	d.deliver(job.lastID, job.MerchantID, payload, job.WebhookURL)
+	return true
+}
+
+// publish sends the payment event to EventBridge and dead-letters it on failure.
+func (d *Dispatcher) publish(event paymentevent.Event) {
+	if d.publisher == nil {
+		return
+	}
+
+	ctx := context.Background()
+	if err := d.publisher.PublishPaymentEvent(ctx, event); err != nil {
+		d.logger.Error("Failed to publish payment event to EventBridge",
+			zap.String("payment_id", event.PaymentID),
+			zap.Error(err))
+		d.deadLetter(FailureRecord{
+			Kind:      FailureEventBridge,
+			PaymentID: event.PaymentID,
+			Event:     &event,
+			LastError: err.Error(),
+		})
+	}
 }
 
-func (d *Dispatcher) sendWebhook(payload WebhookPayload, webhookURL string) error {
+// deliver queues the first attempt of a webhook delivery. Failed attempts are
+// re-queued with exponential backoff until the retry schedule is exhausted.
+func (d *Dispatcher) deliver(webhookID, merchantID string, payload WebhookPayload, webhookURL string) {
+	if webhookURL == "" {
+		return
+	}
+
+	d.queue.push(&deliveryTask{
+		webhookID:  webhookID,
+		merchantID: merchantID,
+		payload:    payload,
+		url:        webhookURL,
+		delivery:   d.deliveries.start(webhookID, payload, webhookURL),
+		number:     1,
+	}, time.Now(), false)
+}
+
+// deliveryTask is a single attempt of a webhook delivery.
+type deliveryTask struct {
+	webhookID  string
+	merchantID string
+	payload    WebhookPayload
+	url        string
+	delivery   *Delivery
+	number     int
+}
+
+func (t *deliveryTask) kind() string { return "delivery" }
+
+func (t *deliveryTask) run(d *Dispatcher) {
+	attempt := d.sendWebhook(t.webhookID, t.merchantID, t.payload, t.url, t.number)
+	d.deliveries.record(t.delivery, attempt)
+
+	if attempt.succeeded() {
+		deliveryTotal.WithLabelValues("success").Inc()
+		d.deliveries.finish(t.delivery, DeliverySucceeded)
+		d.logger.Info("Webhook sent successfully",
+			zap.String("payment_id", t.payload.PaymentID),
+			zap.String("status", t.payload.Status),
+			zap.String("webhook_url", t.url),
+			zap.Int("attempt", t.number))
+		return
+	}
+
+	if t.number >= d.retry.MaxAttempts {
+		deliveryTotal.WithLabelValues("exhausted").Inc()
+		d.deliveries.finish(t.delivery, DeliveryExhausted)
+		d.logger.Error("Webhook delivery exhausted",
+			zap.String("payment_id", t.payload.PaymentID),
+			zap.String("webhook_url", t.url),
+			zap.Int("attempts", t.number),
+			zap.String("last_error", attempt.Error))
+		payload := t.payload
+		d.deadLetter(FailureRecord{
+			Kind:       FailureWebhook,
+			WebhookID:  t.webhookID,
+			MerchantID: t.merchantID,
+			PaymentID:  payload.PaymentID,
+			URL:        t.url,
+			Payload:    &payload,
+			Attempts:   d.deliveries.attempts(t.delivery),
+			LastError:  attempt.Error,
+		})
+		return
+	}
+
+	deliveryTotal.WithLabelValues("failure").Inc()
+	backoff := d.retry.Backoff(t.number)
+	d.logger.Warn("Webhook attempt failed, retrying",
+		zap.String("payment_id", t.payload.PaymentID),
+		zap.String("webhook_url", t.url),
+		zap.Int("attempt", t.number),
+		zap.Duration("backoff", backoff),
+		zap.String("error", attempt.Error))
+
+	retry := *t
+	retry.number++
+	d.queue.push(&retry, time.Now().Add(backoff), false)
+}
+
+// Deliveries returns the delivery log of a payment intent.
+func (d *Dispatcher) Deliveries(paymentID string) []Delivery {
+	return d.deliveries.Deliveries(paymentID)
+}
+
+func (d *Dispatcher) updateStatus(paymentID, status, code string) error {
+	if d.statusUpdater == nil {
+		return nil
+	}
+	return d.statusUpdater.UpdatePaymentStatus(paymentID, status, code)
+}
+
+// sendWebhook makes a single delivery attempt and reports its outcome.
+func (d *Dispatcher) sendWebhook(webhookID, merchantID string, payload WebhookPayload, webhookURL string, number int) Attempt {
+	attempt := Attempt{Number: number, StartedAt: time.Now()}
+
 	jsonData, err := json.Marshal(payload)
 	if err != nil {
-		return fmt.Errorf("failed to marshal webhook payload: %w", err)
+		attempt.Error = fmt.Sprintf("failed to marshal webhook payload: %v", err)
+		return attempt
 	}
 
 	req, err := http.NewRequest("POST", webhookURL, bytes.NewBuffer(jsonData))
 	if err != nil {
-		return fmt.Errorf("failed to create webhook request: %w", err)
+		attempt.Error = fmt.Sprintf("failed to create webhook request: %v", err)
+		return attempt
 	}
 
 	// HTTP 헤더 설정 (실제 PG사 방식)
 	req.Header.Set("Content-Type", "application/json")
 	req.Header.Set("User-Agent", "PaymentSim/1.0")
+	req.Header.Set("X-Webhook-Id", webhookID) // 재시도/중복 발송 시에도 동일
+	req.Header.Set("X-Webhook-Attempt", strconv.Itoa(number))
 
-	// HMAC 서명 추가 (보안)
-	if d.config.WebhookSecret != "" {
-		signature := d.generateSignature(jsonData, d.config.WebhookSecret)
-		req.Header.Set("X-Webhook-Signature", signature)
+	// HMAC 서명 추가 (t=timestamp,v1=... / rotation 중엔 활성 secret마다 v1)
+	if d.secrets != nil {
+		if secrets := d.secrets.For(merchantID, webhookURL); len(secrets) > 0 {
+			req.Header.Set(webhooksig.Header, webhooksig.Sign(jsonData, time.Now(), secrets...))
+		}
 	}
 
 	d.logger.Info("Sending webhook",
 		zap.String("payment_id", payload.PaymentID),
 		zap.String("webhook_url", webhookURL),
-		zap.String("status", payload.Status))
+		zap.String("status", payload.Status),
+		zap.Int("attempt", number))
 
 	resp, err := d.httpClient.Do(req)
+	attempt.Latency = time.Since(attempt.StartedAt)
+	deliveryLatency.Observe(attempt.Latency.Seconds())
 	if err != nil {
-		return fmt.Errorf("failed to send webhook: %w", err)
+		attempt.Error = fmt.Sprintf("failed to send webhook: %v", err)
+		return attempt
 	}
 	defer resp.Body.Close()
 
+	attempt.StatusCode = resp.StatusCode
+	excerpt, _ := io.ReadAll(io.LimitReader(resp.Body, responseExcerptBytes))
+	attempt.ResponseExcerpt = string(excerpt)
+
 	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
-		return fmt.Errorf("webhook failed with status: %d", resp.StatusCode)
+		attempt.Error = fmt.Sprintf("webhook failed with status: %d", resp.StatusCode)
 	}
 
-	return nil
+	return attempt
 }
-
-func (d *Dispatcher) generateSignature(payload []byte, secret string) string {
-	h := hmac.New(sha256.New, []byte(secret))
-	h.Write(payload)
-	return "sha256=" + hex.EncodeToString(h.Sum(nil))
-}
\ No newline at end of file
@@ -0,0 +1,185 @@
+package webhook
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/aws/aws-sdk-go-v2/service/eventbridge"
+	"github.com/kelseyhightower/envconfig"
+	"go.uber.org/zap"
+
+	"github.com/traffic-tacos/payment-sim-api/internal/config"
+	"github.com/traffic-tacos/payment-sim-api/internal/events"
+	"github.com/traffic-tacos/payment-sim-api/internal/scenario"
+	"github.com/traffic-tacos/payment-sim-api/pkg/paymentevent"
+)
+
+// testConfig returns the config defaults with a small queue and fast retries.
+func testConfig(t *testing.T) *config.Config {
+	t.Helper()
+	var cfg config.Config
+	if err := envconfig.Process("", &cfg); err != nil {
+		t.Fatalf("envconfig: %v", err)
+	}
+	cfg.WebhookWorkers = 1
+	cfg.WebhookQueueDepth = 100
+	cfg.WebhookTimeoutMs = 5000
+	cfg.WebhookRetryBaseMs = 1
+	cfg.WebhookRetryMaxMs = 1
+	cfg.WebhookRetryJitter = 0
+	return &cfg
+}
+
+// fakeEventBridge is a PutEvents endpoint that records the published events.
+type fakeEventBridge struct {
+	mu     sync.Mutex
+	events []paymentevent.Event
+}
+
+func (f *fakeEventBridge) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		Entries []struct {
+			Detail string
+		}
+	}
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	f.mu.Lock()
+	for _, entry := range input.Entries {
+		var event paymentevent.Event
+		json.Unmarshal([]byte(entry.Detail), &event)
+		f.events = append(f.events, event)
+	}
+	f.mu.Unlock()
+
+	w.Header().Set("Content-Type", "application/x-amz-json-1.1")
+	w.Write([]byte(`{"FailedEntryCount":0,"Entries":[{"EventId":"evt-1"}]}`))
+}
+
+func (f *fakeEventBridge) published() []paymentevent.Event {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]paymentevent.Event(nil), f.events...)
+}
+
+// newTestPublisher returns a publisher whose events go to a fakeEventBridge.
+func newTestPublisher(t *testing.T, cfg *config.Config) (*events.Publisher, *fakeEventBridge) {
+	t.Helper()
+	fake := &fakeEventBridge{}
+	srv := httptest.NewServer(fake)
+	t.Cleanup(srv.Close)
+
+	client := eventbridge.New(eventbridge.Options{
+		Region:           "ap-northeast-2",
+		BaseEndpoint:     aws.String(srv.URL),
+		Credentials:      credentials.NewStaticCredentialsProvider("test", "test", ""),
+		RetryMaxAttempts: 1,
+	})
+	return events.NewPublisher(client, cfg, zap.NewNop()), fake
+}
+
+func newTestDispatcher(t *testing.T, cfg *config.Config, publisher *events.Publisher) *Dispatcher {
+	t.Helper()
+	return NewDispatcher(zap.NewNop(), cfg, publisher, nil, NewSecrets("test-secret"))
+}
+
+// webhookReceiver is a merchant endpoint that answers the first fail requests
+// with 503 and the rest with 200.
+type webhookReceiver struct {
+	mu       sync.Mutex
+	fail     int
+	payloads []WebhookPayload
+}
+
+func (r *webhookReceiver) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	var payload WebhookPayload
+	json.NewDecoder(req.Body).Decode(&payload)
+
+	r.mu.Lock()
+	r.payloads = append(r.payloads, payload)
+	failing := len(r.payloads) <= r.fail
+	r.mu.Unlock()
+
+	if failing {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		w.Write([]byte("receiver down"))
+		return
+	}
+	w.Write([]byte("ok"))
+}
+
+func (r *webhookReceiver) received() []WebhookPayload {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]WebhookPayload(nil), r.payloads...)
+}
+
+func newWebhookReceiver(t *testing.T, fail int) (*webhookReceiver, string) {
+	t.Helper()
+	receiver := &webhookReceiver{fail: fail}
+	srv := httptest.NewServer(receiver)
+	t.Cleanup(srv.Close)
+	return receiver, srv.URL
+}
+
+func TestCancelScheduled(t *testing.T) {
+	step := scenario.Step{Status: "PAYMENT_STATUS_EXPIRED", After: scenario.Duration(time.Hour)}
+
+	tests := []struct {
+		name        string
+		kinds       []JobKind
+		wantRemoved int
+		wantKept    []string
+	}{
+		{
+			name:        "every job of the payment",
+			wantRemoved: 2,
+			wantKept:    []string{"other-payment", "refund"},
+		},
+		{
+			name:        "jobs of one kind",
+			kinds:       []JobKind{JobAuthorizationExpiry},
+			wantRemoved: 1,
+			wantKept:    []string{"other-payment", "refund", "scenario"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := newTestDispatcher(t, testConfig(t), nil)
+			// ReservationID는 job 구분용 이름
+			for _, job := range []Job{
+				{PaymentID: "pay-1", ReservationID: "scenario", Steps: []scenario.Step{step}},
+				{PaymentID: "pay-1", ReservationID: "expiry", Kind: JobAuthorizationExpiry, Steps: []scenario.Step{step}},
+				{PaymentID: "pay-1", ReservationID: "refund", RefundID: "rf-1", Steps: []scenario.Step{step}},
+				{PaymentID: "pay-2", ReservationID: "other-payment", Kind: JobAuthorizationExpiry, Steps: []scenario.Step{step}},
+			} {
+				if err := d.SchedulePayment(job); err != nil {
+					t.Fatalf("SchedulePayment(%s): %v", job.ReservationID, err)
+				}
+			}
+
+			if removed := d.CancelScheduled("pay-1", tt.kinds...); removed != tt.wantRemoved {
+				t.Errorf("removed = %d, want %d", removed, tt.wantRemoved)
+			}
+			var kept []string
+			for _, item := range d.queue.drain() {
+				kept = append(kept, item.task.(*stepTask).job.ReservationID)
+			}
+			slices.Sort(kept)
+			if !slices.Equal(kept, tt.wantKept) {
+				t.Errorf("kept = %v, want %v", kept, tt.wantKept)
+			}
+		})
+	}
+}
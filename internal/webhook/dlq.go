@@ -0,0 +1,223 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"go.uber.org/zap"
+
+	"github.com/traffic-tacos/payment-sim-api/pkg/paymentevent"
+)
+
+// 실패 종류
+const (
+	FailureWebhook     = "webhook"
+	FailureEventBridge = "eventbridge"
+)
+
+// FailureRecord는 webhook 재시도가 소진되거나 EventBridge 발행이 실패했을 때
+// DLQ로 보내는 메시지 본문이다.
+type FailureRecord struct {
+	Kind       string              `json:"kind"`
+	WebhookID  string              `json:"webhook_id,omitempty"`
+	MerchantID string              `json:"merchant_id,omitempty"`
+	PaymentID  string              `json:"payment_id"`
+	URL        string              `json:"url,omitempty"`
+	Payload    *WebhookPayload     `json:"payload,omitempty"`
+	Event      *paymentevent.Event `json:"event,omitempty"`
+	Attempts   []Attempt           `json:"attempts,omitempty"`
+	LastError  string              `json:"last_error"`
+	FailedAt   time.Time           `json:"failed_at"`
+}
+
+// DeadLetterQueue는 실패 기록을 설정된 SQS DLQ에 저장한다.
+type DeadLetterQueue struct {
+	sqsClient *sqs.Client
+	queueURL  string
+	logger    *zap.Logger
+}
+
+// NewDeadLetterQueue는 DLQ URL이 없으면 nil을 반환한다. nil
+// *DeadLetterQueue는 실패를 로그로만 남기고 버린다.
+func NewDeadLetterQueue(sqsClient *sqs.Client, queueURL string, logger *zap.Logger) *DeadLetterQueue {
+	if queueURL == "" {
+		return nil
+	}
+	return &DeadLetterQueue{
+		sqsClient: sqsClient,
+		queueURL:  queueURL,
+		logger:    logger,
+	}
+}
+
+func (q *DeadLetterQueue) Send(ctx context.Context, record FailureRecord) error {
+	if q == nil {
+		return fmt.Errorf("dead-letter queue not configured")
+	}
+
+	body, err := json.Marshal(record)
+	if err != nil {
+		return fmt.Errorf("failed to marshal failure record: %w", err)
+	}
+
+	_, err = q.sqsClient.SendMessage(ctx, &sqs.SendMessageInput{
+		QueueUrl:    aws.String(q.queueURL),
+		MessageBody: aws.String(string(body)),
+		MessageAttributes: map[string]types.MessageAttributeValue{
+			"kind": {DataType: aws.String("String"), StringValue: aws.String(record.Kind)},
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to send failure record to DLQ: %w", err)
+	}
+
+	q.logger.Info("Failure record sent to DLQ",
+		zap.String("kind", record.Kind),
+		zap.String("payment_id", record.PaymentID))
+	return nil
+}
+
+// receive는 long polling 없이 최대 max개의 메시지를 가져온다.
+func (q *DeadLetterQueue) receive(ctx context.Context, max int32) ([]types.Message, error) {
+	if max > 10 {
+		max = 10
+	}
+	result, err := q.sqsClient.ReceiveMessage(ctx, &sqs.ReceiveMessageInput{
+		QueueUrl:            aws.String(q.queueURL),
+		MaxNumberOfMessages: max,
+		WaitTimeSeconds:     1,
+		VisibilityTimeout:   60,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to receive from DLQ: %w", err)
+	}
+	return result.Messages, nil
+}
+
+func (q *DeadLetterQueue) delete(ctx context.Context, message types.Message) error {
+	_, err := q.sqsClient.DeleteMessage(ctx, &sqs.DeleteMessageInput{
+		QueueUrl:      aws.String(q.queueURL),
+		ReceiptHandle: message.ReceiptHandle,
+	})
+	return err
+}
+
+// parseDLQMessage는 이 서비스의 실패 기록과, SQS가 결제 webhook 큐에서
+// redrive한 EventBridge envelope을 모두 받는다.
+func parseDLQMessage(body string) (FailureRecord, error) {
+	var record FailureRecord
+	if err := json.Unmarshal([]byte(body), &record); err == nil && record.Kind != "" {
+		return record, nil
+	}
+
+	envelope, event, err := paymentevent.DecodeMessage([]byte(body))
+	if err != nil {
+		return FailureRecord{}, fmt.Errorf("unrecognized DLQ message: %w", err)
+	}
+	if envelope.DetailType == "" {
+		return FailureRecord{}, fmt.Errorf("unrecognized DLQ message")
+	}
+	return FailureRecord{
+		Kind:      FailureEventBridge,
+		PaymentID: event.PaymentID,
+		Event:     &event,
+	}, nil
+}
+
+// RedriveResult는 redrive 한 번의 결과를 요약한다.
+type RedriveResult struct {
+	Replayed int      `json:"replayed"`
+	Skipped  int      `json:"skipped"`
+	Errors   []string `json:"errors,omitempty"`
+}
+
+// Redrive는 DLQ 항목을 최대 max개까지 dispatcher로 다시 처리한다. 재처리한
+// 항목은 DLQ에서 지우며, 다시 실패하면 새로 dead-letter된다. 알아볼 수 없는
+// 메시지는 큐에 남긴다.
+func (d *Dispatcher) Redrive(ctx context.Context, max int) (RedriveResult, error) {
+	var result RedriveResult
+	if d.dlq == nil {
+		return result, fmt.Errorf("dead-letter queue not configured")
+	}
+
+	for result.Replayed+result.Skipped < max {
+		messages, err := d.dlq.receive(ctx, int32(max-result.Replayed-result.Skipped))
+		if err != nil {
+			return result, err
+		}
+		if len(messages) == 0 {
+			break
+		}
+
+		for _, message := range messages {
+			record, err := parseDLQMessage(aws.ToString(message.Body))
+			if err != nil {
+				result.Skipped++
+				result.Errors = append(result.Errors, fmt.Sprintf("%s: %v", aws.ToString(message.MessageId), err))
+				continue
+			}
+
+			if err := d.dlq.delete(ctx, message); err != nil {
+				result.Skipped++
+				result.Errors = append(result.Errors, fmt.Sprintf("%s: %v", aws.ToString(message.MessageId), err))
+				continue
+			}
+
+			d.replay(record)
+			result.Replayed++
+		}
+	}
+
+	d.logger.Info("DLQ redrive finished",
+		zap.Int("replayed", result.Replayed),
+		zap.Int("skipped", result.Skipped))
+	return result, nil
+}
+
+func (d *Dispatcher) replay(record FailureRecord) {
+	switch record.Kind {
+	case FailureWebhook:
+		if record.Payload != nil {
+			d.deliver(record.WebhookID, record.MerchantID, *record.Payload, record.URL)
+		}
+	case FailureEventBridge:
+		if record.Event != nil {
+			d.queue.push(&publishTask{event: *record.Event}, time.Now(), false)
+		}
+	}
+}
+
+// publishTask는 dead-letter된 EventBridge 이벤트를 worker에서 다시 발행한다.
+type publishTask struct {
+	event paymentevent.Event
+}
+
+func (t *publishTask) kind() string { return "publish" }
+
+func (t *publishTask) run(d *Dispatcher) {
+	d.publish(t.event)
+}
+
+// deadLetter는 실패 기록을 DLQ로 보내고, 그것마저 실패하면 로그를 남긴다.
+func (d *Dispatcher) deadLetter(record FailureRecord) {
+	record.FailedAt = time.Now()
+	if d.dlq == nil {
+		d.logger.Warn("Dropping failure record, no DLQ configured",
+			zap.String("kind", record.Kind),
+			zap.String("payment_id", record.PaymentID),
+			zap.String("last_error", record.LastError))
+		return
+	}
+
+	if err := d.dlq.Send(context.Background(), record); err != nil {
+		d.logger.Error("Failed to dead-letter failure record",
+			zap.String("kind", record.Kind),
+			zap.String("payment_id", record.PaymentID),
+			zap.Error(err))
+	}
+}
@@ -0,0 +1,18 @@
+package webhook
+
+// dispatchError는 어떻게 보고해야 하는지 스스로 알려주는 dispatcher 에러다.
+// 결제 service 같은 호출자는 이 패키지의 sentinel과 비교하지 않고도 분류할 수
+// 있다.
+type dispatchError struct {
+	kind   string
+	reason string
+	msg    string
+}
+
+func (e *dispatchError) Error() string { return e.msg }
+
+// ErrorKind는 에러가 대응하는 service.Kind의 이름이다.
+func (e *dispatchError) ErrorKind() string { return e.kind }
+
+// ErrorReason은 기계가 읽을 수 있는 에러의 고정 reason code다.
+func (e *dispatchError) ErrorReason() string { return e.reason }
@@ -0,0 +1,45 @@
+package webhook
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var (
+	dispatchQueueDepth = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "webhook_dispatch_queue_depth",
+		Help: "Number of scheduled dispatcher tasks waiting for their due time.",
+	})
+
+	dispatchWorkersBusy = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "webhook_dispatch_workers_busy",
+		Help: "Number of dispatcher workers currently running a task.",
+	})
+
+	dispatchTasksTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "webhook_dispatch_tasks_total",
+		Help: "Dispatcher tasks executed, by task kind.",
+	}, []string{"kind"})
+
+	dispatchRejectedTotal = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "webhook_dispatch_rejected_total",
+		Help: "Payments rejected because the dispatch queue was full.",
+	})
+
+	dispatchTaskLag = promauto.NewHistogram(prometheus.HistogramOpts{
+		Name:    "webhook_dispatch_task_lag_seconds",
+		Help:    "Delay between a task's due time and the moment it was handed to a worker.",
+		Buckets: []float64{.001, .005, .01, .05, .1, .5, 1, 5, 10},
+	})
+
+	deliveryTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "webhook_delivery_total",
+		Help: "Webhook delivery attempts, by result (success, failure, exhausted).",
+	}, []string{"result"})
+
+	deliveryLatency = promauto.NewHistogram(prometheus.HistogramOpts{
+		Name:    "webhook_latency_seconds",
+		Help:    "Latency of webhook HTTP attempts.",
+		Buckets: prometheus.DefBuckets,
+	})
+)
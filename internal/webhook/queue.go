@@ -0,0 +1,204 @@
+package webhook
+
+import (
+	"container/heap"
+	"sync"
+	"time"
+)
+
+// ErrQueueFull is returned when a new payment cannot be scheduled because the
+// delay queue already holds the configured maximum number of tasks.
+var ErrQueueFull error = &dispatchError{kind: "resource_exhausted", reason: "DISPATCH_QUEUE_FULL", msg: "webhook dispatch queue is full"}
+
+// task is a unit of work run by a dispatcher worker once it is due. Tasks
+// never sleep; follow-up work (next scenario step, delivery retry) is pushed
+// back onto the delay queue.
+type task interface {
+	kind() string
+	run(d *Dispatcher)
+	// pending describes the task so it can be persisted or reported on shutdown.
+	pending() PendingTask
+}
+
+type scheduledTask struct {
+	due  time.Time
+	seq  uint64 // 같은 시각이면 먼저 넣은 작업부터
+	task task
+}
+
+type taskHeap []*scheduledTask
+
+func (h taskHeap) Len() int { return len(h) }
+func (h taskHeap) Less(i, j int) bool {
+	if h[i].due.Equal(h[j].due) {
+		return h[i].seq < h[j].seq
+	}
+	return h[i].due.Before(h[j].due)
+}
+func (h taskHeap) Swap(i, j int)       { h[i], h[j] = h[j], h[i] }
+func (h *taskHeap) Push(x interface{}) { *h = append(*h, x.(*scheduledTask)) }
+func (h *taskHeap) Pop() interface{} {
+	old := *h
+	item := old[len(old)-1]
+	old[len(old)-1] = nil
+	*h = old[:len(old)-1]
+	return item
+}
+
+// delayQueue is a min-heap of tasks ordered by due time, drained by a single
+// timer goroutine into a bounded ready channel consumed by the worker pool.
+type delayQueue struct {
+	mu       sync.Mutex
+	items    taskHeap
+	seq      uint64
+	maxDepth int
+
+	wake  chan struct{}
+	ready chan *scheduledTask
+}
+
+func newDelayQueue(maxDepth, readyBuffer int) *delayQueue {
+	return &delayQueue{
+		maxDepth: maxDepth,
+		wake:     make(chan struct{}, 1),
+		ready:    make(chan *scheduledTask, readyBuffer),
+	}
+}
+
+// push schedules t at due. When bounded is true the push is rejected with
+// ErrQueueFull once maxDepth tasks are waiting; follow-up tasks of already
+// accepted payments are pushed unbounded so no accepted payment is lost.
+func (q *delayQueue) push(t task, due time.Time, bounded bool) error {
+	q.mu.Lock()
+	if bounded && q.maxDepth > 0 && len(q.items) >= q.maxDepth {
+		q.mu.Unlock()
+		return ErrQueueFull
+	}
+	q.seq++
+	item := &scheduledTask{due: due, seq: q.seq, task: t}
+	heap.Push(&q.items, item)
+	earliest := q.items[0] == item
+	depth := len(q.items)
+	q.mu.Unlock()
+
+	dispatchQueueDepth.Set(float64(depth))
+	if earliest {
+		select {
+		case q.wake <- struct{}{}:
+		default:
+		}
+	}
+	return nil
+}
+
+func (q *delayQueue) len() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return len(q.items)
+}
+
+// remove deletes the waiting tasks matched by match and returns how many were
+// removed. Tasks already handed to the ready channel are not affected.
+func (q *delayQueue) remove(match func(task) bool) int {
+	q.mu.Lock()
+	kept := q.items[:0]
+	for _, item := range q.items {
+		if !match(item.task) {
+			kept = append(kept, item)
+		}
+	}
+	removed := len(q.items) - len(kept)
+	for i := len(kept); i < len(q.items); i++ {
+		q.items[i] = nil
+	}
+	q.items = kept
+	heap.Init(&q.items)
+	depth := len(q.items)
+	q.mu.Unlock()
+
+	if removed > 0 {
+		dispatchQueueDepth.Set(float64(depth))
+		// 가장 이른 작업이 바뀌었을 수 있으므로 scheduler를 깨운다
+		select {
+		case q.wake <- struct{}{}:
+		default:
+		}
+	}
+	return removed
+}
+
+// drain removes every task still waiting, including the ones already handed
+// to the ready channel but not picked up by a worker. Call only after the
+// scheduler goroutine has stopped.
+func (q *delayQueue) drain() []*scheduledTask {
+	var drained []*scheduledTask
+ready:
+	for {
+		select {
+		case item := <-q.ready:
+			drained = append(drained, item)
+		default:
+			break ready
+		}
+	}
+
+	q.mu.Lock()
+	for len(q.items) > 0 {
+		drained = append(drained, heap.Pop(&q.items).(*scheduledTask))
+	}
+	q.mu.Unlock()
+
+	dispatchQueueDepth.Set(0)
+	return drained
+}
+
+// run moves due tasks to the ready channel until stop is closed. Sending to
+// ready blocks while every worker is busy, which is the pool's backpressure.
+func (q *delayQueue) run(stop <-chan struct{}) {
+	timer := time.NewTimer(time.Hour)
+	defer timer.Stop()
+
+	for {
+		q.mu.Lock()
+		var next *scheduledTask
+		if len(q.items) > 0 && !q.items[0].due.After(time.Now()) {
+			next = heap.Pop(&q.items).(*scheduledTask)
+		}
+		wait := time.Hour
+		if next == nil && len(q.items) > 0 {
+			wait = time.Until(q.items[0].due)
+		}
+		depth := len(q.items)
+		q.mu.Unlock()
+
+		if next != nil {
+			dispatchQueueDepth.Set(float64(depth))
+			dispatchTaskLag.Observe(time.Since(next.due).Seconds())
+			select {
+			case q.ready <- next:
+			case <-stop:
+				// 종료 중이면 꺼낸 작업을 다시 넣어 drain에서 회수되게 한다
+				q.mu.Lock()
+				heap.Push(&q.items, next)
+				q.mu.Unlock()
+				return
+			}
+			continue
+		}
+
+		if !timer.Stop() {
+			select {
+			case <-timer.C:
+			default:
+			}
+		}
+		timer.Reset(wait)
+
+		select {
+		case <-timer.C:
+		case <-q.wake:
+		case <-stop:
+			return
+		}
+	}
+}
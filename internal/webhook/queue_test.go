@@ -0,0 +1,149 @@
+package webhook
+
+import (
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testTask is a queued task identified by name.
+type testTask struct {
+	name string
+}
+
+func (t *testTask) kind() string         { return "test" }
+func (t *testTask) run(d *Dispatcher)    {}
+func (t *testTask) pending() PendingTask { return PendingTask{Kind: "test", PaymentID: t.name} }
+
+func taskNames(items []*scheduledTask) []string {
+	var names []string
+	for _, item := range items {
+		names = append(names, item.task.(*testTask).name)
+	}
+	return names
+}
+
+func TestDelayQueueRunsByDueTime(t *testing.T) {
+	q := newDelayQueue(0, 10)
+	now := time.Now()
+	pushes := []struct {
+		name string
+		due  time.Time
+	}{
+		{"third", now.Add(-time.Second)},
+		{"first", now.Add(-3 * time.Second)},
+		{"second-a", now.Add(-2 * time.Second)},
+		{"second-b", now.Add(-2 * time.Second)}, // 같은 시각이면 먼저 넣은 작업부터
+		{"later", now.Add(time.Hour)},
+	}
+	for _, p := range pushes {
+		if err := q.push(&testTask{name: p.name}, p.due, true); err != nil {
+			t.Fatalf("push(%s): %v", p.name, err)
+		}
+	}
+
+	stop := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		q.run(stop)
+		close(done)
+	}()
+
+	var got []*scheduledTask
+	for range 4 {
+		select {
+		case item := <-q.ready:
+			got = append(got, item)
+		case <-time.After(5 * time.Second):
+			t.Fatalf("only %v became ready", taskNames(got))
+		}
+	}
+	close(stop)
+	<-done
+
+	if want := []string{"first", "second-a", "second-b", "third"}; !slices.Equal(taskNames(got), want) {
+		t.Errorf("ready order = %v, want %v", taskNames(got), want)
+	}
+	if q.len() != 1 {
+		t.Errorf("queue holds %d tasks, want the one not yet due", q.len())
+	}
+}
+
+func TestDelayQueueDepthLimit(t *testing.T) {
+	q := newDelayQueue(2, 1)
+	due := time.Now().Add(time.Hour)
+
+	for _, name := range []string{"a", "b"} {
+		if err := q.push(&testTask{name: name}, due, true); err != nil {
+			t.Fatalf("push(%s): %v", name, err)
+		}
+	}
+	if err := q.push(&testTask{name: "c"}, due, true); !errors.Is(err, ErrQueueFull) {
+		t.Errorf("bounded push at max depth = %v, want %v", err, ErrQueueFull)
+	}
+	// 이미 받은 결제의 후속 작업은 한도와 관계없이 들어간다
+	if err := q.push(&testTask{name: "follow-up"}, due, false); err != nil {
+		t.Errorf("unbounded push at max depth = %v, want nil", err)
+	}
+	if q.len() != 3 {
+		t.Errorf("queue holds %d tasks, want 3", q.len())
+	}
+}
+
+func TestDelayQueueRemoveAndDrain(t *testing.T) {
+	q := newDelayQueue(0, 10)
+	now := time.Now()
+	for i, name := range []string{"keep-1", "drop-1", "keep-2", "drop-2"} {
+		q.push(&testTask{name: name}, now.Add(time.Duration(i)*time.Minute), false)
+	}
+	// scheduler가 이미 넘긴 작업도 drain으로 회수된다
+	q.ready <- &scheduledTask{due: now, task: &testTask{name: "handed-over"}}
+
+	removed := q.remove(func(t task) bool {
+		return strings.HasPrefix(t.(*testTask).name, "drop")
+	})
+	if removed != 2 {
+		t.Errorf("removed = %d, want 2", removed)
+	}
+
+	drained := q.drain()
+	if want := []string{"handed-over", "keep-1", "keep-2"}; !slices.Equal(taskNames(drained), want) {
+		t.Errorf("drained = %v, want %v", taskNames(drained), want)
+	}
+	if q.len() != 0 || len(q.ready) != 0 {
+		t.Errorf("queue holds %d tasks and %d ready after drain", q.len(), len(q.ready))
+	}
+}
+
+func TestDelayQueueRunRequeuesWhenStopped(t *testing.T) {
+	// ready를 받을 worker가 없어 scheduler는 꺼낸 작업을 들고 기다린다
+	q := newDelayQueue(0, 0)
+	q.push(&testTask{name: "in-hand"}, time.Now(), false)
+
+	stop := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		q.run(stop)
+		close(done)
+	}()
+	deadline := time.Now().Add(5 * time.Second)
+	for q.len() != 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("scheduler never took the due task")
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	close(stop)
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("run did not return after stop")
+	}
+
+	if got := taskNames(q.drain()); !slices.Equal(got, []string{"in-hand"}) {
+		t.Errorf("drained = %v, want the task the scheduler held", got)
+	}
+}
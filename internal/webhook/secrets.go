@@ -0,0 +1,105 @@
+package webhook
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+
+	"go.yaml.in/yaml/v2"
+)
+
+// SecretConfig는 merchant와 webhook URL별 서명 secret을 담는다. 나열된 secret은
+// 모두 활성이며, webhook은 secret마다 서명을 하나씩 담으므로 수신자는 중단 없이
+// 키를 교체할 수 있다. 첫 항목이 primary다.
+type SecretConfig struct {
+	Merchants map[string][]string `json:"merchants,omitempty" yaml:"merchants,omitempty"`
+	URLs      map[string][]string `json:"urls,omitempty" yaml:"urls,omitempty"`
+}
+
+// Secrets는 webhook의 서명 secret을 고른다. URL별 secret이 merchant별 secret보다,
+// merchant별 secret이 전역 기본 secret보다 우선한다.
+type Secrets struct {
+	mu        sync.RWMutex
+	defaults  []string
+	overrides SecretConfig
+}
+
+func NewSecrets(defaults ...string) *Secrets {
+	s := &Secrets{}
+	for _, secret := range defaults {
+		if secret = strings.TrimSpace(secret); secret != "" {
+			s.defaults = append(s.defaults, secret)
+		}
+	}
+	return s
+}
+
+// ParseSecretConfig는 YAML 또는 JSON secret 문서를 디코딩한다.
+func ParseSecretConfig(data []byte) (SecretConfig, error) {
+	var cfg SecretConfig
+	if err := yaml.Unmarshal(data, &cfg); err != nil {
+		return SecretConfig{}, fmt.Errorf("failed to parse webhook secrets: %w", err)
+	}
+	for merchant, secrets := range cfg.Merchants {
+		if err := checkSecrets(secrets); err != nil {
+			return SecretConfig{}, fmt.Errorf("merchant %q: %w", merchant, err)
+		}
+	}
+	for url, secrets := range cfg.URLs {
+		if err := checkSecrets(secrets); err != nil {
+			return SecretConfig{}, fmt.Errorf("webhook url %q: %w", url, err)
+		}
+	}
+	return cfg, nil
+}
+
+// checkSecrets는 빈 목록과 빈 항목("" 또는 비어 있는 목록 항목)을 거부한다.
+// 그대로 두면 webhook을 빈 키로 서명하게 된다.
+func checkSecrets(secrets []string) error {
+	if len(secrets) == 0 {
+		return fmt.Errorf("no secrets")
+	}
+	for i, secret := range secrets {
+		if strings.TrimSpace(secret) == "" {
+			return fmt.Errorf("secret %d is empty", i+1)
+		}
+	}
+	return nil
+}
+
+func (s *Secrets) LoadFile(path string) (SecretConfig, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return SecretConfig{}, fmt.Errorf("failed to read webhook secrets file: %w", err)
+	}
+	cfg, err := ParseSecretConfig(data)
+	if err != nil {
+		return SecretConfig{}, err
+	}
+	s.Replace(cfg)
+	return cfg, nil
+}
+
+// Replace는 merchant별/URL별 secret을 교체한다. 기본 secret은 유지한다.
+func (s *Secrets) Replace(cfg SecretConfig) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.overrides = cfg
+}
+
+// For는 webhook의 활성 secret을 primary부터 반환한다.
+func (s *Secrets) For(merchantID, url string) []string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if secrets, ok := s.overrides.URLs[url]; ok {
+		return secrets
+	}
+	if merchantID != "" {
+		if secrets, ok := s.overrides.Merchants[merchantID]; ok {
+			return secrets
+		}
+	}
+	return s.defaults
+}
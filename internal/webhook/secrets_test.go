@@ -0,0 +1,43 @@
+package webhook
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestParseSecretConfig(t *testing.T) {
+	tests := []struct {
+		name    string
+		doc     string
+		want    []string // m-1의 secret
+		wantErr string
+	}{
+		{name: "yaml", doc: "merchants:\n  m-1: [new, old]\n", want: []string{"new", "old"}},
+		{name: "json", doc: `{"merchants":{"m-1":["new"]}}`, want: []string{"new"}},
+		{name: "no secrets", doc: "merchants:\n  m-1: []\n", wantErr: `merchant "m-1": no secrets`},
+		{name: "empty secret", doc: "merchants:\n  m-1: [new, \"\"]\n", wantErr: `merchant "m-1": secret 2 is empty`},
+		{name: "blank secret", doc: "urls:\n  https://merchant.test/webhook: [\" \"]\n", wantErr: `webhook url "https://merchant.test/webhook": secret 1 is empty`},
+		{name: "trailing comma", doc: "merchants:\n  m-1: [new, ]\n", want: []string{"new"}},
+		{name: "json empty secret", doc: `{"merchants":{"m-1":["new",""]}}`, wantErr: `merchant "m-1": secret 2 is empty`},
+		{name: "empty list item", doc: "merchants:\n  m-1:\n    - new\n    -\n", wantErr: `merchant "m-1": secret 2 is empty`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg, err := ParseSecretConfig([]byte(tt.doc))
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("ParseSecretConfig err = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseSecretConfig: %v", err)
+			}
+			if got := cfg.Merchants["m-1"]; !slices.Equal(got, tt.want) {
+				t.Errorf("m-1 secrets = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
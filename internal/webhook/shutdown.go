@@ -0,0 +1,281 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/traffic-tacos/payment-sim-api/pkg/paymentevent"
+)
+
+// ErrDispatcherClosed is returned by SchedulePayment once Shutdown has begun.
+var ErrDispatcherClosed error = &dispatchError{kind: "unavailable", reason: "SHUTTING_DOWN", msg: "webhook dispatcher is shutting down"}
+
+// Pending task kinds
+const (
+	PendingStep     = "step"
+	PendingDelivery = "delivery"
+	PendingPublish  = "publish"
+)
+
+// PendingTask is a dispatcher task that had not run when Shutdown was called.
+// It is what gets persisted for the next process, or reported as dropped.
+type PendingTask struct {
+	Kind       string              `json:"kind"`
+	PaymentID  string              `json:"payment_id"`
+	Due        time.Time           `json:"due"`
+	Job        *Job                `json:"job,omitempty"`
+	StepIndex  int                 `json:"step_index,omitempty"`
+	Last       *WebhookPayload     `json:"last,omitempty"` // duplicate_webhook용 직전 payload
+	WebhookID  string              `json:"webhook_id,omitempty"`
+	MerchantID string              `json:"merchant_id,omitempty"`
+	URL        string              `json:"url,omitempty"`
+	Payload    *WebhookPayload     `json:"payload,omitempty"`
+	Attempt    int                 `json:"attempt,omitempty"`
+	Attempts   []Attempt           `json:"attempts,omitempty"` // 지금까지의 전송 이력 (DLQ 기록용)
+	Event      *paymentevent.Event `json:"event,omitempty"`
+}
+
+// ShutdownReport tells what happened to every task still pending at shutdown.
+type ShutdownReport struct {
+	Persisted int           `json:"persisted"`
+	Flushed   int           `json:"flushed"`
+	Dropped   []PendingTask `json:"dropped,omitempty"`
+	// InFlight is the number of workers still running a task when ctx expired.
+	// Shutdown still waits for them, so the follow-up work they queue is
+	// persisted or reported like any other pending task.
+	InFlight int `json:"in_flight,omitempty"`
+}
+
+func (t *stepTask) pending() PendingTask {
+	job := t.job.Job
+	return PendingTask{
+		Kind:      PendingStep,
+		PaymentID: job.PaymentID,
+		Job:       &job,
+		StepIndex: t.index,
+		Last:      t.job.last,
+		WebhookID: t.job.lastID,
+	}
+}
+
+func (t *deliveryTask) pending() PendingTask {
+	payload := t.payload
+	return PendingTask{
+		Kind:       PendingDelivery,
+		PaymentID:  payload.PaymentID,
+		WebhookID:  t.webhookID,
+		MerchantID: t.merchantID,
+		URL:        t.url,
+		Payload:    &payload,
+		Attempt:    t.number,
+	}
+}
+
+func (t *publishTask) pending() PendingTask {
+	event := t.event
+	return PendingTask{
+		Kind:      PendingPublish,
+		PaymentID: event.PaymentID,
+		Event:     &event,
+	}
+}
+
+// Shutdown stops accepting payments, waits for running tasks to finish and
+// then deals with everything still queued: with WEBHOOK_PENDING_PATH set the
+// tasks are written there and resumed by the next Start; otherwise queued
+// deliveries and publishes are flushed immediately (one attempt each) until
+// ctx expires. Whatever could not be persisted or flushed is returned in
+// ShutdownReport.Dropped and logged one by one.
+func (d *Dispatcher) Shutdown(ctx context.Context) (ShutdownReport, error) {
+	var report ShutdownReport
+	if !d.closed.CompareAndSwap(false, true) {
+		return report, ErrDispatcherClosed
+	}
+	close(d.stop)
+
+	done := make(chan struct{})
+	go func() {
+		d.wg.Wait()
+		close(done)
+	}()
+
+	var waitErr error
+	select {
+	case <-done:
+	case <-ctx.Done():
+		waitErr = fmt.Errorf("timed out waiting for dispatcher workers: %w", ctx.Err())
+		report.InFlight = int(d.busy.Load())
+		// 실행 중인 task가 넣을 재시도/다음 step도 저장되거나 보고되도록 끝까지 기다린다.
+		// task는 webhook timeout 안에 끝나며, 이후 flush는 ctx가 만료되어 건너뛴다.
+		d.logger.Warn("Waiting for in-flight webhook tasks", zap.Int("in_flight", report.InFlight))
+		<-done
+	}
+
+	var pending []PendingTask
+	for _, item := range d.queue.drain() {
+		p := item.task.pending()
+		p.Due = item.due
+		if t, ok := item.task.(*deliveryTask); ok {
+			p.Attempts = d.deliveries.attempts(t.delivery)
+		}
+		pending = append(pending, p)
+	}
+
+	if d.config.WebhookPendingPath != "" && len(pending) > 0 {
+		if err := writePending(d.config.WebhookPendingPath, pending); err != nil {
+			d.logger.Error("Failed to persist pending webhook tasks",
+				zap.String("path", d.config.WebhookPendingPath),
+				zap.Error(err))
+		} else {
+			report.Persisted = len(pending)
+			pending = nil
+		}
+	}
+
+	for _, p := range pending {
+		if ctx.Err() == nil && d.flush(p) {
+			report.Flushed++
+			continue
+		}
+		report.Dropped = append(report.Dropped, p)
+	}
+
+	for _, p := range report.Dropped {
+		d.logger.Warn("Dropped pending webhook task on shutdown",
+			zap.String("kind", p.Kind),
+			zap.String("payment_id", p.PaymentID),
+			zap.String("webhook_id", p.WebhookID),
+			zap.Int("step_index", p.StepIndex),
+			zap.Time("due", p.Due))
+	}
+	d.logger.Info("Webhook dispatcher stopped",
+		zap.Int("persisted", report.Persisted),
+		zap.Int("flushed", report.Flushed),
+		zap.Int("dropped", len(report.Dropped)),
+		zap.Int("in_flight", report.InFlight))
+
+	return report, waitErr
+}
+
+// flush runs an already decided task right away. Scenario steps are not
+// flushed: running them early would change the simulated outcome timing.
+func (d *Dispatcher) flush(p PendingTask) bool {
+	switch p.Kind {
+	case PendingDelivery:
+		attempt := d.sendWebhook(p.WebhookID, p.MerchantID, *p.Payload, p.URL, p.Attempt)
+		if !attempt.succeeded() {
+			d.deadLetter(FailureRecord{
+				Kind:       FailureWebhook,
+				WebhookID:  p.WebhookID,
+				MerchantID: p.MerchantID,
+				PaymentID:  p.PaymentID,
+				URL:        p.URL,
+				Payload:    p.Payload,
+				Attempts:   append(p.Attempts, attempt),
+				LastError:  attempt.Error,
+			})
+		}
+		return true
+	case PendingPublish:
+		d.publish(*p.Event)
+		return true
+	}
+	return false
+}
+
+// resume re-queues tasks persisted by a previous Shutdown and removes the file.
+func (d *Dispatcher) resume() {
+	path := d.config.WebhookPendingPath
+	if path == "" {
+		return
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			d.logger.Error("Failed to read pending webhook tasks", zap.String("path", path), zap.Error(err))
+		}
+		return
+	}
+
+	var pending []PendingTask
+	if err := json.Unmarshal(data, &pending); err != nil {
+		d.logger.Error("Failed to parse pending webhook tasks", zap.String("path", path), zap.Error(err))
+		return
+	}
+
+	for _, p := range pending {
+		t, err := d.restore(p)
+		if err != nil {
+			d.logger.Warn("Skipping pending webhook task",
+				zap.String("kind", p.Kind),
+				zap.String("payment_id", p.PaymentID),
+				zap.Error(err))
+			continue
+		}
+		// 지난 due는 즉시 실행된다
+		d.queue.push(t, p.Due, false)
+	}
+
+	if err := os.Remove(path); err != nil {
+		d.logger.Error("Failed to remove pending webhook tasks file", zap.String("path", path), zap.Error(err))
+	}
+	d.logger.Info("Resumed pending webhook tasks", zap.String("path", path), zap.Int("count", len(pending)))
+}
+
+func (d *Dispatcher) restore(p PendingTask) (task, error) {
+	switch p.Kind {
+	case PendingStep:
+		if p.Job == nil || p.StepIndex < 0 || p.StepIndex >= len(p.Job.Steps) {
+			return nil, fmt.Errorf("invalid step task")
+		}
+		return &stepTask{
+			job:   &jobState{Job: *p.Job, last: p.Last, lastID: p.WebhookID},
+			index: p.StepIndex,
+		}, nil
+	case PendingDelivery:
+		if p.Payload == nil || p.URL == "" {
+			return nil, fmt.Errorf("invalid delivery task")
+		}
+		delivery := d.deliveries.start(p.WebhookID, *p.Payload, p.URL)
+		for _, attempt := range p.Attempts {
+			d.deliveries.record(delivery, attempt)
+		}
+		return &deliveryTask{
+			webhookID:  p.WebhookID,
+			merchantID: p.MerchantID,
+			payload:    *p.Payload,
+			url:        p.URL,
+			delivery:   delivery,
+			number:     p.Attempt,
+		}, nil
+	case PendingPublish:
+		if p.Event == nil {
+			return nil, fmt.Errorf("invalid publish task")
+		}
+		return &publishTask{event: *p.Event}, nil
+	}
+	return nil, fmt.Errorf("unknown pending task kind: %q", p.Kind)
+}
+
+// writePending writes the tasks atomically (temp file + rename).
+func writePending(path string, pending []PendingTask) error {
+	data, err := json.MarshalIndent(pending, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal pending tasks: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return fmt.Errorf("failed to create pending tasks directory: %w", err)
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return fmt.Errorf("failed to write pending tasks: %w", err)
+	}
+	return os.Rename(tmp, path)
+}
@@ -0,0 +1,311 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"go.uber.org/zap"
+
+	"github.com/traffic-tacos/payment-sim-api/internal/scenario"
+	"github.com/traffic-tacos/payment-sim-api/pkg/paymentevent"
+)
+
+// laterJob is a payment whose only step runs an hour from now.
+func laterJob(paymentID string) Job {
+	return Job{
+		PaymentID:     paymentID,
+		ReservationID: "rsv-" + paymentID,
+		Amount:        10000,
+		Currency:      "KRW",
+		Steps: []scenario.Step{
+			{Status: "PAYMENT_STATUS_COMPLETED", After: scenario.Duration(time.Hour)},
+		},
+	}
+}
+
+func testEvent(paymentID string) paymentevent.Event {
+	return paymentevent.Event{
+		PaymentID:     paymentID,
+		ReservationID: "rsv-" + paymentID,
+		Status:        paymentevent.StatusCompleted,
+		Amount:        10000,
+		Currency:      "KRW",
+	}
+}
+
+// queuePending schedules one of each task kind on a dispatcher that is not
+// started, so every task is still queued at shutdown.
+func queuePending(t *testing.T, d *Dispatcher, url string) {
+	t.Helper()
+	if err := d.SchedulePayment(laterJob("pay-step")); err != nil {
+		t.Fatalf("SchedulePayment: %v", err)
+	}
+	d.deliver("wh-1", "", WebhookPayload{PaymentID: "pay-delivery", Status: "PAYMENT_STATUS_COMPLETED"}, url)
+	d.queue.push(&publishTask{event: testEvent("pay-publish")}, time.Now(), false)
+}
+
+func pendingKinds(tasks []PendingTask) []string {
+	var kinds []string
+	for _, p := range tasks {
+		kinds = append(kinds, p.Kind+":"+p.PaymentID)
+	}
+	slices.Sort(kinds)
+	return kinds
+}
+
+func TestShutdownPersistsAndResumes(t *testing.T) {
+	cfg := testConfig(t)
+	cfg.WebhookPendingPath = filepath.Join(t.TempDir(), "pending", "webhooks.json")
+
+	d := newTestDispatcher(t, cfg, nil)
+	queuePending(t, d, "http://merchant.test/webhook")
+	want := make(map[string]PendingTask)
+	for _, item := range d.queue.items {
+		p := item.task.pending()
+		p.Due = item.due
+		want[p.Kind+":"+p.PaymentID] = p
+	}
+
+	report, err := d.Shutdown(context.Background())
+	if err != nil {
+		t.Fatalf("Shutdown: %v", err)
+	}
+	if report.Persisted != 3 || report.Flushed != 0 || len(report.Dropped) != 0 {
+		t.Fatalf("report = %+v, want 3 persisted", report)
+	}
+	if err := d.SchedulePayment(laterJob("pay-late")); !errors.Is(err, ErrDispatcherClosed) {
+		t.Errorf("SchedulePayment after Shutdown = %v, want %v", err, ErrDispatcherClosed)
+	}
+
+	// 다음 프로세스
+	next := newTestDispatcher(t, cfg, nil)
+	next.resume()
+	if _, err := os.Stat(cfg.WebhookPendingPath); !os.IsNotExist(err) {
+		t.Errorf("pending file still exists after resume: %v", err)
+	}
+
+	var resumed []PendingTask
+	for _, item := range next.queue.drain() {
+		p := item.task.pending()
+		p.Due = item.due
+		resumed = append(resumed, p)
+	}
+	if got := pendingKinds(resumed); !slices.Equal(got, []string{"delivery:pay-delivery", "publish:pay-publish", "step:pay-step"}) {
+		t.Fatalf("resumed tasks = %v", got)
+	}
+	for _, got := range resumed {
+		w := want[got.Kind+":"+got.PaymentID]
+		if !got.Due.Equal(w.Due) || got.StepIndex != w.StepIndex || got.WebhookID != w.WebhookID || got.URL != w.URL || got.Attempt != w.Attempt {
+			t.Errorf("resumed %s = %+v, want %+v", got.Kind, got, w)
+		}
+	}
+}
+
+func TestShutdownFlushesWithoutPendingPath(t *testing.T) {
+	cfg := testConfig(t)
+	publisher, eventBridge := newTestPublisher(t, cfg)
+	receiver, url := newWebhookReceiver(t, 0)
+
+	d := newTestDispatcher(t, cfg, publisher)
+	queuePending(t, d, url)
+
+	report, err := d.Shutdown(context.Background())
+	if err != nil {
+		t.Fatalf("Shutdown: %v", err)
+	}
+	if report.Flushed != 2 || report.Persisted != 0 {
+		t.Errorf("report = %+v, want 2 flushed", report)
+	}
+	// scenario step은 결과 시점을 바꾸므로 flush하지 않는다
+	if got := pendingKinds(report.Dropped); !slices.Equal(got, []string{"step:pay-step"}) {
+		t.Errorf("dropped = %v, want the step task only", got)
+	}
+
+	if got := receiver.received(); len(got) != 1 || got[0].PaymentID != "pay-delivery" {
+		t.Errorf("webhooks received = %+v, want pay-delivery", got)
+	}
+	if got := eventBridge.published(); len(got) != 1 || got[0].PaymentID != "pay-publish" {
+		t.Errorf("events published = %+v, want pay-publish", got)
+	}
+}
+
+func TestShutdownReportsDroppedStepsWhenContextExpires(t *testing.T) {
+	cfg := testConfig(t)
+	cfg.WebhookMaxAttempts = 3
+
+	// ctx가 만료될 때까지 worker 하나를 붙잡아 두었다가 실패로 응답하는 webhook 수신자
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-ctx.Done()
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	t.Cleanup(srv.Close)
+
+	d := newTestDispatcher(t, cfg, nil)
+	d.Start()
+	d.deliver("wh-busy", "", WebhookPayload{PaymentID: "pay-busy"}, srv.URL)
+	deadline := time.Now().Add(5 * time.Second)
+	for d.busy.Load() == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("worker never picked up the delivery")
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	for _, id := range []string{"pay-1", "pay-2"} {
+		if err := d.SchedulePayment(laterJob(id)); err != nil {
+			t.Fatalf("SchedulePayment(%s): %v", id, err)
+		}
+	}
+
+	report, err := d.Shutdown(ctx)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Shutdown err = %v, want %v", err, context.DeadlineExceeded)
+	}
+	if report.InFlight != 1 {
+		t.Errorf("in flight = %d, want 1", report.InFlight)
+	}
+	if report.Flushed != 0 || report.Persisted != 0 {
+		t.Errorf("report = %+v, want nothing flushed or persisted", report)
+	}
+	// 실행 중이던 전송의 재시도도 버려진 task로 보고된다
+	if got := pendingKinds(report.Dropped); !slices.Equal(got, []string{"delivery:pay-busy", "step:pay-1", "step:pay-2"}) {
+		t.Errorf("dropped = %v, want the retry and both step tasks", got)
+	}
+	for _, p := range report.Dropped {
+		switch p.Kind {
+		case PendingDelivery:
+			if p.Attempt != 2 || len(p.Attempts) != 1 {
+				t.Errorf("dropped retry = attempt %d with %d attempts, want attempt 2 after 1", p.Attempt, len(p.Attempts))
+			}
+		case PendingStep:
+			if p.Job == nil || p.StepIndex != 0 || p.Due.IsZero() {
+				t.Errorf("dropped task %+v lacks its job, step or due time", p)
+			}
+		}
+	}
+}
+
+// fakeSQS is a SendMessage endpoint that records the failure records sent to
+// the DLQ.
+type fakeSQS struct {
+	mu      sync.Mutex
+	records []FailureRecord
+}
+
+func (f *fakeSQS) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		MessageBody string
+	}
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var record FailureRecord
+	json.Unmarshal([]byte(input.MessageBody), &record)
+	f.mu.Lock()
+	f.records = append(f.records, record)
+	f.mu.Unlock()
+
+	w.Header().Set("Content-Type", "application/x-amz-json-1.0")
+	w.Write([]byte(`{"MessageId":"msg-1"}`))
+}
+
+func (f *fakeSQS) sent() []FailureRecord {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]FailureRecord(nil), f.records...)
+}
+
+// newTestDLQ returns a DLQ whose records go to a fakeSQS.
+func newTestDLQ(t *testing.T) (*DeadLetterQueue, *fakeSQS) {
+	t.Helper()
+	fake := &fakeSQS{}
+	srv := httptest.NewServer(fake)
+	t.Cleanup(srv.Close)
+
+	client := sqs.New(sqs.Options{
+		Region:                           "ap-northeast-2",
+		BaseEndpoint:                     aws.String(srv.URL),
+		Credentials:                      credentials.NewStaticCredentialsProvider("test", "test", ""),
+		RetryMaxAttempts:                 1,
+		DisableMessageChecksumValidation: true,
+	})
+	return NewDeadLetterQueue(client, srv.URL+"/dlq", zap.NewNop()), fake
+}
+
+// failOnce retries a delivery that failed its first attempt, so the retry
+// queued at shutdown carries one attempt of history.
+func failOnce(t *testing.T, d *Dispatcher, url string) {
+	t.Helper()
+	d.deliver("wh-1", "", WebhookPayload{PaymentID: "pay-delivery"}, url)
+	item := d.queue.drain()[0]
+	item.task.run(d)
+	if n := d.queue.len(); n != 1 {
+		t.Fatalf("queued tasks after a failed attempt = %d, want the retry", n)
+	}
+}
+
+func TestShutdownFlushKeepsAttemptHistory(t *testing.T) {
+	cfg := testConfig(t)
+	cfg.WebhookMaxAttempts = 3
+	dlq, fake := newTestDLQ(t)
+	_, url := newWebhookReceiver(t, 2)
+
+	d := NewDispatcher(zap.NewNop(), cfg, nil, dlq, NewSecrets("test-secret"))
+	failOnce(t, d, url)
+
+	report, err := d.Shutdown(context.Background())
+	if err != nil {
+		t.Fatalf("Shutdown: %v", err)
+	}
+	if report.Flushed != 1 {
+		t.Fatalf("report = %+v, want the retry flushed", report)
+	}
+
+	records := fake.sent()
+	if len(records) != 1 {
+		t.Fatalf("DLQ records = %d, want 1", len(records))
+	}
+	attempts := records[0].Attempts
+	if len(attempts) != 2 || attempts[0].Number != 1 || attempts[1].Number != 2 {
+		t.Errorf("dead-lettered attempts = %+v, want attempts 1 and 2", attempts)
+	}
+}
+
+func TestShutdownPersistsAttemptHistory(t *testing.T) {
+	cfg := testConfig(t)
+	cfg.WebhookMaxAttempts = 3
+	cfg.WebhookPendingPath = filepath.Join(t.TempDir(), "webhooks.json")
+	_, url := newWebhookReceiver(t, 1)
+
+	d := newTestDispatcher(t, cfg, nil)
+	failOnce(t, d, url)
+	if _, err := d.Shutdown(context.Background()); err != nil {
+		t.Fatalf("Shutdown: %v", err)
+	}
+
+	next := newTestDispatcher(t, cfg, nil)
+	next.resume()
+	deliveries := next.Deliveries("pay-delivery")
+	if len(deliveries) != 1 || len(deliveries[0].Attempts) != 1 {
+		t.Fatalf("resumed deliveries = %+v, want one with the first attempt", deliveries)
+	}
+	if got := deliveries[0].Attempts[0]; got.Number != 1 || got.Error == "" {
+		t.Errorf("resumed attempt = %+v, want the failed first attempt", got)
+	}
+}
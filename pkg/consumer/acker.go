@@ -0,0 +1,87 @@
+package consumer
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"go.uber.org/zap"
+)
+
+type ackRequest struct {
+	msg    *Message
+	logger *zap.Logger
+}
+
+// runAcker deletes acknowledged messages with DeleteMessageBatch, flushing
+// when a batch is full or AckFlushInterval after its first message. It
+// returns after acks is closed and the last batch is flushed.
+func (c *Consumer) runAcker(acks <-chan ackRequest) {
+	batch := make([]ackRequest, 0, maxBatchSize)
+	timer := time.NewTimer(c.config.AckFlushInterval)
+	timer.Stop()
+
+	flush := func() {
+		timer.Stop()
+		if len(batch) > 0 {
+			c.deleteBatch(batch)
+			batch = batch[:0]
+		}
+	}
+
+	for {
+		select {
+		case req, ok := <-acks:
+			if !ok {
+				flush()
+				return
+			}
+			batch = append(batch, req)
+			if len(batch) == 1 {
+				timer.Reset(c.config.AckFlushInterval)
+			}
+			if len(batch) == maxBatchSize {
+				flush()
+			}
+		case <-timer.C:
+			flush()
+		}
+	}
+}
+
+func (c *Consumer) deleteBatch(batch []ackRequest) {
+	entries := make([]types.DeleteMessageBatchRequestEntry, len(batch))
+	for i, req := range batch {
+		entries[i] = types.DeleteMessageBatchRequestEntry{
+			Id:            aws.String(strconv.Itoa(i)), // batch 안에서만 유일하면 된다
+			ReceiptHandle: aws.String(req.msg.ReceiptHandle),
+		}
+	}
+	ackBatchSize.Observe(float64(len(batch)))
+
+	result, err := c.sqs.DeleteMessageBatch(context.Background(), &sqs.DeleteMessageBatchInput{
+		QueueUrl: aws.String(c.config.QueueURL),
+		Entries:  entries,
+	})
+	if err != nil {
+		ackFailuresTotal.Add(float64(len(batch)))
+		c.logger.Error("Failed to delete message batch from SQS",
+			zap.Int("batch_size", len(batch)),
+			zap.Error(err))
+		return
+	}
+
+	for _, failed := range result.Failed {
+		i, err := strconv.Atoi(aws.ToString(failed.Id))
+		if err != nil || i < 0 || i >= len(batch) {
+			continue
+		}
+		ackFailuresTotal.Inc()
+		batch[i].logger.Error("Failed to delete message from SQS",
+			zap.String("code", aws.ToString(failed.Code)),
+			zap.String("error_message", aws.ToString(failed.Message)))
+	}
+}
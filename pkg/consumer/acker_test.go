@@ -0,0 +1,123 @@
+package consumer
+
+import (
+	"errors"
+	"slices"
+	"strconv"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestDeleteBatch(t *testing.T) {
+	tests := []struct {
+		name         string
+		acks         int
+		failing      []string // receipt handles DeleteMessageBatch reports as failed
+		deleteErr    error
+		wantBatches  []int
+		wantDeleted  []string
+		wantFailures int // error logs: one per failed entry, or one for the batch
+	}{
+		{
+			name:        "all deleted",
+			acks:        3,
+			wantBatches: []int{3},
+			wantDeleted: []string{"rh-0", "rh-1", "rh-2"},
+		},
+		{
+			name:         "partial failure",
+			acks:         4,
+			failing:      []string{"rh-1", "rh-3"},
+			wantBatches:  []int{4},
+			wantDeleted:  []string{"rh-0", "rh-2"},
+			wantFailures: 2,
+		},
+		{
+			name:         "whole batch fails",
+			acks:         3,
+			deleteErr:    errors.New("throttled"),
+			wantBatches:  []int{3},
+			wantFailures: 1,
+		},
+		{
+			name:         "failures in the second batch",
+			acks:         12,
+			failing:      []string{"rh-11"},
+			wantBatches:  []int{10, 2},
+			wantDeleted:  []string{"rh-0", "rh-1", "rh-2", "rh-3", "rh-4", "rh-5", "rh-6", "rh-7", "rh-8", "rh-9", "rh-10"},
+			wantFailures: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := newFakeSQS()
+			client.deleteErr = tt.deleteErr
+			for _, handle := range tt.failing {
+				client.deleteFailures[handle] = true
+			}
+			core, logs := observer.New(zapcore.ErrorLevel)
+			c := newTestConsumer(Config{}, client)
+			c.logger = zap.New(core)
+
+			acks := make(chan ackRequest, tt.acks)
+			for i := 0; i < tt.acks; i++ {
+				handle := "rh-" + strconv.Itoa(i)
+				acks <- ackRequest{msg: &Message{ReceiptHandle: handle}, logger: c.logger.With(zap.String("receipt_handle", handle))}
+			}
+			close(acks)
+			c.runAcker(acks)
+
+			var batches []int
+			for _, batch := range client.batches {
+				batches = append(batches, len(batch))
+			}
+			if !slices.Equal(batches, tt.wantBatches) {
+				t.Errorf("batch sizes = %v, want %v", batches, tt.wantBatches)
+			}
+			if !slices.Equal(client.deleted, tt.wantDeleted) {
+				t.Errorf("deleted = %v, want %v", client.deleted, tt.wantDeleted)
+			}
+			if logs.Len() != tt.wantFailures {
+				t.Errorf("error logs = %d, want %d", logs.Len(), tt.wantFailures)
+			}
+			for _, entry := range logs.FilterMessage("Failed to delete message from SQS").All() {
+				handle := entry.ContextMap()["receipt_handle"].(string)
+				if !slices.Contains(tt.failing, handle) {
+					t.Errorf("failure logged for %s, which was deleted", handle)
+				}
+			}
+		})
+	}
+}
+
+func TestAckerFlushesPartialBatch(t *testing.T) {
+	client := newFakeSQS()
+	c := newTestConsumer(Config{AckFlushInterval: 10 * time.Millisecond}, client)
+
+	acks := make(chan ackRequest)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		c.runAcker(acks)
+	}()
+
+	acks <- ackRequest{msg: &Message{ReceiptHandle: "rh-0"}, logger: zap.NewNop()}
+	deadline := time.Now().Add(time.Second)
+	for !client.wasDeleted("rh-0") {
+		if time.Now().After(deadline) {
+			t.Fatal("single ack not flushed after AckFlushInterval")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	close(acks)
+	<-done
+	if len(client.batches) != 1 {
+		t.Errorf("DeleteMessageBatch calls = %d, want 1", len(client.batches))
+	}
+}
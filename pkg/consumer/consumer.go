@@ -0,0 +1,561 @@
+// Package consumer consumes EventBridge events delivered to an SQS queue
+// (EventBridge rule → SQS target) and dispatches them to handlers registered
+// by detail-type.
+//
+// Every handler returns a Result that decides the message's fate:
+//
+//	Ack()                 processed, delete the message
+//	Nack(err)             transient failure, retry per the handler's RetryPolicy
+//	                      and then return the message to the queue
+//	NackAfter(err, d)     return the message to the queue, visible again after d
+//	Reject(err)           permanent failure, never retried: dead-lettered
+//
+// Messages that are not EventBridge envelopes, whose payment event detail
+// fails schema validation, or whose detail-type has no registered handler are
+// rejected before reaching a handler. With
+// Config.DeadLetterQueueURL set, dead-lettered messages are sent there with
+// the error attached and deleted; otherwise they stay in the queue until its
+// SQS redrive policy moves them to the DLQ. Failed messages are never deleted
+// silently.
+//
+// While a handler runs, the consumer keeps extending the message's visibility
+// timeout so long handlers are not redelivered to another worker.
+//
+// Config.Pollers goroutines long-poll the queue and hand messages to at most
+// Config.Concurrency concurrent handlers. A poller only receives as many
+// messages as there are idle handlers, so received messages never wait for a
+// handler while their visibility timeout runs out. Acknowledged messages are
+// deleted in batches with DeleteMessageBatch.
+//
+// With Config.Dedup set, a message whose dedup key (Config.DedupKey or
+// WithDedupKey) was already acknowledged is dropped without running the
+// handler, and a duplicate arriving while the first copy is still being
+// handled is returned to the queue until that handler finishes.
+package consumer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"go.uber.org/zap"
+
+	"github.com/traffic-tacos/payment-sim-api/pkg/paymentevent"
+)
+
+// ErrNoHandler rejects messages of a detail-type no handler is registered for.
+var ErrNoHandler = errors.New("no handler registered for detail-type")
+
+// SQSAPI is the part of *sqs.Client the consumer uses.
+type SQSAPI interface {
+	ReceiveMessage(ctx context.Context, params *sqs.ReceiveMessageInput, optFns ...func(*sqs.Options)) (*sqs.ReceiveMessageOutput, error)
+	DeleteMessageBatch(ctx context.Context, params *sqs.DeleteMessageBatchInput, optFns ...func(*sqs.Options)) (*sqs.DeleteMessageBatchOutput, error)
+	ChangeMessageVisibility(ctx context.Context, params *sqs.ChangeMessageVisibilityInput, optFns ...func(*sqs.Options)) (*sqs.ChangeMessageVisibilityOutput, error)
+	SendMessage(ctx context.Context, params *sqs.SendMessageInput, optFns ...func(*sqs.Options)) (*sqs.SendMessageOutput, error)
+	GetQueueAttributes(ctx context.Context, params *sqs.GetQueueAttributesInput, optFns ...func(*sqs.Options)) (*sqs.GetQueueAttributesOutput, error)
+}
+
+// Config configures a Consumer. Zero values take the defaults below.
+type Config struct {
+	QueueURL          string
+	MaxMessages       int32         // ReceiveMessage batch size, 1~10 (default 10)
+	WaitTime          time.Duration // long polling wait, up to 20s (default 20s)
+	VisibilityTimeout time.Duration // default 60s, extended while a handler runs
+	Pollers           int           // concurrent ReceiveMessage loops (default 1)
+	Concurrency       int           // concurrent handlers (default 10)
+	// AckFlushInterval bounds how long an ack waits for a full
+	// DeleteMessageBatch of 10 (default 100ms).
+	AckFlushInterval time.Duration
+	// DeadLetterQueueURL receives rejected messages (empty = leave them for
+	// the queue's SQS redrive policy).
+	DeadLetterQueueURL string
+	// Retry is the policy of handlers registered without WithRetry
+	// (zero value = DefaultRetryPolicy).
+	Retry RetryPolicy
+	// Dedup stores the keys of acknowledged messages (nil = no dedup).
+	Dedup DedupStore
+	// DedupKey is the key of handlers registered without WithDedupKey
+	// (default EventIDKey).
+	DedupKey KeyFunc
+}
+
+const (
+	maxVisibilityTimeout = 12 * time.Hour // SQS 상한
+	maxBatchSize         = 10             // ReceiveMessage/DeleteMessageBatch 상한
+	receiveErrorBackoff  = time.Second
+	// 같은 key의 메시지가 처리 중이면 이 시간 뒤 다시 보이게 한다
+	inFlightDuplicateDelay = 5 * time.Second
+)
+
+func (c Config) withDefaults() Config {
+	if c.MaxMessages <= 0 || c.MaxMessages > maxBatchSize {
+		c.MaxMessages = maxBatchSize
+	}
+	if c.WaitTime <= 0 || c.WaitTime > 20*time.Second {
+		c.WaitTime = 20 * time.Second
+	}
+	if c.VisibilityTimeout <= 0 {
+		c.VisibilityTimeout = 60 * time.Second
+	}
+	if c.VisibilityTimeout > maxVisibilityTimeout {
+		c.VisibilityTimeout = maxVisibilityTimeout
+	}
+	if c.Pollers <= 0 {
+		c.Pollers = 1
+	}
+	if c.Concurrency <= 0 {
+		c.Concurrency = 10
+	}
+	if c.AckFlushInterval <= 0 {
+		c.AckFlushInterval = 100 * time.Millisecond
+	}
+	if c.DedupKey == nil {
+		c.DedupKey = EventIDKey
+	}
+	if c.Retry == (RetryPolicy{}) {
+		c.Retry = DefaultRetryPolicy
+	}
+	c.Retry = c.Retry.withDefaults()
+	return c
+}
+
+// Option configures a handler registration.
+type Option func(*route)
+
+// WithRetry sets the retry policy of a handler.
+func WithRetry(policy RetryPolicy) Option {
+	return func(r *route) {
+		r.policy = policy.withDefaults()
+	}
+}
+
+// WithDedupKey sets how a handler's messages are deduplicated when
+// Config.Dedup is set; nil turns dedup off for the handler.
+func WithDedupKey(key KeyFunc) Option {
+	return func(r *route) {
+		r.dedupKey = key
+	}
+}
+
+type route struct {
+	detailType string
+	handler    Handler
+	policy     RetryPolicy
+	dedupKey   KeyFunc
+}
+
+// Consumer polls an SQS queue and dispatches messages to handlers.
+type Consumer struct {
+	logger *zap.Logger
+	config Config
+	sqs    SQSAPI
+
+	mu     sync.RWMutex
+	routes map[string]*route
+
+	inFlightMu sync.Mutex
+	inFlight   map[string]bool // 처리 중인 dedup key
+}
+
+func New(logger *zap.Logger, config Config, client SQSAPI) *Consumer {
+	return &Consumer{
+		logger:   logger,
+		config:   config.withDefaults(),
+		sqs:      client,
+		routes:   make(map[string]*route),
+		inFlight: make(map[string]bool),
+	}
+}
+
+// Handle registers handler for messages of an EventBridge detail-type.
+// Registering the same detail-type twice panics, like http.ServeMux.
+func (c *Consumer) Handle(detailType string, handler Handler, opts ...Option) {
+	if detailType == "" {
+		panic("consumer: empty detail-type")
+	}
+	if handler == nil {
+		panic("consumer: nil handler for " + detailType)
+	}
+
+	r := &route{detailType: detailType, handler: handler, policy: c.config.Retry, dedupKey: c.config.DedupKey}
+	for _, opt := range opts {
+		opt(r)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, ok := c.routes[detailType]; ok {
+		panic("consumer: multiple registrations for " + detailType)
+	}
+	c.routes[detailType] = r
+}
+
+// HandlePaymentEvent registers handler for a payment-sim-api event type. The
+// detail is decoded and validated against the paymentevent schema; details
+// that fail validation are rejected without calling handler.
+func (c *Consumer) HandlePaymentEvent(eventType paymentevent.EventType, handler PaymentHandlerFunc, opts ...Option) {
+	detailType, ok := eventType.DetailType()
+	if !ok {
+		panic(fmt.Sprintf("consumer: unknown payment event type %s", eventType))
+	}
+	c.Handle(detailType, paymentHandler(eventType, handler), opts...)
+}
+
+func (c *Consumer) route(detailType string) *route {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.routes[detailType]
+}
+
+// Run polls until ctx is cancelled, then waits for running handlers and
+// pending acks. Handlers see the cancelled ctx; messages received but not yet
+// handled are released back to the queue.
+func (c *Consumer) Run(ctx context.Context) error {
+	if c.config.QueueURL == "" {
+		return errors.New("consumer: queue URL is required")
+	}
+
+	c.logger.Info("Starting SQS consumer",
+		zap.String("queue_url", c.config.QueueURL),
+		zap.Int("pollers", c.config.Pollers),
+		zap.Int("concurrency", c.config.Concurrency),
+		zap.Int32("max_messages", c.config.MaxMessages),
+		zap.Duration("visibility_timeout", c.config.VisibilityTimeout))
+	c.checkRedrivePolicy(ctx)
+
+	acks := make(chan ackRequest, c.config.Concurrency)
+	ackerDone := make(chan struct{})
+	go func() {
+		defer close(ackerDone)
+		c.runAcker(acks)
+	}()
+
+	// slots는 handler 동시 실행 수를 제한한다 (빈 slot만큼만 수신)
+	slots := make(chan struct{}, c.config.Concurrency)
+	var pollers, handlers sync.WaitGroup
+	for i := 0; i < c.config.Pollers; i++ {
+		pollers.Add(1)
+		go func() {
+			defer pollers.Done()
+			c.poll(ctx, slots, acks, &handlers)
+		}()
+	}
+
+	pollers.Wait()
+	handlers.Wait()
+	close(acks)
+	<-ackerDone
+
+	c.logger.Info("Context cancelled, consumer stopped")
+	return nil
+}
+
+func (c *Consumer) poll(ctx context.Context, slots chan struct{}, acks chan<- ackRequest, handlers *sync.WaitGroup) {
+	for {
+		n := acquire(ctx, slots, int(c.config.MaxMessages))
+		if n == 0 {
+			return
+		}
+
+		messages, err := c.receive(ctx, n)
+		release(slots, n-len(messages))
+		if err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			c.logger.Error("Failed to receive messages from SQS", zap.Error(err))
+			// 자격 증명/네트워크 오류가 계속될 때 busy loop 방지
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(receiveErrorBackoff):
+			}
+			continue
+		}
+
+		for _, message := range messages {
+			handlers.Add(1)
+			go func() {
+				defer handlers.Done()
+				defer release(slots, 1)
+				c.process(ctx, message, acks)
+			}()
+		}
+	}
+}
+
+// acquire blocks for one free handler slot, then takes up to max-1 more
+// without waiting. It returns 0 when ctx is cancelled.
+func acquire(ctx context.Context, slots chan struct{}, max int) int {
+	select {
+	case <-ctx.Done():
+		return 0
+	case slots <- struct{}{}:
+	}
+	n := 1
+	for n < max {
+		select {
+		case slots <- struct{}{}:
+			n++
+		default:
+			return n
+		}
+	}
+	return n
+}
+
+func release(slots chan struct{}, n int) {
+	for i := 0; i < n; i++ {
+		<-slots
+	}
+}
+
+func (c *Consumer) receive(ctx context.Context, max int) ([]types.Message, error) {
+	result, err := c.sqs.ReceiveMessage(ctx, &sqs.ReceiveMessageInput{
+		QueueUrl:            aws.String(c.config.QueueURL),
+		MaxNumberOfMessages: int32(max),
+		WaitTimeSeconds:     int32(c.config.WaitTime / time.Second),
+		VisibilityTimeout:   seconds(c.config.VisibilityTimeout),
+		MessageSystemAttributeNames: []types.MessageSystemAttributeName{
+			types.MessageSystemAttributeNameApproximateReceiveCount,
+			types.MessageSystemAttributeNameSentTimestamp,
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(result.Messages) > 0 {
+		messagesReceivedTotal.Add(float64(len(result.Messages)))
+		c.logger.Debug("Polled SQS", zap.Int("message_count", len(result.Messages)))
+	}
+	return result.Messages, nil
+}
+
+func (c *Consumer) process(ctx context.Context, raw types.Message, acks chan<- ackRequest) {
+	msg, err := newMessage(raw)
+	logger := c.logger.With(
+		zap.String("message_id", msg.ID),
+		zap.String("detail_type", msg.DetailType()),
+		zap.Int("receive_count", msg.ReceiveCount))
+
+	if err != nil {
+		c.settle(ctx, logger, msg, otherDetailType, c.config.Retry, Reject(err), acks)
+		return
+	}
+
+	r := c.route(msg.DetailType())
+	if r == nil {
+		// 이 worker가 모르는 이벤트도 삭제하지 않고 dead-letter 처리해 handler 등록 후 다시 보낼 수 있게 한다
+		c.settle(ctx, logger, msg, otherDetailType, c.config.Retry,
+			Reject(fmt.Errorf("%w: %q", ErrNoHandler, msg.DetailType())), acks)
+		return
+	}
+
+	if ctx.Err() != nil {
+		// 종료 중: 처리하지 않고 바로 다시 보이게 해 다른 worker가 가져가도록 한다
+		messagesTotal.WithLabelValues(r.detailType, "released").Inc()
+		if err := c.changeVisibility(context.WithoutCancel(ctx), msg, 0); err != nil {
+			logger.Warn("Failed to release message", zap.Error(err))
+		}
+		return
+	}
+
+	var key string
+	if c.config.Dedup != nil && r.dedupKey != nil {
+		key = r.dedupKey(msg)
+	}
+	if key != "" {
+		if c.config.Dedup.Contains(key) {
+			duplicatesTotal.WithLabelValues(r.detailType, "processed").Inc()
+			logger.Info("Duplicate message dropped", zap.String("dedup_key", key))
+			acks <- ackRequest{msg: msg, logger: logger}
+			return
+		}
+		if !c.claim(key) {
+			duplicatesTotal.WithLabelValues(r.detailType, "in_flight").Inc()
+			logger.Info("Duplicate of a message being handled, returning to queue",
+				zap.String("dedup_key", key))
+			if err := c.changeVisibility(context.WithoutCancel(ctx), msg, inFlightDuplicateDelay); err != nil {
+				logger.Warn("Failed to change message visibility", zap.Error(err))
+			}
+			return
+		}
+		defer c.unclaim(key)
+	}
+
+	if !msg.SentAt.IsZero() {
+		messageLag.Observe(time.Since(msg.SentAt).Seconds())
+	}
+	handlersBusy.Inc()
+	started := time.Now()
+
+	stop := c.keepInvisible(ctx, logger, msg)
+	result := c.invoke(ctx, logger, r, msg)
+	stop()
+
+	handlerDuration.WithLabelValues(r.detailType).Observe(time.Since(started).Seconds())
+	handlersBusy.Dec()
+
+	if key != "" && result.action == actionAck {
+		// 기록에 실패해도 ack는 진행한다 (중복이 오면 handler가 한 번 더 실행될 뿐)
+		if err := c.config.Dedup.Add(key); err != nil {
+			logger.Warn("Failed to record dedup key", zap.String("dedup_key", key), zap.Error(err))
+		}
+	}
+
+	c.settle(ctx, logger, msg, r.detailType, r.policy, result, acks)
+}
+
+// invoke calls the handler, retrying Nack results in-process per the policy.
+func (c *Consumer) invoke(ctx context.Context, logger *zap.Logger, r *route, msg *Message) Result {
+	for attempt := 1; ; attempt++ {
+		msg.Attempt = attempt
+		result := call(ctx, r.handler, msg)
+		if result.action != actionNack || result.requeue > 0 || attempt >= r.policy.MaxAttempts {
+			return result
+		}
+
+		wait := r.policy.Backoff(attempt)
+		logger.Warn("Handler failed, retrying",
+			zap.Int("attempt", attempt),
+			zap.Duration("retry_in", wait),
+			zap.Error(result.err))
+
+		select {
+		case <-ctx.Done():
+			return result
+		case <-time.After(wait):
+		}
+	}
+}
+
+func call(ctx context.Context, handler Handler, msg *Message) (result Result) {
+	defer func() {
+		if p := recover(); p != nil {
+			result = Nack(fmt.Errorf("handler panic: %v", p))
+		}
+	}()
+	return handler.Handle(ctx, msg)
+}
+
+// claim marks key as being handled. It returns false if another handler
+// already holds it.
+func (c *Consumer) claim(key string) bool {
+	c.inFlightMu.Lock()
+	defer c.inFlightMu.Unlock()
+	if c.inFlight[key] {
+		return false
+	}
+	c.inFlight[key] = true
+	return true
+}
+
+func (c *Consumer) unclaim(key string) {
+	c.inFlightMu.Lock()
+	delete(c.inFlight, key)
+	c.inFlightMu.Unlock()
+}
+
+// otherDetailType labels metrics of messages without a registered handler,
+// so arbitrary detail-types do not create new series.
+const otherDetailType = "other"
+
+// settle applies the result to the queue. Shutdown does not cancel it, so a
+// finished handler's ack is not lost.
+func (c *Consumer) settle(ctx context.Context, logger *zap.Logger, msg *Message, detailType string, policy RetryPolicy, result Result, acks chan<- ackRequest) {
+	ctx = context.WithoutCancel(ctx)
+	messagesTotal.WithLabelValues(detailType, result.action.String()).Inc()
+
+	switch result.action {
+	case actionAck:
+		logger.Debug("Message acknowledged", zap.Int("attempts", msg.Attempt))
+		acks <- ackRequest{msg: msg, logger: logger}
+
+	case actionReject:
+		c.deadLetter(ctx, logger, msg, policy, result.err, acks)
+
+	case actionNack:
+		if policy.MaxReceives > 0 && msg.ReceiveCount >= policy.MaxReceives {
+			c.deadLetter(ctx, logger, msg, policy,
+				fmt.Errorf("giving up after %d receives: %w", msg.ReceiveCount, result.err), acks)
+			return
+		}
+
+		delay := result.requeue
+		if delay == 0 {
+			delay = policy.RequeueBackoff(msg.ReceiveCount)
+		}
+		logger.Warn("Message not acknowledged, returning to queue",
+			zap.Int("attempts", msg.Attempt),
+			zap.Duration("visible_in", delay),
+			zap.Error(result.err))
+		if err := c.changeVisibility(ctx, msg, delay); err != nil {
+			// 실패해도 visibility timeout이 지나면 다시 전달된다
+			logger.Error("Failed to change message visibility", zap.Error(err))
+		}
+	}
+}
+
+// keepInvisible extends the visibility timeout every half timeout until the
+// returned stop function is called.
+func (c *Consumer) keepInvisible(ctx context.Context, logger *zap.Logger, msg *Message) (stop func()) {
+	// 종료 중에도 Run은 handler를 기다리므로 ctx가 아니라 stop이 불릴 때까지 연장한다
+	ctx = context.WithoutCancel(ctx)
+	interval := c.config.VisibilityTimeout / 2
+	if interval < time.Second {
+		interval = time.Second
+	}
+
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		started := time.Now()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+			}
+			if err := c.changeVisibility(ctx, msg, c.config.VisibilityTimeout); err != nil {
+				logger.Warn("Failed to extend message visibility", zap.Error(err))
+				continue
+			}
+			logger.Debug("Extended message visibility",
+				zap.Duration("running_for", time.Since(started)))
+		}
+	}()
+
+	return func() {
+		close(done)
+		wg.Wait()
+	}
+}
+
+func (c *Consumer) changeVisibility(ctx context.Context, msg *Message, timeout time.Duration) error {
+	_, err := c.sqs.ChangeMessageVisibility(ctx, &sqs.ChangeMessageVisibilityInput{
+		QueueUrl:          aws.String(c.config.QueueURL),
+		ReceiptHandle:     aws.String(msg.ReceiptHandle),
+		VisibilityTimeout: seconds(timeout),
+	})
+	return err
+}
+
+// seconds rounds d up to whole seconds within the SQS visibility range.
+func seconds(d time.Duration) int32 {
+	if d <= 0 {
+		return 0
+	}
+	if d > maxVisibilityTimeout {
+		d = maxVisibilityTimeout
+	}
+	return int32((d + time.Second - 1) / time.Second)
+}
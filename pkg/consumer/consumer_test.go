@@ -0,0 +1,287 @@
+package consumer
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"go.uber.org/zap"
+)
+
+// fakeSQS records what the consumer does to each message, keyed by receipt
+// handle.
+type fakeSQS struct {
+	mu         sync.Mutex
+	deleted    []string
+	batches    [][]string
+	visibility map[string][]int32
+	sent       []*sqs.SendMessageInput
+
+	sendErr        error
+	deleteErr      error
+	redrivePolicy  string          // GetQueueAttributes가 돌려줄 RedrivePolicy
+	deleteFailures map[string]bool // DeleteMessageBatch가 실패로 보고할 receipt handle
+}
+
+func newFakeSQS() *fakeSQS {
+	return &fakeSQS{visibility: make(map[string][]int32), deleteFailures: make(map[string]bool)}
+}
+
+func (f *fakeSQS) ReceiveMessage(ctx context.Context, params *sqs.ReceiveMessageInput, optFns ...func(*sqs.Options)) (*sqs.ReceiveMessageOutput, error) {
+	return &sqs.ReceiveMessageOutput{}, nil
+}
+
+func (f *fakeSQS) DeleteMessageBatch(ctx context.Context, params *sqs.DeleteMessageBatchInput, optFns ...func(*sqs.Options)) (*sqs.DeleteMessageBatchOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	var handles []string
+	for _, entry := range params.Entries {
+		handles = append(handles, aws.ToString(entry.ReceiptHandle))
+	}
+	f.batches = append(f.batches, handles)
+	if f.deleteErr != nil {
+		return nil, f.deleteErr
+	}
+
+	output := &sqs.DeleteMessageBatchOutput{}
+	for _, entry := range params.Entries {
+		if f.deleteFailures[aws.ToString(entry.ReceiptHandle)] {
+			output.Failed = append(output.Failed, types.BatchResultErrorEntry{
+				Id:          entry.Id,
+				Code:        aws.String("ReceiptHandleIsInvalid"),
+				Message:     aws.String("receipt handle expired"),
+				SenderFault: true,
+			})
+			continue
+		}
+		f.deleted = append(f.deleted, aws.ToString(entry.ReceiptHandle))
+		output.Successful = append(output.Successful, types.DeleteMessageBatchResultEntry{Id: entry.Id})
+	}
+	return output, nil
+}
+
+func (f *fakeSQS) ChangeMessageVisibility(ctx context.Context, params *sqs.ChangeMessageVisibilityInput, optFns ...func(*sqs.Options)) (*sqs.ChangeMessageVisibilityOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	handle := aws.ToString(params.ReceiptHandle)
+	f.visibility[handle] = append(f.visibility[handle], params.VisibilityTimeout)
+	return &sqs.ChangeMessageVisibilityOutput{}, nil
+}
+
+func (f *fakeSQS) SendMessage(ctx context.Context, params *sqs.SendMessageInput, optFns ...func(*sqs.Options)) (*sqs.SendMessageOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.sendErr != nil {
+		return nil, f.sendErr
+	}
+	f.sent = append(f.sent, params)
+	return &sqs.SendMessageOutput{MessageId: aws.String("dlq-" + strconv.Itoa(len(f.sent)))}, nil
+}
+
+func (f *fakeSQS) GetQueueAttributes(ctx context.Context, params *sqs.GetQueueAttributesInput, optFns ...func(*sqs.Options)) (*sqs.GetQueueAttributesOutput, error) {
+	attributes := make(map[string]string)
+	if f.redrivePolicy != "" {
+		attributes[string(types.QueueAttributeNameRedrivePolicy)] = f.redrivePolicy
+	}
+	return &sqs.GetQueueAttributesOutput{Attributes: attributes}, nil
+}
+
+func (f *fakeSQS) wasDeleted(handle string) bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, deleted := range f.deleted {
+		if deleted == handle {
+			return true
+		}
+	}
+	return false
+}
+
+const testDetailType = "Test Event"
+
+// testRetry retries without waiting and without jitter, so requeue delays are
+// exact: 30s, 60s, 120s, ... up to 15m.
+var testRetry = RetryPolicy{
+	MaxAttempts:     3,
+	Base:            time.Millisecond,
+	Max:             time.Millisecond,
+	RequeueDelay:    30 * time.Second,
+	MaxRequeueDelay: 15 * time.Minute,
+}
+
+func newTestConsumer(config Config, client *fakeSQS) *Consumer {
+	config.QueueURL = "https://sqs.test/queue"
+	if config.Retry == (RetryPolicy{}) {
+		config.Retry = testRetry
+	}
+	return New(zap.NewNop(), config, client)
+}
+
+// testMessage builds an SQS message with an EventBridge envelope of
+// testDetailType; its receipt handle is "rh-" + id.
+func testMessage(id string, receiveCount int) types.Message {
+	body := `{"id":"evt-` + id + `","detail-type":"` + testDetailType + `","source":"test","time":"2026-01-01T00:00:00Z","detail":{}}`
+	return rawMessage(id, body, receiveCount)
+}
+
+func rawMessage(id, body string, receiveCount int) types.Message {
+	return types.Message{
+		MessageId:     aws.String(id),
+		ReceiptHandle: aws.String("rh-" + id),
+		Body:          aws.String(body),
+		Attributes: map[string]string{
+			string(types.MessageSystemAttributeNameApproximateReceiveCount): strconv.Itoa(receiveCount),
+		},
+	}
+}
+
+// consume processes messages one by one like a poller would, then flushes
+// the acker, so every SQS call has been made when it returns.
+func consume(c *Consumer, messages ...types.Message) {
+	acks := make(chan ackRequest, len(messages))
+	for _, message := range messages {
+		c.process(context.Background(), message, acks)
+	}
+	close(acks)
+	c.runAcker(acks)
+}
+
+func TestResultMapping(t *testing.T) {
+	tests := []struct {
+		name           string
+		detailType     string // "" = testDetailType
+		receiveCount   int    // 0 = first delivery
+		results        []Result
+		panics         bool
+		wantCalls      int
+		wantDeleted    bool
+		wantVisibility []int32
+	}{
+		{
+			name:        "ack deletes",
+			results:     []Result{Ack()},
+			wantCalls:   1,
+			wantDeleted: true,
+		},
+		{
+			name:        "ack on retry deletes",
+			results:     []Result{Nack(errors.New("timeout")), Ack()},
+			wantCalls:   2,
+			wantDeleted: true,
+		},
+		{
+			name:           "nack retries then returns to the queue",
+			results:        []Result{Nack(errors.New("timeout"))},
+			wantCalls:      3,
+			wantVisibility: []int32{30},
+		},
+		{
+			name:           "nack backs off by receive count",
+			receiveCount:   3,
+			results:        []Result{Nack(errors.New("timeout"))},
+			wantCalls:      3,
+			wantVisibility: []int32{120},
+		},
+		{
+			name:           "nack backoff is capped",
+			receiveCount:   10,
+			results:        []Result{Nack(errors.New("timeout"))},
+			wantCalls:      3,
+			wantVisibility: []int32{900},
+		},
+		{
+			name:           "nack after skips retries",
+			results:        []Result{NackAfter(errors.New("locked"), 10*time.Second)},
+			wantCalls:      1,
+			wantVisibility: []int32{10},
+		},
+		{
+			name:           "reject is not retried",
+			results:        []Result{Reject(errors.New("bad reservation"))},
+			wantCalls:      1,
+			wantVisibility: []int32{30},
+		},
+		{
+			name:           "panic is a nack",
+			panics:         true,
+			wantCalls:      3,
+			wantVisibility: []int32{30},
+		},
+		{
+			name:           "unhandled detail-type is left for redrive",
+			detailType:     "Other Event",
+			results:        []Result{Ack()},
+			wantVisibility: []int32{30},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := newFakeSQS()
+			c := newTestConsumer(Config{}, client)
+
+			detailType := tt.detailType
+			if detailType == "" {
+				detailType = testDetailType
+			}
+			calls := 0
+			c.Handle(detailType, HandlerFunc(func(ctx context.Context, msg *Message) Result {
+				calls++
+				if msg.Attempt != calls {
+					t.Errorf("Attempt = %d on call %d", msg.Attempt, calls)
+				}
+				if tt.panics {
+					panic("boom")
+				}
+				return tt.results[min(calls, len(tt.results))-1]
+			}))
+
+			consume(c, testMessage("m-1", max(tt.receiveCount, 1)))
+
+			if calls != tt.wantCalls {
+				t.Errorf("handler calls = %d, want %d", calls, tt.wantCalls)
+			}
+			if got := client.wasDeleted("rh-m-1"); got != tt.wantDeleted {
+				t.Errorf("deleted = %v, want %v", got, tt.wantDeleted)
+			}
+			if got := client.visibility["rh-m-1"]; !slices.Equal(got, tt.wantVisibility) {
+				t.Errorf("visibility changes = %v, want %v", got, tt.wantVisibility)
+			}
+			if len(client.sent) != 0 {
+				t.Errorf("sent %d messages to a DLQ without DeadLetterQueueURL", len(client.sent))
+			}
+		})
+	}
+}
+
+func TestVisibilityExtendedWhileHandlerFinishesOnShutdown(t *testing.T) {
+	client := newFakeSQS()
+	c := newTestConsumer(Config{VisibilityTimeout: 2 * time.Second}, client)
+	ctx, cancel := context.WithCancel(context.Background())
+	c.Handle(testDetailType, HandlerFunc(func(ctx context.Context, msg *Message) Result {
+		// handler 실행 중에 종료가 시작되어도 진행 중인 작업은 마친다
+		cancel()
+		time.Sleep(1500 * time.Millisecond)
+		return Ack()
+	}))
+
+	acks := make(chan ackRequest, 1)
+	c.process(ctx, testMessage("m-1", 1), acks)
+	close(acks)
+	c.runAcker(acks)
+
+	if got := client.visibility["rh-m-1"]; !slices.Equal(got, []int32{2}) {
+		t.Errorf("visibility changes = %v, want one extension to 2s", got)
+	}
+	if !client.wasDeleted("rh-m-1") {
+		t.Error("message was not deleted after the handler finished")
+	}
+}
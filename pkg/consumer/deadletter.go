@@ -0,0 +1,118 @@
+package consumer
+
+import (
+	"context"
+	"strconv"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"go.uber.org/zap"
+)
+
+// Dead-letter message attributes. The body is the original message body, so
+// tools that read EventBridge envelopes from the DLQ still work.
+const (
+	AttributeKind          = "kind"
+	AttributeError         = "error"
+	AttributeSourceQueue   = "source_queue_url"
+	AttributeSourceMessage = "source_message_id"
+	AttributeDetailType    = "detail_type"
+	AttributeReceiveCount  = "receive_count"
+
+	// KindConsumer is the kind attribute of messages dead-lettered by a Consumer.
+	KindConsumer = "consumer"
+)
+
+// maxErrorAttribute keeps the error attribute well inside the SQS message size.
+const maxErrorAttribute = 4096
+
+// deadLetter forwards msg to the DLQ and deletes it, or leaves it in the
+// queue for SQS redrive when no DLQ is configured or forwarding fails.
+func (c *Consumer) deadLetter(ctx context.Context, logger *zap.Logger, msg *Message, policy RetryPolicy, cause error, acks chan<- ackRequest) {
+	if c.config.DeadLetterQueueURL == "" {
+		delay := policy.RequeueBackoff(msg.ReceiveCount)
+		deadLetterTotal.WithLabelValues("left_for_redrive").Inc()
+		logger.Error("Poison message left in queue for SQS redrive",
+			zap.Duration("visible_in", delay),
+			zap.Error(cause))
+		if err := c.changeVisibility(ctx, msg, delay); err != nil {
+			logger.Error("Failed to change message visibility", zap.Error(err))
+		}
+		return
+	}
+
+	if err := c.forward(ctx, msg, cause); err != nil {
+		// 삭제하지 않으므로 visibility timeout 뒤 다시 전달된다
+		deadLetterTotal.WithLabelValues("failed").Inc()
+		logger.Error("Failed to forward message to DLQ, leaving it in the queue",
+			zap.NamedError("cause", cause),
+			zap.Error(err))
+		return
+	}
+
+	deadLetterTotal.WithLabelValues("forwarded").Inc()
+	logger.Error("Message forwarded to DLQ", zap.Error(cause))
+	acks <- ackRequest{msg: msg, logger: logger}
+}
+
+// checkRedrivePolicy warns when poison messages have nowhere to go: without a
+// DLQ URL they are left for the queue's RedrivePolicy, and a queue without one
+// redelivers them until the retention period ends.
+func (c *Consumer) checkRedrivePolicy(ctx context.Context) {
+	if c.config.DeadLetterQueueURL != "" {
+		return
+	}
+
+	output, err := c.sqs.GetQueueAttributes(ctx, &sqs.GetQueueAttributesInput{
+		QueueUrl:       aws.String(c.config.QueueURL),
+		AttributeNames: []types.QueueAttributeName{types.QueueAttributeNameRedrivePolicy},
+	})
+	if err != nil {
+		c.logger.Warn("Failed to read queue redrive policy",
+			zap.String("queue_url", c.config.QueueURL),
+			zap.Error(err))
+		return
+	}
+	if output.Attributes[string(types.QueueAttributeNameRedrivePolicy)] == "" {
+		c.logger.Warn("Queue has no RedrivePolicy and no DLQ is configured; poison messages are redelivered until they expire",
+			zap.String("queue_url", c.config.QueueURL))
+	}
+}
+
+func (c *Consumer) forward(ctx context.Context, msg *Message, cause error) error {
+	reason := cause.Error()
+	if len(reason) > maxErrorAttribute {
+		reason = reason[:maxErrorAttribute]
+	}
+
+	attributes := map[string]types.MessageAttributeValue{
+		AttributeKind:         stringAttribute(KindConsumer),
+		AttributeError:        stringAttribute(reason),
+		AttributeSourceQueue:  stringAttribute(c.config.QueueURL),
+		AttributeReceiveCount: {DataType: aws.String("Number"), StringValue: aws.String(strconv.Itoa(msg.ReceiveCount))},
+	}
+	if msg.ID != "" {
+		attributes[AttributeSourceMessage] = stringAttribute(msg.ID)
+	}
+	if msg.DetailType() != "" {
+		attributes[AttributeDetailType] = stringAttribute(msg.DetailType())
+	}
+
+	body := string(msg.Body)
+	if body == "" {
+		// SQS는 빈 본문을 허용하지 않는다
+		body = "{}"
+	}
+
+	_, err := c.sqs.SendMessage(ctx, &sqs.SendMessageInput{
+		QueueUrl:          aws.String(c.config.DeadLetterQueueURL),
+		MessageBody:       aws.String(body),
+		MessageAttributes: attributes,
+	})
+	return err
+}
+
+func stringAttribute(value string) types.MessageAttributeValue {
+	return types.MessageAttributeValue{DataType: aws.String("String"), StringValue: aws.String(value)}
+}
@@ -0,0 +1,170 @@
+package consumer
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestDeadLetter(t *testing.T) {
+	const dlqURL = "https://sqs.test/worker-dlq"
+
+	tests := []struct {
+		name           string
+		dlq            string
+		maxReceives    int
+		sendErr        error
+		receiveCount   int    // 0 = first delivery
+		body           string // "" = an EventBridge envelope of testDetailType
+		result         Result
+		wantSent       bool
+		wantError      string // error attribute
+		wantDeleted    bool
+		wantVisibility []int32
+	}{
+		{
+			name:        "reject forwarded",
+			dlq:         dlqURL,
+			result:      Reject(errors.New("bad reservation")),
+			wantSent:    true,
+			wantError:   "bad reservation",
+			wantDeleted: true,
+		},
+		{
+			name:        "unparseable body forwarded as is",
+			dlq:         dlqURL,
+			body:        "not json",
+			wantSent:    true,
+			wantError:   "invalid EventBridge envelope",
+			wantDeleted: true,
+		},
+		{
+			name:        "unhandled detail-type forwarded",
+			dlq:         dlqURL,
+			body:        `{"id":"evt-m-1","detail-type":"Payment Disputed","source":"test","time":"2026-01-01T00:00:00Z","detail":{}}`,
+			wantSent:    true,
+			wantError:   `no handler registered for detail-type: "Payment Disputed"`,
+			wantDeleted: true,
+		},
+		{
+			name:         "nack after max receives",
+			dlq:          dlqURL,
+			maxReceives:  3,
+			receiveCount: 3,
+			result:       Nack(errors.New("reservation service down")),
+			wantSent:     true,
+			wantError:    "giving up after 3 receives: reservation service down",
+			wantDeleted:  true,
+		},
+		{
+			name:           "reject without DLQ stays for redrive",
+			result:         Reject(errors.New("bad reservation")),
+			wantVisibility: []int32{30},
+		},
+		{
+			name:           "unparseable body without DLQ stays for redrive",
+			receiveCount:   2,
+			body:           "not json",
+			wantVisibility: []int32{60},
+		},
+		{
+			name:    "forward failure stays in queue",
+			dlq:     dlqURL,
+			sendErr: errors.New("sqs unavailable"),
+			result:  Reject(errors.New("bad reservation")),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := newFakeSQS()
+			client.sendErr = tt.sendErr
+			retry := testRetry
+			retry.MaxReceives = tt.maxReceives
+			c := newTestConsumer(Config{DeadLetterQueueURL: tt.dlq, Retry: retry}, client)
+			c.Handle(testDetailType, HandlerFunc(func(ctx context.Context, msg *Message) Result {
+				return tt.result
+			}))
+
+			receiveCount := max(tt.receiveCount, 1)
+			message := testMessage("m-1", receiveCount)
+			if tt.body != "" {
+				message = rawMessage("m-1", tt.body, receiveCount)
+			}
+			consume(c, message)
+
+			if got := client.wasDeleted("rh-m-1"); got != tt.wantDeleted {
+				t.Errorf("deleted = %v, want %v", got, tt.wantDeleted)
+			}
+			if got := client.visibility["rh-m-1"]; !slices.Equal(got, tt.wantVisibility) {
+				t.Errorf("visibility changes = %v, want %v", got, tt.wantVisibility)
+			}
+			if !tt.wantSent {
+				if len(client.sent) != 0 {
+					t.Fatalf("sent %d messages to the DLQ, want none", len(client.sent))
+				}
+				return
+			}
+
+			if len(client.sent) != 1 {
+				t.Fatalf("sent %d messages to the DLQ, want 1", len(client.sent))
+			}
+			sent := client.sent[0]
+			if aws.ToString(sent.QueueUrl) != dlqURL {
+				t.Errorf("sent to %s, want %s", aws.ToString(sent.QueueUrl), dlqURL)
+			}
+			if aws.ToString(sent.MessageBody) != aws.ToString(message.Body) {
+				t.Errorf("body = %s, want the original body", aws.ToString(sent.MessageBody))
+			}
+			attribute := func(name string) string {
+				return aws.ToString(sent.MessageAttributes[name].StringValue)
+			}
+			if attribute(AttributeKind) != KindConsumer {
+				t.Errorf("kind = %q, want %q", attribute(AttributeKind), KindConsumer)
+			}
+			if !strings.Contains(attribute(AttributeError), tt.wantError) {
+				t.Errorf("error = %q, want it to contain %q", attribute(AttributeError), tt.wantError)
+			}
+			if attribute(AttributeSourceQueue) != c.config.QueueURL || attribute(AttributeSourceMessage) != "m-1" {
+				t.Errorf("source = %s/%s, want %s/m-1", attribute(AttributeSourceQueue), attribute(AttributeSourceMessage), c.config.QueueURL)
+			}
+		})
+	}
+}
+
+func TestCheckRedrivePolicy(t *testing.T) {
+	const redrivePolicy = `{"deadLetterTargetArn":"arn:aws:sqs:ap-northeast-2:000000000000:dlq","maxReceiveCount":"5"}`
+
+	tests := []struct {
+		name          string
+		dlq           string
+		redrivePolicy string
+		wantWarning   bool
+	}{
+		{name: "no DLQ and no redrive policy", wantWarning: true},
+		{name: "redrive policy", redrivePolicy: redrivePolicy},
+		{name: "DLQ URL", dlq: "https://sqs.test/worker-dlq"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := newFakeSQS()
+			client.redrivePolicy = tt.redrivePolicy
+			core, logs := observer.New(zapcore.WarnLevel)
+			c := newTestConsumer(Config{DeadLetterQueueURL: tt.dlq}, client)
+			c.logger = zap.New(core)
+
+			c.checkRedrivePolicy(context.Background())
+			if got := logs.Len() > 0; got != tt.wantWarning {
+				t.Errorf("warned = %v, want %v", got, tt.wantWarning)
+			}
+		})
+	}
+}
@@ -0,0 +1,139 @@
+package consumer
+
+import (
+	"container/list"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/traffic-tacos/payment-sim-api/pkg/paymentevent"
+)
+
+// DedupStore는 처리한 메시지의 key를 기억한다. SQS는 at-least-once이고
+// publisher도 이벤트를 두 번 발행할 수 있으므로, dedup key로 등록한 handler는
+// store의 TTL 안에서 key마다 한 번만 실행된다.
+type DedupStore interface {
+	// Contains는 key가 추가되었고 아직 만료되지 않았는지 알려준다.
+	Contains(key string) bool
+	// Add는 key를 처리 완료로 기록한다.
+	Add(key string) error
+}
+
+// KeyFunc는 메시지의 dedup key를 반환한다. ""면 항상 처리한다.
+type KeyFunc func(msg *Message) string
+
+// EventIDKey는 EventBridge event ID를 key로 써서 같은 이벤트의 SQS 재전달을
+// 잡는다.
+func EventIDKey(msg *Message) string {
+	if msg.Envelope.ID == "" {
+		return ""
+	}
+	return "event:" + msg.Envelope.ID
+}
+
+// PaymentStatusKey는 payment ID, event type, 상태, refund ID로 결제 이벤트의
+// key를 만든다. EventIDKey와 달리 같은 전이가 별도의 EventBridge 이벤트로 두 번
+// 발행된 경우도 잡는다. 디코딩되지 않는 detail에는 key가 없다.
+func PaymentStatusKey(msg *Message) string {
+	event, err := paymentevent.Decode(msg.Envelope.Detail)
+	if err != nil {
+		return ""
+	}
+	parts := []string{"payment", event.PaymentID, string(event.EventType), string(event.Status)}
+	if event.RefundID != "" {
+		parts = append(parts, event.RefundID)
+	}
+	return strings.Join(parts, ":")
+}
+
+type dedupEntry struct {
+	key       string
+	expiresAt time.Time
+}
+
+// MemoryDedupStore는 고정 TTL을 가진 key의 LRU다. 가득 차면 가장 오래전에 본
+// key를 만료 전이라도 내보낸다.
+type MemoryDedupStore struct {
+	mu       sync.Mutex
+	capacity int
+	ttl      time.Duration
+	order    *list.List // 앞쪽이 가장 최근
+	entries  map[string]*list.Element
+}
+
+func NewMemoryDedupStore(capacity int, ttl time.Duration) *MemoryDedupStore {
+	if capacity <= 0 {
+		capacity = 100000
+	}
+	return &MemoryDedupStore{
+		capacity: capacity,
+		ttl:      ttl,
+		order:    list.New(),
+		entries:  make(map[string]*list.Element),
+	}
+}
+
+func (s *MemoryDedupStore) Contains(key string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	elem, ok := s.entries[key]
+	if !ok {
+		return false
+	}
+	if !time.Now().Before(elem.Value.(*dedupEntry).expiresAt) {
+		s.remove(elem)
+		return false
+	}
+	s.order.MoveToFront(elem)
+	return true
+}
+
+func (s *MemoryDedupStore) Add(key string) error {
+	s.add(key, time.Now().Add(s.ttl))
+	return nil
+}
+
+// Len은 보관 중인 key 수를 반환한다. 만료되었지만 아직 내보내지 않은 key도
+// 포함한다.
+func (s *MemoryDedupStore) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.order.Len()
+}
+
+func (s *MemoryDedupStore) add(key string, expiresAt time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if elem, ok := s.entries[key]; ok {
+		elem.Value.(*dedupEntry).expiresAt = expiresAt
+		s.order.MoveToFront(elem)
+		return
+	}
+	s.entries[key] = s.order.PushFront(&dedupEntry{key: key, expiresAt: expiresAt})
+	for s.order.Len() > s.capacity {
+		s.remove(s.order.Back())
+	}
+}
+
+// live는 만료되지 않은 항목을 가장 오래전에 본 것부터 반환한다.
+func (s *MemoryDedupStore) live() []dedupEntry {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	entries := make([]dedupEntry, 0, s.order.Len())
+	for elem := s.order.Back(); elem != nil; elem = elem.Prev() {
+		if entry := elem.Value.(*dedupEntry); now.Before(entry.expiresAt) {
+			entries = append(entries, *entry)
+		}
+	}
+	return entries
+}
+
+// remove는 elem을 지운다. 호출자가 s.mu를 잡고 있어야 한다.
+func (s *MemoryDedupStore) remove(elem *list.Element) {
+	s.order.Remove(elem)
+	delete(s.entries, elem.Value.(*dedupEntry).key)
+}
@@ -0,0 +1,212 @@
+package consumer
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// FileDedupStore는 append-only 로그(key마다 JSON 한 줄)를 둔 MemoryDedupStore로,
+// 처리한 key가 worker 재시작 뒤에도 남는다. store를 열 때 로그를 replay하고
+// 만료되지 않은 key만 남도록 compact하며, 로그가 capacity 줄 이상이고 그 절반
+// 이상이 만료·제거·갱신된 key일 때 다시 compact한다.
+type FileDedupStore struct {
+	mem  *MemoryDedupStore
+	path string
+
+	mu      sync.Mutex
+	file    *os.File
+	records int   // 현재 로그의 줄 수
+	size    int64 // 마지막으로 온전히 기록된 로그 크기
+	torn    bool  // 실패한 쓰기의 잔여 바이트를 아직 잘라내지 못함
+}
+
+type dedupRecord struct {
+	Key       string    `json:"key"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+func NewFileDedupStore(path string, capacity int, ttl time.Duration) (*FileDedupStore, error) {
+	if dir := filepath.Dir(path); dir != "" {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return nil, fmt.Errorf("failed to create dedup store directory: %w", err)
+		}
+	}
+
+	s := &FileDedupStore{
+		mem:  NewMemoryDedupStore(capacity, ttl),
+		path: path,
+	}
+	if err := s.replay(); err != nil {
+		return nil, err
+	}
+	if err := s.compact(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *FileDedupStore) Contains(key string) bool {
+	return s.mem.Contains(key)
+}
+
+// Add는 key를 메모리에 기록하고 로그에 덧붙인 뒤 sync하고 반환한다. 그래서
+// 메시지를 삭제한 뒤 crash가 나도 key는 남는다. 쓰기나 sync가 실패하면 그
+// 부분을 로그에서 다시 잘라내, 다음 key가 쓰다 만 줄 뒤에 붙지 않게 한다.
+func (s *FileDedupStore) Add(key string) error {
+	expiresAt := time.Now().Add(s.mem.ttl)
+	s.mem.add(key, expiresAt)
+
+	line, err := json.Marshal(dedupRecord{Key: key, ExpiresAt: expiresAt})
+	if err != nil {
+		return fmt.Errorf("failed to marshal dedup key: %w", err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.torn {
+		if err := s.truncateTail(); err != nil {
+			return fmt.Errorf("dedup store log has an unrepaired partial record: %w", err)
+		}
+	}
+	n, err := s.file.Write(append(line, '\n'))
+	if err != nil {
+		s.truncateTail()
+		return fmt.Errorf("failed to append dedup key: %w", err)
+	}
+	if err := s.file.Sync(); err != nil {
+		s.truncateTail()
+		return fmt.Errorf("failed to sync dedup store: %w", err)
+	}
+	s.size += int64(n)
+	s.records++
+	return s.maybeCompact()
+}
+
+// truncateTail은 로그를 마지막 온전한 레코드까지 잘라낸다. 실패하면 성공할
+// 때까지 Add가 덧붙이기를 거부한다. 호출자가 s.mu를 잡고 있어야 한다.
+func (s *FileDedupStore) truncateTail() error {
+	if err := s.file.Truncate(s.size); err != nil {
+		s.torn = true
+		return err
+	}
+	s.torn = false
+	return nil
+}
+
+// maybeCompact는 로그가 capacity 줄 이상이고 그 절반 이상이 더 이상 메모리에
+// 없을 때 compact한다. 호출자가 s.mu를 잡고 있어야 한다.
+func (s *FileDedupStore) maybeCompact() error {
+	if s.records < s.mem.capacity || s.records < 2*s.mem.Len() {
+		return nil
+	}
+	if err := s.compact(); err != nil {
+		// 키는 이미 기록되었으므로 다음 Add에서 다시 시도한다
+		return fmt.Errorf("failed to compact dedup store: %w", err)
+	}
+	return nil
+}
+
+// Len은 메모리에 있는 key 수를 반환한다.
+func (s *FileDedupStore) Len() int {
+	return s.mem.Len()
+}
+
+func (s *FileDedupStore) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.file.Close()
+}
+
+func (s *FileDedupStore) replay() error {
+	f, err := os.Open(s.path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to open dedup store: %w", err)
+	}
+	defer f.Close()
+
+	// 마지막 줄은 쓰다 만 상태일 수 있으므로 건너뛰지만, 그 뒤에 줄이 더 있으면
+	// 확인된 쓰기가 손상된 것이므로 열지 않는다
+	now := time.Now()
+	scanner := bufio.NewScanner(f)
+	var (
+		lineNo     int
+		corrupt    int
+		corruptErr error
+	)
+	for scanner.Scan() {
+		lineNo++
+		if corruptErr != nil {
+			return fmt.Errorf("dedup store %s is corrupt at line %d: %w", s.path, corrupt, corruptErr)
+		}
+		var record dedupRecord
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			corrupt, corruptErr = lineNo, err
+			continue
+		}
+		if record.Key == "" {
+			corrupt, corruptErr = lineNo, fmt.Errorf("record has no key")
+			continue
+		}
+		if now.Before(record.ExpiresAt) {
+			s.mem.add(record.Key, record.ExpiresAt)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("failed to read dedup store: %w", err)
+	}
+	return nil
+}
+
+// compact는 만료되지 않은 key만 LRU 순서로 로그에 다시 쓰고, 덧붙이기용으로
+// 다시 연다. 새 로그가 이전 로그를 대체할 때까지 이전 로그는 열어 둔다.
+func (s *FileDedupStore) compact() error {
+	live := s.mem.live()
+	tmpPath := s.path + ".tmp"
+	tmp, err := os.Create(tmpPath)
+	if err != nil {
+		return fmt.Errorf("failed to create compacted dedup store: %w", err)
+	}
+	w := bufio.NewWriter(tmp)
+	var size int64
+	for _, entry := range live {
+		line, err := json.Marshal(dedupRecord{Key: entry.key, ExpiresAt: entry.expiresAt})
+		if err != nil {
+			tmp.Close()
+			return fmt.Errorf("failed to marshal dedup key: %w", err)
+		}
+		n, _ := w.Write(append(line, '\n'))
+		size += int64(n)
+	}
+	if err := w.Flush(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write compacted dedup store: %w", err)
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to sync compacted dedup store: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmpPath, s.path); err != nil {
+		return fmt.Errorf("failed to replace dedup store: %w", err)
+	}
+
+	file, err := os.OpenFile(s.path, os.O_APPEND|os.O_WRONLY|os.O_CREATE, 0o644)
+	if err != nil {
+		return fmt.Errorf("failed to open dedup store for append: %w", err)
+	}
+	if s.file != nil {
+		s.file.Close()
+	}
+	s.file, s.records, s.size, s.torn = file, len(live), size, false
+	return nil
+}
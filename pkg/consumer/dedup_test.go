@@ -0,0 +1,335 @@
+package consumer
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+)
+
+// liveKeys는 만료되지 않은 key를 가장 오래전에 본 것부터 반환한다.
+func liveKeys(s *MemoryDedupStore) []string {
+	var keys []string
+	for _, entry := range s.live() {
+		keys = append(keys, entry.key)
+	}
+	return keys
+}
+
+func TestMemoryDedupStoreEviction(t *testing.T) {
+	tests := []struct {
+		name    string
+		ops     []string // "add:k" 또는 "get:k"
+		want    []string // 가장 오래전에 본 것부터
+		evicted []string
+	}{
+		{
+			name:    "oldest add is evicted",
+			ops:     []string{"add:a", "add:b", "add:c", "add:d"},
+			want:    []string{"b", "c", "d"},
+			evicted: []string{"a"},
+		},
+		{
+			name:    "contains refreshes recency",
+			ops:     []string{"add:a", "add:b", "add:c", "get:a", "add:d"},
+			want:    []string{"c", "a", "d"},
+			evicted: []string{"b"},
+		},
+		{
+			name:    "re-adding refreshes recency",
+			ops:     []string{"add:a", "add:b", "add:c", "add:a", "add:d", "add:e"},
+			want:    []string{"a", "d", "e"},
+			evicted: []string{"b", "c"},
+		},
+		{
+			name: "misses do not change order",
+			ops:  []string{"add:a", "add:b", "get:x", "add:c"},
+			want: []string{"a", "b", "c"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := NewMemoryDedupStore(3, time.Hour)
+			for _, op := range tt.ops {
+				action, key, _ := strings.Cut(op, ":")
+				if action == "add" {
+					s.Add(key)
+				} else {
+					s.Contains(key)
+				}
+			}
+
+			if got := liveKeys(s); !slices.Equal(got, tt.want) {
+				t.Errorf("keys = %v, want %v", got, tt.want)
+			}
+			for _, key := range tt.evicted {
+				if s.Contains(key) {
+					t.Errorf("%s was not evicted", key)
+				}
+			}
+		})
+	}
+}
+
+func TestMemoryDedupStoreExpiry(t *testing.T) {
+	s := NewMemoryDedupStore(10, time.Hour)
+	s.add("expired", time.Now().Add(-time.Second))
+	s.Add("live")
+
+	if s.Contains("expired") {
+		t.Error("expired key reported as seen")
+	}
+	if !s.Contains("live") {
+		t.Error("live key not reported as seen")
+	}
+	if s.Len() != 1 {
+		t.Errorf("Len = %d after the expired key was looked up, want 1", s.Len())
+	}
+}
+
+func TestFileDedupStoreSurvivesReopen(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "dedup", "worker-dedup.log")
+
+	s, err := NewFileDedupStore(path, 10, time.Hour)
+	if err != nil {
+		t.Fatalf("NewFileDedupStore: %v", err)
+	}
+	for _, key := range []string{"a", "b", "c"} {
+		if err := s.Add(key); err != nil {
+			t.Fatalf("Add(%s): %v", key, err)
+		}
+	}
+	if err := s.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	// 만료된 key와 쓰다 만 마지막 줄 (crash)
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		t.Fatalf("open log: %v", err)
+	}
+	expired, _ := json.Marshal(dedupRecord{Key: "old", ExpiresAt: time.Now().Add(-time.Minute)})
+	f.Write(append(expired, '\n'))
+	f.WriteString(`{"key":"torn","expires_at":"20`)
+	f.Close()
+
+	tests := []struct {
+		name     string
+		capacity int
+		want     []string // 가장 오래전에 본 것부터
+	}{
+		{name: "same capacity", capacity: 10, want: []string{"a", "b", "c"}},
+		{name: "smaller capacity keeps the most recent", capacity: 2, want: []string{"b", "c"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// 앞 subtest의 compaction 결과가 아니라 같은 로그에서 시작한다
+			copyPath := filepath.Join(t.TempDir(), "worker-dedup.log")
+			data, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatalf("read log: %v", err)
+			}
+			os.WriteFile(copyPath, data, 0o644)
+
+			reopened, err := NewFileDedupStore(copyPath, tt.capacity, time.Hour)
+			if err != nil {
+				t.Fatalf("reopen: %v", err)
+			}
+			defer reopened.Close()
+
+			if got := liveKeys(reopened.mem); !slices.Equal(got, tt.want) {
+				t.Errorf("keys after reopen = %v, want %v", got, tt.want)
+			}
+			for _, key := range []string{"old", "torn"} {
+				if reopened.Contains(key) {
+					t.Errorf("%s survived the reopen", key)
+				}
+			}
+
+			compacted, err := os.ReadFile(copyPath)
+			if err != nil {
+				t.Fatalf("read compacted log: %v", err)
+			}
+			if lines := strings.Count(string(compacted), "\n"); lines != len(tt.want) {
+				t.Errorf("compacted log has %d lines, want %d", lines, len(tt.want))
+			}
+
+			// 다시 열린 store에 추가한 key도 다음 재시작까지 남는다
+			if err := reopened.Add("d"); err != nil {
+				t.Fatalf("Add after reopen: %v", err)
+			}
+			reopened.Close()
+			again, err := NewFileDedupStore(copyPath, tt.capacity, time.Hour)
+			if err != nil {
+				t.Fatalf("second reopen: %v", err)
+			}
+			defer again.Close()
+			if !again.Contains("d") {
+				t.Error("key added after reopen was lost")
+			}
+		})
+	}
+}
+
+func TestFileDedupStoreCompactsWhileRunning(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "worker-dedup.log")
+	s, err := NewFileDedupStore(path, 3, time.Hour)
+	if err != nil {
+		t.Fatalf("NewFileDedupStore: %v", err)
+	}
+	defer s.Close()
+
+	// 용량 3에서 evict된 key가 로그에 계속 쌓이지 않아야 한다
+	for i := range 20 {
+		if err := s.Add(string(rune('a' + i))); err != nil {
+			t.Fatalf("Add: %v", err)
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatalf("read log: %v", err)
+		}
+		if lines := strings.Count(string(data), "\n"); lines > 6 {
+			t.Fatalf("log has %d lines after %d adds, want at most 6", lines, i+1)
+		}
+	}
+
+	reopened, err := NewFileDedupStore(path, 3, time.Hour)
+	if err != nil {
+		t.Fatalf("reopen: %v", err)
+	}
+	defer reopened.Close()
+	if got, want := liveKeys(reopened.mem), []string{"r", "s", "t"}; !slices.Equal(got, want) {
+		t.Errorf("keys after reopen = %v, want %v", got, want)
+	}
+}
+
+func TestFileDedupStoreReplayCorruptLines(t *testing.T) {
+	tests := []struct {
+		name    string
+		tail    string // 정상 key 두 개 뒤에 덧붙일 내용
+		wantErr bool
+	}{
+		{name: "partial last line", tail: `{"key":"c","expires_at":"20`},
+		{name: "corrupt line before a record", tail: "{\"key\":\"c\",\"exp\n" + `{"key":"d","expires_at":"2999-01-01T00:00:00Z"}` + "\n", wantErr: true},
+		{name: "record without key before a record", tail: "{}\n" + `{"key":"d","expires_at":"2999-01-01T00:00:00Z"}` + "\n", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "worker-dedup.log")
+			s, err := NewFileDedupStore(path, 10, time.Hour)
+			if err != nil {
+				t.Fatalf("NewFileDedupStore: %v", err)
+			}
+			for _, key := range []string{"a", "b"} {
+				if err := s.Add(key); err != nil {
+					t.Fatalf("Add(%s): %v", key, err)
+				}
+			}
+			s.Close()
+			appendRaw(t, path, tt.tail)
+
+			reopened, err := NewFileDedupStore(path, 10, time.Hour)
+			if tt.wantErr {
+				if err == nil {
+					reopened.Close()
+					t.Fatal("NewFileDedupStore succeeded on a log corrupt in the middle")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("NewFileDedupStore: %v", err)
+			}
+			defer reopened.Close()
+			if got := liveKeys(reopened.mem); !slices.Equal(got, []string{"a", "b"}) {
+				t.Errorf("keys after reopen = %v, want [a b]", got)
+			}
+		})
+	}
+}
+
+func TestFileDedupStoreAddIsDurable(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "worker-dedup.log")
+	s, err := NewFileDedupStore(path, 10, time.Hour)
+	if err != nil {
+		t.Fatalf("NewFileDedupStore: %v", err)
+	}
+	defer s.Close()
+
+	// Add가 반환되면 Close 없이도 (crash) key가 로그에 있다
+	if err := s.Add("a"); err != nil {
+		t.Fatalf("Add: %v", err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("read log: %v", err)
+	}
+	if !strings.Contains(string(data), `"key":"a"`) {
+		t.Errorf("log = %q, want the added key", data)
+	}
+
+	// 쓰다 만 key가 남은 상태에서 append 실패 처리
+	appendRaw(t, path, `{"key":"torn","exp`)
+	s.mu.Lock()
+	s.truncateTail()
+	s.mu.Unlock()
+
+	if err := s.Add("b"); err != nil {
+		t.Fatalf("Add after a torn append: %v", err)
+	}
+	reopened, err := NewFileDedupStore(path, 10, time.Hour)
+	if err != nil {
+		t.Fatalf("reopen: %v", err)
+	}
+	defer reopened.Close()
+	if got := liveKeys(reopened.mem); !slices.Equal(got, []string{"a", "b"}) {
+		t.Errorf("keys after reopen = %v, want [a b]", got)
+	}
+}
+
+func appendRaw(t *testing.T, path, data string) {
+	t.Helper()
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		t.Fatalf("open log: %v", err)
+	}
+	defer f.Close()
+	if _, err := f.WriteString(data); err != nil {
+		t.Fatalf("write log: %v", err)
+	}
+}
+
+func TestConsumerDropsDuplicates(t *testing.T) {
+	client := newFakeSQS()
+	c := newTestConsumer(Config{Dedup: NewMemoryDedupStore(10, time.Hour), DedupKey: EventIDKey}, client)
+
+	var handled []string
+	c.Handle(testDetailType, HandlerFunc(func(ctx context.Context, msg *Message) Result {
+		handled = append(handled, msg.ID)
+		if msg.ID == "m-nack" {
+			return NackAfter(nil, time.Second)
+		}
+		return Ack()
+	}))
+
+	first := testMessage("m-1", 1)
+	redelivered := rawMessage("m-2", aws.ToString(first.Body), 1) // 같은 EventBridge event
+	nacked := testMessage("m-nack", 1)
+	retried := rawMessage("m-nack-2", aws.ToString(nacked.Body), 2)
+	consume(c, first, redelivered, nacked, retried)
+
+	// nack된 event는 기록되지 않으므로 재전달되면 다시 처리한다
+	if want := []string{"m-1", "m-nack", "m-nack-2"}; !slices.Equal(handled, want) {
+		t.Errorf("handled = %v, want %v", handled, want)
+	}
+	if !client.wasDeleted("rh-m-2") {
+		t.Error("duplicate was not deleted")
+	}
+}
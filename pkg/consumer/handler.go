@@ -0,0 +1,87 @@
+package consumer
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+
+	"github.com/traffic-tacos/payment-sim-api/pkg/paymentevent"
+)
+
+// Message는 EventBridge 이벤트를 담은 SQS 메시지다.
+type Message struct {
+	ID            string
+	ReceiptHandle string
+	Body          []byte
+	// ReceiveCount는 SQS ApproximateReceiveCount다 (첫 전달이면 1).
+	ReceiveCount int
+	SentAt       time.Time
+	// Envelope은 파싱한 EventBridge 이벤트이며 Envelope.Detail은 원본 JSON이다.
+	Envelope paymentevent.Envelope
+	// Attempt는 현재 전달에서 프로세스 안 시도 횟수다 (1부터).
+	Attempt int
+}
+
+// DetailType은 메시지를 라우팅하는 EventBridge detail-type을 반환한다.
+func (m *Message) DetailType() string {
+	return m.Envelope.DetailType
+}
+
+func newMessage(raw types.Message) (*Message, error) {
+	msg := &Message{
+		ID:            aws.ToString(raw.MessageId),
+		ReceiptHandle: aws.ToString(raw.ReceiptHandle),
+		Body:          []byte(aws.ToString(raw.Body)),
+		ReceiveCount:  1,
+	}
+	if v, err := strconv.Atoi(raw.Attributes[string(types.MessageSystemAttributeNameApproximateReceiveCount)]); err == nil {
+		msg.ReceiveCount = v
+	}
+	if v, err := strconv.ParseInt(raw.Attributes[string(types.MessageSystemAttributeNameSentTimestamp)], 10, 64); err == nil {
+		msg.SentAt = time.UnixMilli(v)
+	}
+
+	if err := json.Unmarshal(msg.Body, &msg.Envelope); err != nil {
+		return msg, fmt.Errorf("invalid EventBridge envelope: %w", err)
+	}
+	if msg.Envelope.DetailType == "" {
+		return msg, fmt.Errorf("EventBridge envelope has no detail-type")
+	}
+	return msg, nil
+}
+
+// Handler는 메시지 하나를 처리하고 Ack, Nack, NackAfter, Reject로 처리 결과를
+// 정한다.
+type Handler interface {
+	Handle(ctx context.Context, msg *Message) Result
+}
+
+// HandlerFunc는 함수를 Handler로 쓸 수 있게 한다.
+type HandlerFunc func(ctx context.Context, msg *Message) Result
+
+func (f HandlerFunc) Handle(ctx context.Context, msg *Message) Result {
+	return f(ctx, msg)
+}
+
+// PaymentHandlerFunc는 디코딩한 결제 이벤트를 처리한다.
+type PaymentHandlerFunc func(ctx context.Context, msg *Message, event paymentevent.Event) Result
+
+// paymentHandler는 결제 이벤트 detail을 디코딩하고 검증한다. paymentevent
+// 스키마에 맞지 않는 detail은 거절한다.
+func paymentHandler(eventType paymentevent.EventType, handle PaymentHandlerFunc) Handler {
+	return HandlerFunc(func(ctx context.Context, msg *Message) Result {
+		event, err := paymentevent.Decode(msg.Envelope.Detail)
+		if err != nil {
+			return Reject(err)
+		}
+		if event.EventType != eventType {
+			return Reject(fmt.Errorf("event_type %s does not match detail-type %q", event.EventType, msg.DetailType()))
+		}
+		return handle(ctx, msg, event)
+	})
+}
@@ -0,0 +1,56 @@
+package consumer
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var (
+	messagesReceivedTotal = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "consumer_messages_received_total",
+		Help: "SQS messages received by the consumer.",
+	})
+
+	messagesTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "consumer_messages_total",
+		Help: "Messages settled, by detail-type and result (ack, nack, reject, released).",
+	}, []string{"detail_type", "result"})
+
+	messageLag = promauto.NewHistogram(prometheus.HistogramOpts{
+		Name:    "consumer_message_lag_seconds",
+		Help:    "Time between a message being sent to SQS and its handler starting.",
+		Buckets: []float64{.01, .05, .1, .5, 1, 5, 10, 30, 60, 300, 900},
+	})
+
+	handlerDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "consumer_handler_duration_seconds",
+		Help:    "Time spent handling a message, including in-process retries.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"detail_type"})
+
+	handlersBusy = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "consumer_handlers_busy",
+		Help: "Number of handler goroutines currently processing a message.",
+	})
+
+	ackBatchSize = promauto.NewHistogram(prometheus.HistogramOpts{
+		Name:    "consumer_ack_batch_size",
+		Help:    "Messages deleted per DeleteMessageBatch call.",
+		Buckets: []float64{1, 2, 4, 6, 8, 10},
+	})
+
+	ackFailuresTotal = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "consumer_ack_failures_total",
+		Help: "Messages whose deletion failed; they are redelivered after the visibility timeout.",
+	})
+
+	deadLetterTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "consumer_dead_letter_total",
+		Help: "Rejected or exhausted messages, by result (forwarded, left_for_redrive, failed).",
+	}, []string{"result"})
+
+	duplicatesTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "consumer_duplicates_total",
+		Help: "Duplicate messages not handled, by detail-type and state (processed = dropped, in_flight = returned to the queue).",
+	}, []string{"detail_type", "state"})
+)
@@ -0,0 +1,74 @@
+package consumer
+
+import (
+	"errors"
+	"time"
+)
+
+type action int
+
+const (
+	actionAck action = iota
+	actionNack
+	actionReject
+)
+
+func (a action) String() string {
+	switch a {
+	case actionAck:
+		return "ack"
+	case actionNack:
+		return "nack"
+	default:
+		return "reject"
+	}
+}
+
+// Result는 처리한 메시지를 어떻게 할지 consumer에 알려준다.
+type Result struct {
+	action action
+	err    error
+	// NackAfter로 지정한 지연 (0 = retry policy를 따름)
+	requeue time.Duration
+}
+
+// Ack는 메시지를 큐에서 삭제한다.
+func Ack() Result {
+	return Result{action: actionAck}
+}
+
+// Nack은 일시적인 실패를 알린다. retry policy가 허용하는 만큼 handler를
+// 프로세스 안에서 다시 시도한 뒤 메시지를 큐에 돌려보낸다.
+func Nack(err error) Result {
+	if err == nil {
+		err = errors.New("nack")
+	}
+	return Result{action: actionNack, err: err}
+}
+
+// NackAfter는 프로세스 안에서 재시도하지 않고 메시지를 큐에 돌려보낸다.
+// 메시지는 policy의 RequeueBackoff 대신 delay 뒤에 다시 보인다.
+func NackAfter(err error, delay time.Duration) Result {
+	if err == nil {
+		err = errors.New("nack")
+	}
+	if delay <= 0 {
+		delay = time.Second
+	}
+	return Result{action: actionNack, err: err, requeue: delay}
+}
+
+// Reject는 파싱할 수 없는 메시지 같은 영구적인 실패를 알린다. 다시 시도해도
+// 성공하지 않으므로 dead-letter 처리한다. Config.DeadLetterQueueURL이 있으면
+// 오류와 함께 그 큐로 보내고, 없으면 SQS redrive policy가 옮기도록 큐에 남긴다.
+func Reject(err error) Result {
+	if err == nil {
+		err = errors.New("rejected")
+	}
+	return Result{action: actionReject, err: err}
+}
+
+// Err는 Nack 또는 Reject 결과의 실패 원인을 반환한다.
+func (r Result) Err() error {
+	return r.err
+}
@@ -0,0 +1,75 @@
+package consumer
+
+import (
+	"math"
+	"math/rand/v2"
+	"time"
+)
+
+// RetryPolicy는 Nack된 메시지를 다시 시도하는 방법을 정한다. handler는 전달
+// 한 번마다 최대 MaxAttempts번 호출되며, 시도 사이에
+// min(Base * 2^(n-1), Max) ± Jitter만큼 기다린다. 마지막 시도 뒤에는 메시지를
+// 큐에 돌려보내고, r이 SQS ApproximateReceiveCount일 때
+// min(RequeueDelay * 2^(r-1), MaxRequeueDelay) ± Jitter 뒤에 다시 전달된다.
+// MaxReceives번 수신된 메시지는 Reject처럼 dead-letter 처리한다 (0 = 큐의
+// redrive policy에 맡김).
+type RetryPolicy struct {
+	MaxAttempts     int
+	Base            time.Duration
+	Max             time.Duration
+	Jitter          float64 // 0~1, 지연 대비 비율
+	RequeueDelay    time.Duration
+	MaxRequeueDelay time.Duration // 0이거나 RequeueDelay보다 작으면 RequeueDelay 고정
+	MaxReceives     int
+}
+
+// DefaultRetryPolicy는 WithRetry 없이 등록한 handler에 쓴다.
+var DefaultRetryPolicy = RetryPolicy{
+	MaxAttempts:     3,
+	Base:            200 * time.Millisecond,
+	Max:             5 * time.Second,
+	Jitter:          0.2,
+	RequeueDelay:    30 * time.Second,
+	MaxRequeueDelay: 15 * time.Minute,
+}
+
+func (p RetryPolicy) withDefaults() RetryPolicy {
+	if p.MaxAttempts <= 0 {
+		p.MaxAttempts = 1
+	}
+	if p.RequeueDelay < 0 {
+		p.RequeueDelay = 0
+	}
+	if p.MaxRequeueDelay < p.RequeueDelay {
+		p.MaxRequeueDelay = p.RequeueDelay
+	}
+	return p
+}
+
+// Backoff는 attempt번째(1부터) 시도가 실패한 뒤의 대기 시간을 반환한다.
+func (p RetryPolicy) Backoff(attempt int) time.Duration {
+	return p.exponential(p.Base, p.Max, attempt)
+}
+
+// RequeueBackoff는 receiveCount번째 전달이 실패한 뒤 메시지가 보이지 않는
+// 시간을 반환한다.
+func (p RetryPolicy) RequeueBackoff(receiveCount int) time.Duration {
+	return p.exponential(p.RequeueDelay, p.MaxRequeueDelay, receiveCount)
+}
+
+func (p RetryPolicy) exponential(base, max time.Duration, n int) time.Duration {
+	if n < 1 {
+		n = 1
+	}
+	delay := float64(base) * math.Pow(2, float64(n-1))
+	if max > 0 && delay > float64(max) {
+		delay = float64(max)
+	}
+	if p.Jitter > 0 {
+		delay += delay * p.Jitter * (2*rand.Float64() - 1)
+	}
+	if delay < 0 {
+		delay = 0
+	}
+	return time.Duration(delay)
+}
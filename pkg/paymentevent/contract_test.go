@@ -0,0 +1,227 @@
+package paymentevent_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/aws/aws-sdk-go-v2/service/eventbridge"
+	"go.uber.org/zap"
+
+	"github.com/traffic-tacos/payment-sim-api/internal/config"
+	"github.com/traffic-tacos/payment-sim-api/internal/events"
+	"github.com/traffic-tacos/payment-sim-api/pkg/paymentevent"
+)
+
+type schemaProperty struct {
+	Enum []interface{} `json:"enum"`
+}
+
+type schemaDoc struct {
+	Required   []string                  `json:"required"`
+	Properties map[string]schemaProperty `json:"properties"`
+}
+
+// TestSchemaMatchesEvent checks that the Go types and the JSON schema describe
+// the same event.
+func TestSchemaMatchesEvent(t *testing.T) {
+	var schema schemaDoc
+	if err := json.Unmarshal(paymentevent.Schema(), &schema); err != nil {
+		t.Fatalf("invalid schema: %v", err)
+	}
+
+	fields := map[string]bool{}
+	typ := reflect.TypeOf(paymentevent.Event{})
+	for i := 0; i < typ.NumField(); i++ {
+		tag, _, _ := strings.Cut(typ.Field(i).Tag.Get("json"), ",")
+		fields[tag] = true
+		if _, ok := schema.Properties[tag]; !ok {
+			t.Errorf("Event field %s is missing from the schema", tag)
+		}
+	}
+	for prop := range schema.Properties {
+		if !fields[prop] {
+			t.Errorf("schema property %s is missing from Event", prop)
+		}
+	}
+	for _, field := range schema.Required {
+		if !fields[field] {
+			t.Errorf("required property %s is missing from Event", field)
+		}
+	}
+
+	var statuses, eventTypes []string
+	for _, s := range paymentevent.Statuses() {
+		statuses = append(statuses, string(s))
+	}
+	for _, et := range paymentevent.EventTypes() {
+		if _, ok := et.DetailType(); !ok {
+			t.Errorf("event type %s has no detail type", et)
+		}
+		eventTypes = append(eventTypes, string(et))
+	}
+
+	tests := []struct {
+		property string
+		want     []string
+	}{
+		{"status", statuses},
+		{"event_type", eventTypes},
+		{"schema_version", []string{fmt.Sprint(paymentevent.SchemaVersion)}},
+	}
+	for _, tt := range tests {
+		var enum []string
+		for _, v := range schema.Properties[tt.property].Enum {
+			enum = append(enum, fmt.Sprint(v))
+		}
+		want := slices.Clone(tt.want)
+		sort.Strings(enum)
+		sort.Strings(want)
+		if !slices.Equal(enum, want) {
+			t.Errorf("schema %s enum = %v, want %v", tt.property, enum, want)
+		}
+	}
+}
+
+// eventBridgeStub records PutEvents entries and returns them as the
+// EventBridge envelopes an EventBridge → SQS rule would deliver.
+type eventBridgeStub struct {
+	mu        sync.Mutex
+	envelopes [][]byte
+}
+
+func (s *eventBridgeStub) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Entries []struct {
+			Source     string
+			DetailType string
+			Detail     string
+		}
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var entries []map[string]string
+	for _, entry := range req.Entries {
+		id := fmt.Sprintf("evt-%d", len(s.envelopes)+1)
+		body, _ := json.Marshal(map[string]interface{}{
+			"id":          id,
+			"detail-type": entry.DetailType,
+			"source":      entry.Source,
+			"time":        time.Now().UTC().Format(time.RFC3339),
+			"detail":      json.RawMessage(entry.Detail),
+		})
+		s.envelopes = append(s.envelopes, body)
+		entries = append(entries, map[string]string{"EventId": id})
+	}
+	w.Header().Set("Content-Type", "application/x-amz-json-1.1")
+	json.NewEncoder(w).Encode(map[string]interface{}{"Entries": entries, "FailedEntryCount": 0})
+}
+
+func (s *eventBridgeStub) last() []byte {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.envelopes) == 0 {
+		return nil
+	}
+	return s.envelopes[len(s.envelopes)-1]
+}
+
+// TestPublisherEventsReachWorker publishes every event type and status the
+// API emits and decodes it the way the reservation worker does.
+func TestPublisherEventsReachWorker(t *testing.T) {
+	stub := &eventBridgeStub{}
+	srv := httptest.NewServer(stub)
+	defer srv.Close()
+
+	client := eventbridge.New(eventbridge.Options{
+		Region:       "ap-northeast-2",
+		BaseEndpoint: aws.String(srv.URL),
+		Credentials:  credentials.NewStaticCredentialsProvider("test", "test", ""),
+	})
+	cfg := &config.Config{EventBusName: "test-bus", EventSource: "payment-sim-api"}
+	publisher := events.NewPublisher(client, cfg, zap.NewNop())
+
+	tests := []struct {
+		eventType paymentevent.EventType
+		status    paymentevent.Status
+		refundID  string
+		want      paymentevent.Outcome // status events only
+	}{
+		{paymentevent.EventTypeStatusUpdated, paymentevent.StatusPending, "", paymentevent.OutcomeInProgress},
+		{paymentevent.EventTypeStatusUpdated, paymentevent.StatusProcessing, "", paymentevent.OutcomeInProgress},
+		{paymentevent.EventTypeStatusUpdated, paymentevent.StatusAuthorized, "", paymentevent.OutcomeInProgress},
+		{paymentevent.EventTypeStatusUpdated, paymentevent.StatusCompleted, "", paymentevent.OutcomeApproved},
+		{paymentevent.EventTypeStatusUpdated, paymentevent.StatusFailed, "", paymentevent.OutcomeFailed},
+		{paymentevent.EventTypeStatusUpdated, paymentevent.StatusExpired, "", paymentevent.OutcomeFailed},
+		{paymentevent.EventTypeStatusUpdated, paymentevent.StatusRefunded, "", paymentevent.OutcomeRefunded},
+		{paymentevent.EventTypeCancelled, paymentevent.StatusCancelled, "", paymentevent.OutcomeFailed},
+		{paymentevent.EventTypeRefunded, paymentevent.StatusRefunded, "ref-1", 0},
+		{paymentevent.EventTypeRefundFailed, paymentevent.StatusCompleted, "ref-2", 0},
+	}
+
+	covered := map[paymentevent.Status]bool{}
+	for _, tt := range tests {
+		t.Run(string(tt.eventType)+"/"+string(tt.status), func(t *testing.T) {
+			published := paymentevent.Event{
+				EventType:     tt.eventType,
+				PaymentID:     "pay-1",
+				ReservationID: "rsv-1",
+				Status:        tt.status,
+				Amount:        50000,
+				Currency:      "KRW",
+				RefundID:      tt.refundID,
+			}
+			if err := publisher.PublishPaymentEvent(context.Background(), published); err != nil {
+				t.Fatalf("PublishPaymentEvent: %v", err)
+			}
+
+			envelope, event, err := paymentevent.DecodeMessage(stub.last())
+			if err != nil {
+				t.Fatalf("DecodeMessage: %v", err)
+			}
+			if want, _ := tt.eventType.DetailType(); envelope.DetailType != want {
+				t.Errorf("detail-type = %q, want %q", envelope.DetailType, want)
+			}
+			if event.SchemaVersion != paymentevent.SchemaVersion || event.Timestamp == 0 {
+				t.Errorf("publisher did not stamp the event: %+v", event)
+			}
+			event.SchemaVersion, event.Timestamp = 0, 0
+			if event != published {
+				t.Errorf("decoded event = %+v, want %+v", event, published)
+			}
+
+			switch tt.eventType {
+			case paymentevent.EventTypeStatusUpdated, paymentevent.EventTypeCancelled:
+				if got := event.Status.Outcome(); got != tt.want {
+					t.Errorf("Outcome() = %d, want %d", got, tt.want)
+				}
+				covered[event.Status] = true
+			}
+		})
+	}
+
+	for _, status := range paymentevent.Statuses() {
+		if !covered[status] {
+			t.Errorf("status %s is not covered by a status event", status)
+		}
+		if status.Outcome() == paymentevent.OutcomeUnknown {
+			t.Errorf("worker does not handle status %s", status)
+		}
+	}
+}
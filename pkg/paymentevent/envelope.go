@@ -0,0 +1,40 @@
+package paymentevent
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// Envelope은 EventBridge → SQS 규칙이 SQS 메시지 본문으로 전달하는
+// EventBridge 이벤트다.
+type Envelope struct {
+	ID         string          `json:"id"`
+	DetailType string          `json:"detail-type"`
+	Source     string          `json:"source"`
+	Account    string          `json:"account,omitempty"`
+	Time       time.Time       `json:"time"`
+	Region     string          `json:"region,omitempty"`
+	Detail     json.RawMessage `json:"detail"`
+}
+
+// DecodeMessage는 EventBridge 결제 이벤트를 담은 SQS 메시지 본문을 파싱하고
+// detail을 검증한다.
+func DecodeMessage(body []byte) (Envelope, Event, error) {
+	var envelope Envelope
+	if err := json.Unmarshal(body, &envelope); err != nil {
+		return Envelope{}, Event{}, fmt.Errorf("paymentevent: invalid EventBridge envelope: %w", err)
+	}
+	if len(envelope.Detail) == 0 {
+		return envelope, Event{}, fmt.Errorf("paymentevent: EventBridge envelope has no detail")
+	}
+
+	event, err := Decode(envelope.Detail)
+	if err != nil {
+		return envelope, Event{}, err
+	}
+	if want, _ := event.EventType.DetailType(); envelope.DetailType != "" && envelope.DetailType != want {
+		return envelope, Event{}, fmt.Errorf("paymentevent: detail-type %q does not match event_type %s", envelope.DetailType, event.EventType)
+	}
+	return envelope, event, nil
+}
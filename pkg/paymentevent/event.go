@@ -0,0 +1,169 @@
+// Package paymentevent is the versioned schema of the payment events that
+// payment-sim-api publishes to EventBridge (and that SQS delivers to
+// consumers such as the reservation worker).
+//
+// Producers and consumers share the Go types in this package and the JSON
+// schema returned by Schema, so a status or event type can only be added in
+// one place:
+//
+//	{
+//	  "schema_version": 1,
+//	  "event_type": "payment.status_updated",
+//	  "payment_id": "pay_123",
+//	  "reservation_id": "rsv_123",
+//	  "status": "PAYMENT_STATUS_COMPLETED",
+//	  "amount": 50000,
+//	  "currency": "KRW",
+//	  "timestamp": 1700000000
+//	}
+package paymentevent
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// SchemaVersion is the version of Event this package produces. Events without
+// a schema_version were published before versioning and are version 1.
+const SchemaVersion = 1
+
+// Status is the payment status carried by an event.
+type Status string
+
+const (
+	StatusPending    Status = "PAYMENT_STATUS_PENDING"
+	StatusProcessing Status = "PAYMENT_STATUS_PROCESSING"
+	// StatusAuthorized is emitted by capture_method=manual payments; the gRPC
+	// API reports it as PROCESSING.
+	StatusAuthorized Status = "PAYMENT_STATUS_AUTHORIZED"
+	StatusCompleted  Status = "PAYMENT_STATUS_COMPLETED"
+	StatusFailed     Status = "PAYMENT_STATUS_FAILED"
+	StatusCancelled  Status = "PAYMENT_STATUS_CANCELLED"
+	StatusRefunded   Status = "PAYMENT_STATUS_REFUNDED"
+	StatusExpired    Status = "PAYMENT_STATUS_EXPIRED"
+)
+
+// Statuses lists every status the API emits.
+func Statuses() []Status {
+	return []Status{
+		StatusPending, StatusProcessing, StatusAuthorized, StatusCompleted,
+		StatusFailed, StatusCancelled, StatusRefunded, StatusExpired,
+	}
+}
+
+// Valid reports whether s is a status of this schema version.
+func (s Status) Valid() bool {
+	for _, status := range Statuses() {
+		if s == status {
+			return true
+		}
+	}
+	return false
+}
+
+// Final reports whether no further status event follows for the payment
+// (refunds of a COMPLETED payment aside).
+func (s Status) Final() bool {
+	switch s {
+	case StatusPending, StatusProcessing, StatusAuthorized:
+		return false
+	default:
+		return true
+	}
+}
+
+// Outcome is what a status means for the reservation a payment pays for.
+type Outcome int
+
+const (
+	OutcomeUnknown Outcome = iota
+	// OutcomeInProgress leaves the reservation unchanged.
+	OutcomeInProgress
+	OutcomeApproved
+	OutcomeFailed
+	// OutcomeRefunded is reported with the payment.refunded event, which
+	// carries the refund details.
+	OutcomeRefunded
+)
+
+// Outcome classifies s. Consumers switch on it instead of on the statuses so
+// a status added to the schema cannot be silently ignored.
+func (s Status) Outcome() Outcome {
+	switch s {
+	case StatusCompleted:
+		return OutcomeApproved
+	case StatusFailed, StatusCancelled, StatusExpired:
+		return OutcomeFailed
+	case StatusPending, StatusProcessing, StatusAuthorized:
+		return OutcomeInProgress
+	case StatusRefunded:
+		return OutcomeRefunded
+	default:
+		return OutcomeUnknown
+	}
+}
+
+// EventType names what happened to the payment.
+type EventType string
+
+const (
+	EventTypeStatusUpdated EventType = "payment.status_updated"
+	EventTypeRefunded      EventType = "payment.refunded"
+	EventTypeRefundFailed  EventType = "payment.refund_failed"
+	EventTypeCancelled     EventType = "payment.cancelled"
+)
+
+// detailTypes maps event types to EventBridge detail types.
+var detailTypes = map[EventType]string{
+	EventTypeStatusUpdated: "Payment Status Updated",
+	EventTypeRefunded:      "Payment Refunded",
+	EventTypeRefundFailed:  "Payment Refund Failed",
+	EventTypeCancelled:     "Payment Cancelled",
+}
+
+// EventTypes lists every event type the API emits.
+func EventTypes() []EventType {
+	return []EventType{EventTypeStatusUpdated, EventTypeRefunded, EventTypeRefundFailed, EventTypeCancelled}
+}
+
+// DetailType returns the EventBridge detail-type of t.
+func (t EventType) DetailType() (string, bool) {
+	detailType, ok := detailTypes[t]
+	return detailType, ok
+}
+
+// Event is the EventBridge detail of a payment event.
+type Event struct {
+	SchemaVersion int       `json:"schema_version"`
+	EventType     EventType `json:"event_type"`
+	PaymentID     string    `json:"payment_id"`
+	ReservationID string    `json:"reservation_id"`
+	UserID        string    `json:"user_id,omitempty"`
+	Status        Status    `json:"status"`
+	Amount        int64     `json:"amount"`
+	Currency      string    `json:"currency"`
+	Timestamp     int64     `json:"timestamp"`
+	// 환불 이벤트에서만 사용 (Amount는 이번 환불 금액)
+	RefundID       string `json:"refund_id,omitempty"`
+	RefundedAmount int64  `json:"refunded_amount,omitempty"`
+	// 실패한 결제/환불의 PG 코드와 decline 사유
+	FailureCode   string `json:"failure_code,omitempty"`
+	FailureReason string `json:"failure_reason,omitempty"`
+}
+
+// Decode parses and validates an event detail. Pre-versioning events get
+// SchemaVersion 1.
+func Decode(detail []byte) (Event, error) {
+	if err := Validate(detail); err != nil {
+		return Event{}, err
+	}
+
+	var event Event
+	if err := json.Unmarshal(detail, &event); err != nil {
+		return Event{}, fmt.Errorf("paymentevent: %w", err)
+	}
+	if event.SchemaVersion == 0 {
+		event.SchemaVersion = 1
+	}
+	return event, nil
+}
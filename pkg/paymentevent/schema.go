@@ -0,0 +1,126 @@
+package paymentevent
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+)
+
+//go:embed payment-event.v1.schema.json
+var schemaJSON []byte
+
+// Schema returns the JSON schema (draft 2020-12) of Event.
+func Schema() []byte {
+	return slices.Clone(schemaJSON)
+}
+
+// schemaNode is the subset of JSON schema used by payment-event.v1.schema.json.
+type schemaNode struct {
+	Type       string                 `json:"type"`
+	Required   []string               `json:"required"`
+	Properties map[string]*schemaNode `json:"properties"`
+	Enum       []interface{}          `json:"enum"`
+	Minimum    *float64               `json:"minimum"`
+	MinLength  *int                   `json:"minLength"`
+}
+
+var schema = func() *schemaNode {
+	var root schemaNode
+	if err := json.Unmarshal(schemaJSON, &root); err != nil {
+		panic(fmt.Sprintf("paymentevent: invalid embedded schema: %v", err))
+	}
+	return &root
+}()
+
+// Validate checks an event detail against the schema. Unknown fields are
+// allowed so consumers keep working when fields are added.
+func Validate(detail []byte) error {
+	var doc interface{}
+	dec := json.NewDecoder(strings.NewReader(string(detail)))
+	dec.UseNumber()
+	if err := dec.Decode(&doc); err != nil {
+		return fmt.Errorf("paymentevent: %w", err)
+	}
+	if err := schema.validate("", doc); err != nil {
+		return fmt.Errorf("paymentevent: %w", err)
+	}
+	return nil
+}
+
+func (n *schemaNode) validate(path string, value interface{}) error {
+	name := path
+	if name == "" {
+		name = "event"
+	}
+
+	switch n.Type {
+	case "object":
+		obj, ok := value.(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("%s must be an object", name)
+		}
+		for _, field := range n.Required {
+			if _, ok := obj[field]; !ok {
+				return fmt.Errorf("%s is required", joinPath(path, field))
+			}
+		}
+		// 보고되는 오류가 매번 같도록 필드 이름 순으로 검사한다
+		fields := make([]string, 0, len(obj))
+		for field := range obj {
+			fields = append(fields, field)
+		}
+		sort.Strings(fields)
+		for _, field := range fields {
+			if prop, ok := n.Properties[field]; ok {
+				if err := prop.validate(joinPath(path, field), obj[field]); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	case "string":
+		s, ok := value.(string)
+		if !ok {
+			return fmt.Errorf("%s must be a string", name)
+		}
+		if n.MinLength != nil && len(s) < *n.MinLength {
+			return fmt.Errorf("%s must be at least %d characters", name, *n.MinLength)
+		}
+	case "integer":
+		num, ok := value.(json.Number)
+		if !ok {
+			return fmt.Errorf("%s must be an integer", name)
+		}
+		i, err := num.Int64()
+		if err != nil {
+			return fmt.Errorf("%s must be an integer", name)
+		}
+		if n.Minimum != nil && float64(i) < *n.Minimum {
+			return fmt.Errorf("%s must be at least %v", name, *n.Minimum)
+		}
+	}
+
+	if len(n.Enum) > 0 && !n.inEnum(value) {
+		return fmt.Errorf("%s has unknown value %v", name, value)
+	}
+	return nil
+}
+
+func (n *schemaNode) inEnum(value interface{}) bool {
+	for _, allowed := range n.Enum {
+		if fmt.Sprint(allowed) == fmt.Sprint(value) {
+			return true
+		}
+	}
+	return false
+}
+
+func joinPath(path, field string) string {
+	if path == "" {
+		return field
+	}
+	return path + "." + field
+}
@@ -0,0 +1,127 @@
+// Package webhooksig는 payment-sim webhook을 서명하고 검증한다.
+//
+// X-Webhook-Signature 헤더는 Stripe 방식을 따른다:
+//
+//	X-Webhook-Signature: t=1700000000,v1=<hex>,v1=<hex>
+//
+// 각 v1은 HEX(HMAC-SHA256(secret, "<t>.<raw body>"))다. secret 교체 중에는
+// 활성 secret마다 v1을 하나씩 담으므로, 수신자는 그중 하나만 알아도 요청을
+// 검증할 수 있다.
+package webhooksig
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Header는 서명을 담는 HTTP 헤더다.
+const Header = "X-Webhook-Signature"
+
+// Scheme은 이 패키지가 만드는 서명 버전이다.
+const Scheme = "v1"
+
+// DefaultTolerance는 서명 timestamp와 수신자 시계의 허용 차이다 (replay 방지).
+const DefaultTolerance = 5 * time.Minute
+
+var (
+	ErrInvalidHeader     = errors.New("webhooksig: invalid signature header")
+	ErrNoSignature       = errors.New("webhooksig: no v1 signature in header")
+	ErrTimestampExpired  = errors.New("webhooksig: timestamp outside tolerance")
+	ErrSignatureMismatch = errors.New("webhooksig: no signature matches the given secrets")
+)
+
+// Compute는 timestamp 시점에 서명한 payload의 hex v1 서명을 반환한다.
+func Compute(payload []byte, secret string, timestamp int64) string {
+	h := hmac.New(sha256.New, []byte(secret))
+	h.Write([]byte(strconv.FormatInt(timestamp, 10)))
+	h.Write([]byte("."))
+	h.Write(payload)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// Sign은 secret마다 v1 항목을 하나씩 담은 payload의 헤더 값을 만든다.
+func Sign(payload []byte, at time.Time, secrets ...string) string {
+	timestamp := at.Unix()
+	parts := make([]string, 0, len(secrets)+1)
+	parts = append(parts, "t="+strconv.FormatInt(timestamp, 10))
+	for _, secret := range secrets {
+		if secret == "" {
+			continue
+		}
+		parts = append(parts, Scheme+"="+Compute(payload, secret, timestamp))
+	}
+	return strings.Join(parts, ",")
+}
+
+// Parse는 헤더 값을 timestamp와 v1 서명으로 나눈다. 모르는 scheme의 항목은
+// 무시한다.
+func Parse(header string) (int64, []string, error) {
+	var (
+		timestamp  int64
+		haveTime   bool
+		signatures []string
+	)
+
+	for _, part := range strings.Split(header, ",") {
+		key, value, ok := strings.Cut(strings.TrimSpace(part), "=")
+		if !ok {
+			return 0, nil, ErrInvalidHeader
+		}
+		switch key {
+		case "t":
+			t, err := strconv.ParseInt(value, 10, 64)
+			if err != nil {
+				return 0, nil, fmt.Errorf("%w: bad timestamp %q", ErrInvalidHeader, value)
+			}
+			timestamp, haveTime = t, true
+		case Scheme:
+			signatures = append(signatures, value)
+		}
+	}
+
+	if !haveTime {
+		return 0, nil, fmt.Errorf("%w: missing timestamp", ErrInvalidHeader)
+	}
+	if len(signatures) == 0 {
+		return 0, nil, ErrNoSignature
+	}
+	return timestamp, signatures, nil
+}
+
+// Verify는 secrets 중 하나로 header가 payload의 서명인지 확인한다. tolerance가
+// 0이면 timestamp를 검사하지 않는다.
+func Verify(payload []byte, header string, tolerance time.Duration, secrets ...string) error {
+	timestamp, signatures, err := Parse(header)
+	if err != nil {
+		return err
+	}
+
+	if tolerance > 0 {
+		diff := time.Since(time.Unix(timestamp, 0))
+		if diff < 0 {
+			diff = -diff
+		}
+		if diff > tolerance {
+			return ErrTimestampExpired
+		}
+	}
+
+	for _, secret := range secrets {
+		if secret == "" {
+			continue
+		}
+		expected := []byte(Compute(payload, secret, timestamp))
+		for _, signature := range signatures {
+			if hmac.Equal(expected, []byte(signature)) {
+				return nil
+			}
+		}
+	}
+	return ErrSignatureMismatch
+}
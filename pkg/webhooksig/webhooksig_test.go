@@ -0,0 +1,150 @@
+package webhooksig
+
+import (
+	"errors"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestVerify(t *testing.T) {
+	payload := []byte(`{"payment_id":"pay-1","status":"PAYMENT_STATUS_COMPLETED"}`)
+	now := time.Now()
+	ts := strconv.FormatInt(now.Unix(), 10)
+
+	tests := []struct {
+		name      string
+		header    string
+		tolerance time.Duration
+		secrets   []string
+		want      error
+	}{
+		{
+			name:      "single secret",
+			header:    Sign(payload, now, "old"),
+			tolerance: DefaultTolerance,
+			secrets:   []string{"old"},
+		},
+		{
+			name:      "rotation: receiver knows the new secret",
+			header:    Sign(payload, now, "old", "new"),
+			tolerance: DefaultTolerance,
+			secrets:   []string{"new"},
+		},
+		{
+			name:      "rotation: receiver knows the old secret",
+			header:    Sign(payload, now, "old", "new"),
+			tolerance: DefaultTolerance,
+			secrets:   []string{"old"},
+		},
+		{
+			name:      "receiver holds several secrets",
+			header:    Sign(payload, now, "new"),
+			tolerance: DefaultTolerance,
+			secrets:   []string{"", "stale", "new"},
+		},
+		{
+			name:      "unknown schemes are ignored",
+			header:    "t=" + ts + ",v0=deadbeef,v1=" + Compute(payload, "s", now.Unix()),
+			tolerance: DefaultTolerance,
+			secrets:   []string{"s"},
+		},
+		{
+			name:      "no secret matches",
+			header:    Sign(payload, now, "old", "new"),
+			tolerance: DefaultTolerance,
+			secrets:   []string{"other"},
+			want:      ErrSignatureMismatch,
+		},
+		{
+			name:      "tampered body",
+			header:    "t=" + ts + ",v1=" + Compute([]byte(`{}`), "s", now.Unix()),
+			tolerance: DefaultTolerance,
+			secrets:   []string{"s"},
+			want:      ErrSignatureMismatch,
+		},
+		{
+			name:      "within tolerance",
+			header:    Sign(payload, now.Add(-4*time.Minute), "s"),
+			tolerance: DefaultTolerance,
+			secrets:   []string{"s"},
+		},
+		{
+			name:      "too old",
+			header:    Sign(payload, now.Add(-6*time.Minute), "s"),
+			tolerance: DefaultTolerance,
+			secrets:   []string{"s"},
+			want:      ErrTimestampExpired,
+		},
+		{
+			name:      "too far in the future",
+			header:    Sign(payload, now.Add(6*time.Minute), "s"),
+			tolerance: DefaultTolerance,
+			secrets:   []string{"s"},
+			want:      ErrTimestampExpired,
+		},
+		{
+			name:      "zero tolerance skips the timestamp check",
+			header:    Sign(payload, now.Add(-24*time.Hour), "s"),
+			tolerance: 0,
+			secrets:   []string{"s"},
+		},
+		{
+			name:      "signature from another timestamp",
+			header:    "t=" + ts + ",v1=" + Compute(payload, "s", now.Unix()-1),
+			tolerance: DefaultTolerance,
+			secrets:   []string{"s"},
+			want:      ErrSignatureMismatch,
+		},
+		{
+			name:      "missing timestamp",
+			header:    "v1=" + Compute(payload, "s", now.Unix()),
+			tolerance: DefaultTolerance,
+			secrets:   []string{"s"},
+			want:      ErrInvalidHeader,
+		},
+		{
+			name:      "no v1 entry",
+			header:    "t=" + ts,
+			tolerance: DefaultTolerance,
+			secrets:   []string{"s"},
+			want:      ErrNoSignature,
+		},
+		{
+			name:      "malformed entry",
+			header:    "t=" + ts + ",garbage",
+			tolerance: DefaultTolerance,
+			secrets:   []string{"s"},
+			want:      ErrInvalidHeader,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := Verify(payload, tt.header, tt.tolerance, tt.secrets...)
+			if tt.want == nil && err != nil {
+				t.Fatalf("Verify = %v, want nil", err)
+			}
+			if tt.want != nil && !errors.Is(err, tt.want) {
+				t.Fatalf("Verify = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestSignOneEntryPerSecret(t *testing.T) {
+	payload := []byte("body")
+	at := time.Unix(1700000000, 0)
+
+	timestamp, signatures, err := Parse(Sign(payload, at, "a", "", "b"))
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	if timestamp != at.Unix() {
+		t.Errorf("timestamp = %d, want %d", timestamp, at.Unix())
+	}
+	want := []string{Compute(payload, "a", at.Unix()), Compute(payload, "b", at.Unix())}
+	if len(signatures) != len(want) || signatures[0] != want[0] || signatures[1] != want[1] {
+		t.Errorf("signatures = %v, want %v", signatures, want)
+	}
+}